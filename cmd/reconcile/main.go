@@ -0,0 +1,131 @@
+// Command reconcile matches a bank statement against the payouts and
+// settlements in the database and prints the reconciliation report. It
+// exits with status 2 when any item is not fully matched.
+//
+//	reconcile [-format CSV|MT940] [-currency IDR] [-amount-tolerance N] [-date-tolerance N] [-json] statement-file
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/banggibima/be-assignment/config"
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/banggibima/be-assignment/internal/services"
+	"github.com/banggibima/be-assignment/pkg/db"
+)
+
+func main() {
+	format := flag.String("format", "", "statement format, CSV or MT940 (default detected from the file)")
+	currency := flag.String("currency", "", "currency of lines without one (default IDR)")
+	amountTolerance := flag.Int("amount-tolerance", -1, "largest amount difference that still matches (default RECONCILIATION_AMOUNT_TOLERANCE)")
+	dateTolerance := flag.Int("date-tolerance", -1, "largest value date difference in days that still matches (default RECONCILIATION_DATE_TOLERANCE_DAYS)")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: reconcile [flags] statement-file")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fail(err)
+	}
+
+	path := flag.Arg(0)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fail(err)
+	}
+
+	req := dto.ReconcileRequest{Format: *format, Currency: *currency}
+	if *amountTolerance >= 0 {
+		req.AmountTolerance = amountTolerance
+	}
+	if *dateTolerance >= 0 {
+		req.DateToleranceDays = dateTolerance
+	}
+
+	pool, err := db.Init(cfg)
+	if err != nil {
+		fail(err)
+	}
+	defer pool.Close()
+
+	reconciliationService := services.NewReconciliationService(pool, repositories.NewDatabaseReconciliationRepository(pool), cfg.Reconciliation)
+
+	res, err := reconciliationService.Reconcile(context.Background(), filepath.Base(path), content, req)
+	if err != nil {
+		fail(err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(res); err != nil {
+			fail(err)
+		}
+	} else {
+		printReport(res)
+	}
+
+	if res.PartialCount > 0 || res.UnmatchedCount > 0 {
+		pool.Close()
+		os.Exit(2)
+	}
+}
+
+func printReport(res *dto.ReconciliationResponse) {
+	fmt.Printf("Reconciliation %s (%s, %s..%s)\n", res.ID, res.Format, res.StatementFrom, res.StatementTo)
+	fmt.Printf("%d lines: %d matched, %d partial, %d unmatched\n\n", res.LineCount, res.MatchedCount, res.PartialCount, res.UnmatchedCount)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tLINE\tDATE\tAMOUNT\tREFERENCE\tMATCH\tEXPECTED\tREASON")
+	for _, item := range res.Items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			item.Status,
+			optional(item.LineNo),
+			optional(item.ValueDate),
+			optional(item.Amount),
+			optional(item.Reference),
+			match(item),
+			expected(item),
+			item.Reason,
+		)
+	}
+	w.Flush()
+}
+
+func match(item dto.ReconciliationItemResponse) string {
+	if item.MatchKind == nil || item.MatchID == nil {
+		return "-"
+	}
+	return *item.MatchKind + " " + *item.MatchID
+}
+
+func expected(item dto.ReconciliationItemResponse) string {
+	if item.ExpectedAmount == nil || item.ExpectedDate == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d %s on %s", *item.ExpectedAmount, item.Currency, *item.ExpectedDate)
+}
+
+func optional[T any](value *T) string {
+	if value == nil {
+		return "-"
+	}
+	return fmt.Sprint(*value)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "reconcile:", err)
+	os.Exit(1)
+}
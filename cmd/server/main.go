@@ -5,13 +5,16 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	_ "time/tzdata"
 
 	"github.com/banggibima/be-assignment/config"
 	"github.com/banggibima/be-assignment/docs"
 	"github.com/banggibima/be-assignment/internal/handlers"
+	"github.com/banggibima/be-assignment/internal/models"
 	"github.com/banggibima/be-assignment/internal/repositories"
 	"github.com/banggibima/be-assignment/internal/services"
 	"github.com/banggibima/be-assignment/pkg/db"
+	"github.com/banggibima/be-assignment/pkg/storage"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -29,16 +32,42 @@ func main() {
 	}
 	defer pool.Close()
 
+	artifacts, err := storage.Init(context.Background(), cfg.Storage)
+	if err != nil {
+		panic(err)
+	}
+
+	settlementDay := models.SettlementDay{
+		TimeZone:   cfg.Settlement.TimeZone,
+		CutoffHour: cfg.Settlement.CutoffHour,
+	}
+
 	productRepo := repositories.NewDatabaseProductRepository(pool)
 	orderRepo := repositories.NewDatabaseOrderRepository(pool)
 	jobRepo := repositories.NewDatabaseJobRepository(pool)
-	transRepo := repositories.NewDatabaseTransactionRepository(pool)
+	transRepo := repositories.NewDatabaseTransactionRepository(pool, settlementDay)
 	settleRepo := repositories.NewDatabaseSettlementRepository(pool)
+	jobEventRepo := repositories.NewDatabaseJobEventRepository(pool)
+	feePlanRepo := repositories.NewDatabaseFeePlanRepository(pool)
+	merchantConfigRepo := repositories.NewDatabaseMerchantSettlementConfigRepository(pool)
+	payoutRepo := repositories.NewDatabasePayoutRepository(pool)
+	ledgerRepo := repositories.NewDatabaseLedgerRepository(pool)
+	reconciliationRepo := repositories.NewDatabaseReconciliationRepository(pool)
 
 	orderService := services.NewOrderService(pool, orderRepo, productRepo)
-	jobService := services.NewJobService(pool, jobRepo, transRepo, settleRepo)
+	jobEventService := services.NewJobEventService(jobEventRepo)
+	ledgerService := services.NewLedgerService(ledgerRepo)
+	jobService := services.NewJobService(pool, jobRepo, transRepo, settleRepo, feePlanRepo, ledgerService, artifacts, jobEventService, cfg.Job)
+	settlementService := services.NewSettlementService(pool, transRepo, settleRepo)
+	settlementRunService := services.NewSettlementRunService(pool, settleRepo, ledgerService)
+	feePlanService := services.NewFeePlanService(feePlanRepo)
+	merchantService := services.NewMerchantService(merchantConfigRepo, settlementDay)
+	payoutService := services.NewPayoutService(pool, payoutRepo, ledgerService, cfg.Payout)
+	reconciliationService := services.NewReconciliationService(pool, reconciliationRepo, cfg.Reconciliation)
+	transactionService := services.NewTransactionService(pool, transRepo, ledgerService)
 
 	ctx, cancel := context.WithCancel(context.Background())
+	go jobEventService.Run(ctx)
 	jobService.StartWorkerPool(ctx)
 
 	router := gin.Default()
@@ -51,7 +80,15 @@ func main() {
 
 	handlers.Register(router)
 	handlers.NewOrderHandler(orderService).Register(router)
-	handlers.NewJobHandler(jobService).Register(router)
+	handlers.NewJobHandler(jobService, jobEventService).Register(router)
+	handlers.NewSettlementHandler(settlementService).Register(router)
+	handlers.NewSettlementRunHandler(settlementRunService).Register(router)
+	handlers.NewFeePlanHandler(feePlanService).Register(router)
+	handlers.NewMerchantHandler(merchantService).Register(router)
+	handlers.NewPayoutHandler(payoutService).Register(router)
+	handlers.NewLedgerHandler(ledgerService).Register(router)
+	handlers.NewReconciliationHandler(reconciliationService).Register(router)
+	handlers.NewTransactionHandler(transactionService).Register(router)
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
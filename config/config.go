@@ -1,7 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"runtime"
+	"strconv"
+	"time"
 )
 
 type HTTP struct {
@@ -17,12 +21,199 @@ type Postgres struct {
 	SSLMode  string
 }
 
+type Job struct {
+	Workers               int
+	PollInterval          time.Duration
+	LeaseTTL              time.Duration
+	HeartbeatInterval     time.Duration
+	ReapInterval          time.Duration
+	MaxAttempts           int
+	RetryBaseDelay        time.Duration
+	RetryMaxDelay         time.Duration
+	ProgressFlushRows     int
+	ProgressFlushInterval time.Duration
+	PartitionDays         int
+}
+
+// Settlement holds the settlement day of merchants without their own
+// time zone and cut-off hour.
+type Settlement struct {
+	TimeZone   string
+	CutoffHour int
+}
+
+// Payout holds the smallest amount worth paying out; smaller balances wait
+// for a later payout date.
+type Payout struct {
+	MinAmount int
+}
+
+// Reconciliation holds how far a bank statement line may differ from the
+// payout or settlement it is matched with and still count as matched.
+type Reconciliation struct {
+	AmountTolerance   int
+	DateToleranceDays int
+}
+
+// Storage selects where job artifacts such as settlement reports are kept:
+// a local directory, or a bucket of an S3-compatible object store.
+type Storage struct {
+	Backend  string
+	LocalDir string
+	S3       S3
+}
+
+// S3 holds the bucket of the S3 storage backend. UsePathStyle addresses the
+// bucket in the URL path, which MinIO and most self-hosted stores need.
+type S3 struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UseSSL          bool
+	UsePathStyle    bool
+}
+
 type Config struct {
-	HTTP     HTTP
-	Postgres Postgres
+	HTTP           HTTP
+	Postgres       Postgres
+	Job            Job
+	Settlement     Settlement
+	Payout         Payout
+	Reconciliation Reconciliation
+	Storage        Storage
 }
 
 func Load() (*Config, error) {
+	workers, err := getEnvInt("JOB_WORKERS", runtime.NumCPU())
+	if err != nil {
+		return nil, err
+	}
+
+	pollInterval, err := getEnvDuration("JOB_POLL_INTERVAL", time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	leaseTTL, err := getEnvDuration("JOB_LEASE_TTL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeatInterval, err := getEnvDuration("JOB_HEARTBEAT_INTERVAL", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	reapInterval, err := getEnvDuration("JOB_REAP_INTERVAL", 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	maxAttempts, err := getEnvInt("JOB_MAX_ATTEMPTS", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	retryBaseDelay, err := getEnvDuration("JOB_RETRY_BASE_DELAY", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	retryMaxDelay, err := getEnvDuration("JOB_RETRY_MAX_DELAY", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	progressFlushRows, err := getEnvInt("JOB_PROGRESS_FLUSH_ROWS", 1000)
+	if err != nil {
+		return nil, err
+	}
+
+	progressFlushInterval, err := getEnvDuration("JOB_PROGRESS_FLUSH_INTERVAL", time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	partitionDays, err := getEnvInt("JOB_PARTITION_DAYS", 7)
+	if err != nil {
+		return nil, err
+	}
+
+	timeZone := os.Getenv("SETTLEMENT_TIME_ZONE")
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return nil, fmt.Errorf("invalid SETTLEMENT_TIME_ZONE: %w", err)
+	}
+
+	cutoffHour, err := getEnvInt("SETTLEMENT_CUTOFF_HOUR", 0)
+	if err != nil {
+		return nil, err
+	}
+	if cutoffHour < 0 || cutoffHour > 23 {
+		return nil, fmt.Errorf("invalid SETTLEMENT_CUTOFF_HOUR: %d is not an hour of the day", cutoffHour)
+	}
+
+	payoutMinAmount, err := getEnvInt("PAYOUT_MIN_AMOUNT", 10000)
+	if err != nil {
+		return nil, err
+	}
+	if payoutMinAmount < 0 {
+		return nil, fmt.Errorf("invalid PAYOUT_MIN_AMOUNT: %d is negative", payoutMinAmount)
+	}
+
+	amountTolerance, err := getEnvInt("RECONCILIATION_AMOUNT_TOLERANCE", 0)
+	if err != nil {
+		return nil, err
+	}
+	if amountTolerance < 0 {
+		return nil, fmt.Errorf("invalid RECONCILIATION_AMOUNT_TOLERANCE: %d is negative", amountTolerance)
+	}
+
+	dateToleranceDays, err := getEnvInt("RECONCILIATION_DATE_TOLERANCE_DAYS", 2)
+	if err != nil {
+		return nil, err
+	}
+	if dateToleranceDays < 0 {
+		return nil, fmt.Errorf("invalid RECONCILIATION_DATE_TOLERANCE_DAYS: %d is negative", dateToleranceDays)
+	}
+
+	storageBackend := os.Getenv("STORAGE_BACKEND")
+	if storageBackend == "" {
+		storageBackend = "local"
+	}
+	if storageBackend != "local" && storageBackend != "s3" {
+		return nil, fmt.Errorf("invalid STORAGE_BACKEND: %q is not local or s3", storageBackend)
+	}
+
+	storageLocalDir := os.Getenv("STORAGE_LOCAL_DIR")
+	if storageLocalDir == "" {
+		storageLocalDir = "/tmp/settlements"
+	}
+
+	s3Region := os.Getenv("STORAGE_S3_REGION")
+	if s3Region == "" {
+		s3Region = "us-east-1"
+	}
+
+	s3UseSSL, err := getEnvBool("STORAGE_S3_USE_SSL", true)
+	if err != nil {
+		return nil, err
+	}
+
+	s3UsePathStyle, err := getEnvBool("STORAGE_S3_USE_PATH_STYLE", true)
+	if err != nil {
+		return nil, err
+	}
+
+	if storageBackend == "s3" && (os.Getenv("STORAGE_S3_ENDPOINT") == "" || os.Getenv("STORAGE_S3_BUCKET") == "") {
+		return nil, fmt.Errorf("STORAGE_S3_ENDPOINT and STORAGE_S3_BUCKET are required for the s3 storage backend")
+	}
+
 	config := &Config{
 		HTTP: HTTP{
 			Port: os.Getenv("HTTP_PORT"),
@@ -35,7 +226,87 @@ func Load() (*Config, error) {
 			Database: os.Getenv("POSTGRES_DATABASE"),
 			SSLMode:  os.Getenv("POSTGRES_SSLMODE"),
 		},
+		Job: Job{
+			Workers:               workers,
+			PollInterval:          pollInterval,
+			LeaseTTL:              leaseTTL,
+			HeartbeatInterval:     heartbeatInterval,
+			ReapInterval:          reapInterval,
+			MaxAttempts:           maxAttempts,
+			RetryBaseDelay:        retryBaseDelay,
+			RetryMaxDelay:         retryMaxDelay,
+			ProgressFlushRows:     progressFlushRows,
+			ProgressFlushInterval: progressFlushInterval,
+			PartitionDays:         partitionDays,
+		},
+		Settlement: Settlement{
+			TimeZone:   timeZone,
+			CutoffHour: cutoffHour,
+		},
+		Payout: Payout{
+			MinAmount: payoutMinAmount,
+		},
+		Reconciliation: Reconciliation{
+			AmountTolerance:   amountTolerance,
+			DateToleranceDays: dateToleranceDays,
+		},
+		Storage: Storage{
+			Backend:  storageBackend,
+			LocalDir: storageLocalDir,
+			S3: S3{
+				Endpoint:        os.Getenv("STORAGE_S3_ENDPOINT"),
+				Region:          s3Region,
+				Bucket:          os.Getenv("STORAGE_S3_BUCKET"),
+				Prefix:          os.Getenv("STORAGE_S3_PREFIX"),
+				AccessKeyID:     os.Getenv("STORAGE_S3_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("STORAGE_S3_SECRET_ACCESS_KEY"),
+				UseSSL:          s3UseSSL,
+				UsePathStyle:    s3UsePathStyle,
+			},
+		},
 	}
 
 	return config, nil
 }
+
+func getEnvInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	return n, nil
+}
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	return d, nil
+}
+
+func getEnvBool(key string, fallback bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	return b, nil
+}
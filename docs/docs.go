@@ -17,9 +17,13 @@ const docTemplate = `{
     "paths": {
         "/downloads/{job_id}": {
             "get": {
-                "description": "Download CSV file of completed job",
+                "description": "Download the settlement report of a completed job. It is sent in the format chosen when the job was created unless Accept asks for another one: text/csv, application/json, application/x-ndjson, application/vnd.openxmlformats-officedocument.spreadsheetml.sheet (XLSX) or application/vnd.apache.parquet. X-Checksum-SHA256 carries the hex SHA-256 digest of the file.",
                 "produces": [
-                    "application/octet-stream"
+                    "text/csv",
+                    "application/json",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+                    "application/vnd.apache.parquet"
                 ],
                 "tags": [
                     "Job"
@@ -40,36 +44,93 @@ const docTemplate = `{
                         "schema": {
                             "type": "file"
                         }
+                    },
+                    "404": {
+                        "description": "JOB_NOT_FOUND or REPORT_NOT_FOUND",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "406": {
+                        "description": "UNSUPPORTED_REPORT_FORMAT",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/health": {
+        "/downloads/{job_id}/manifest": {
             "get": {
-                "description": "Check if the server is running",
+                "description": "Get the manifest of the settlement report a completed job wrote: file name, format, size, row count, generation time and SHA-256 digest",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Health"
+                    "Job"
+                ],
+                "summary": "Get Job Result Manifest",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "job_id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Health Check",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/dto.ReportManifestResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "JOB_NOT_FOUND or REPORT_NOT_FOUND",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/jobs/settlement": {
+        "/fee-plans": {
+            "get": {
+                "description": "List every fee plan",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Fee Plan"
+                ],
+                "summary": "List Fee Plans",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.FeePlanResponse"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Create a new settlement job",
+                "description": "Create a fee plan of a percentage (in basis points) plus a fixed fee per transaction, with optional volume tiers and a daily minimum/maximum fee",
                 "consumes": [
                     "application/json"
                 ],
@@ -77,25 +138,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Job"
+                    "Fee Plan"
                 ],
-                "summary": "Create Settlement Job",
+                "summary": "Create Fee Plan",
                 "parameters": [
                     {
-                        "description": "Job request",
+                        "description": "Fee plan",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dto.CreateSettlementJobRequest"
+                            "$ref": "#/definitions/dto.CreateFeePlanRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "202": {
-                        "description": "Accepted",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/dto.CreateSettlementJobResponse"
+                            "$ref": "#/definitions/dto.FeePlanResponse"
                         }
                     },
                     "400": {
@@ -113,20 +174,20 @@ const docTemplate = `{
                 }
             }
         },
-        "/jobs/{id}": {
+        "/fee-plans/{id}": {
             "get": {
-                "description": "Get the status of a specific job",
+                "description": "Get a fee plan by ID",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Job"
+                    "Fee Plan"
                 ],
-                "summary": "Get Job Status",
+                "summary": "Get Fee Plan",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Job ID",
+                        "description": "Fee plan ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -136,11 +197,11 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.SettlementProgressResponse"
+                            "$ref": "#/definitions/dto.FeePlanResponse"
                         }
                     },
                     "404": {
-                        "description": "JOB_NOT_FOUND",
+                        "description": "FEE_PLAN_NOT_FOUND",
                         "schema": {
                             "$ref": "#/definitions/dto.ErrorResponse"
                         }
@@ -154,30 +215,110 @@ const docTemplate = `{
                 }
             }
         },
-        "/jobs/{id}/cancel": {
-            "post": {
-                "description": "Cancel a running job",
+        "/health": {
+            "get": {
+                "description": "Check if the server is running",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Health Check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs": {
+            "get": {
+                "description": "Search jobs by status, type, creation time and settlement range with cursor pagination",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Job"
                 ],
-                "summary": "Cancel Job",
+                "summary": "List Jobs",
                 "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Job status, repeatable (QUEUED, RUNNING, DONE, CANCELLED, FAILED, DEAD)",
+                        "name": "status",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "description": "Job ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Job type, e.g. SETTLEMENT",
+                        "name": "job_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created at or after (RFC 3339 or YYYY-MM-DD)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before (RFC 3339), or on or before (YYYY-MM-DD)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Settlement range starts on or after (YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Settlement range ends on or before (YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from a previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort by creation time: asc or desc (default desc)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 1-100 (default 20)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.CancelJobResponse"
+                            "$ref": "#/definitions/dto.ListJobsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
                         }
                     },
                     "500": {
@@ -189,9 +330,52 @@ const docTemplate = `{
                 }
             }
         },
-        "/orders": {
+        "/jobs/dead-letter": {
+            "get": {
+                "description": "List jobs that exhausted their retries. Use POST /jobs/{id}/resume to requeue one.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Job"
+                ],
+                "summary": "List Dead-Letter Jobs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of jobs (default 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.JobStatusResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/settlement": {
             "post": {
-                "description": "Create a new order",
+                "description": "Create a new settlement job. A job whose statuses leave out some settlement statuses is report-only: it writes its report but does not replace settlements or post to the ledger.",
                 "consumes": [
                     "application/json"
                 ],
@@ -199,25 +383,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Order"
+                    "Job"
                 ],
-                "summary": "Create Order",
+                "summary": "Create Settlement Job",
                 "parameters": [
                     {
-                        "description": "Order request",
+                        "description": "Job request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dto.CreateOrderRequest"
+                            "$ref": "#/definitions/dto.CreateSettlementJobRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "$ref": "#/definitions/dto.CreateOrderResponse"
+                            "$ref": "#/definitions/dto.CreateSettlementJobResponse"
                         }
                     },
                     "400": {
@@ -226,14 +410,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "PRODUCT_NOT_FOUND",
-                        "schema": {
-                            "$ref": "#/definitions/dto.ErrorResponse"
-                        }
-                    },
                     "409": {
-                        "description": "OUT_OF_STOCK",
+                        "description": "LATER_SETTLEMENTS_EXIST",
                         "schema": {
                             "$ref": "#/definitions/dto.ErrorResponse"
                         }
@@ -247,20 +425,20 @@ const docTemplate = `{
                 }
             }
         },
-        "/orders/{id}": {
+        "/jobs/{id}": {
             "get": {
-                "description": "Get order details by ID",
+                "description": "Get the status of a specific job",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Order"
+                    "Job"
                 ],
-                "summary": "Get Order By ID",
+                "summary": "Get Job Status",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Order ID",
+                        "description": "Job ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -270,11 +448,11 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.GetOrderResponse"
+                            "$ref": "#/definitions/dto.SettlementProgressResponse"
                         }
                     },
                     "404": {
-                        "description": "ORDER_NOT_FOUND",
+                        "description": "JOB_NOT_FOUND",
                         "schema": {
                             "$ref": "#/definitions/dto.ErrorResponse"
                         }
@@ -287,72 +465,2177 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "dto.CancelJobResponse": {
-            "type": "object",
-            "properties": {
-                "job_id": {
-                    "type": "string"
-                },
-                "message": {
-                    "type": "string"
-                },
-                "status": {
-                    "type": "string"
+        },
+        "/jobs/{id}/cancel": {
+            "post": {
+                "description": "Cancel a running job",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Job"
+                ],
+                "summary": "Cancel Job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.CancelJobResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "dto.CreateOrderRequest": {
-            "type": "object",
+        "/jobs/{id}/events": {
+            "get": {
+                "description": "Stream progress, status transitions and the final download link of a job as Server-Sent Events. The first event is the current state; the stream ends when the job is DONE, CANCELLED or DEAD.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Job"
+                ],
+                "summary": "Stream Job Events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.JobEvent"
+                        }
+                    },
+                    "404": {
+                        "description": "JOB_NOT_FOUND",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}/resume": {
+            "post": {
+                "description": "Re-queue a cancelled, failed or dead-letter job so it continues from its last checkpoint",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Job"
+                ],
+                "summary": "Resume Job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ResumeJobResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found or not resumable",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ResumeJobResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ledger/balances": {
+            "get": {
+                "description": "Get the debits, credits and balance per currency of ledger accounts. Accounts are platform:cash, platform:fee_revenue, merchant:{id}:pending and merchant:{id}:payable.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Ledger"
+                ],
+                "summary": "Get Ledger Balances",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Account ID",
+                        "name": "account_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Merchant ID, selects its pending and payable accounts",
+                        "name": "merchant_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.LedgerBalanceResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ledger/invariants": {
+            "get": {
+                "description": "Check that every ledger journal sums to zero per currency and list the ones that do not",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Ledger"
+                ],
+                "summary": "Check Ledger Invariants",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.LedgerInvariantsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ledger/journals": {
+            "get": {
+                "description": "Search ledger journals with their entries by kind, source and account with cursor pagination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Ledger"
+                ],
+                "summary": "List Ledger Journals",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "PAYMENT, FEE, REFUND, CHARGEBACK, SETTLEMENT, PAYOUT or PAYOUT_REVERSAL",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Transaction, settlement run or payout ID",
+                        "name": "source_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Account ID",
+                        "name": "account_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from a previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 1-500 (default 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ListLedgerJournalsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/merchants/{merchant_id}/fee-plans": {
+            "get": {
+                "description": "List the fee plans assigned to a merchant, most recent effective date first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Fee Plan"
+                ],
+                "summary": "List Merchant Fee Plans",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Merchant ID",
+                        "name": "merchant_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.MerchantFeePlanResponse"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Put a merchant on a fee plan from a date, optionally until a date. Assigning again with the same effective_from replaces that assignment.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Fee Plan"
+                ],
+                "summary": "Assign Fee Plan To Merchant",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Merchant ID",
+                        "name": "merchant_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Assignment",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.AssignFeePlanRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.MerchantFeePlanResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "FEE_PLAN_NOT_FOUND",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/merchants/{merchant_id}/settlement-config": {
+            "get": {
+                "description": "Get the time zone and cut-off hour that define a merchant's settlement day. Merchants without their own config get the default one with default=true.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Merchant"
+                ],
+                "summary": "Get Merchant Settlement Config",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Merchant ID",
+                        "name": "merchant_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.MerchantSettlementConfigResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Set a merchant's IANA time zone and cut-off hour (0-23). Payments from the cut-off hour onwards belong to the next settlement day.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Merchant"
+                ],
+                "summary": "Update Merchant Settlement Config",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Merchant ID",
+                        "name": "merchant_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Settlement config",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.UpdateMerchantSettlementConfigRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.MerchantSettlementConfigResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a merchant's own settlement config so it uses the default time zone and cut-off hour again",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Merchant"
+                ],
+                "summary": "Reset Merchant Settlement Config",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Merchant ID",
+                        "name": "merchant_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.MerchantSettlementConfigResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders": {
+            "post": {
+                "description": "Create a new order",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Create Order",
+                "parameters": [
+                    {
+                        "description": "Order request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateOrderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateOrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "PRODUCT_NOT_FOUND",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "OUT_OF_STOCK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/{id}": {
+            "get": {
+                "description": "Get order details by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Get Order By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.GetOrderResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "ORDER_NOT_FOUND",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payouts": {
+            "get": {
+                "description": "Search payouts by merchant, currency, status and payout date range with cursor pagination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payout"
+                ],
+                "summary": "List Payouts",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Merchant ID",
+                        "name": "merchant_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "PENDING, SENT, CONFIRMED or FAILED",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payout date on or after (YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payout date on or before (YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from a previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 1-500 (default 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ListPayoutsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payouts/generate": {
+            "post": {
+                "description": "Batch the settlements dated before the payout date that are not paid out yet into one PENDING payout per merchant and currency. Balances below the minimum amount wait for a later payout date.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payout"
+                ],
+                "summary": "Generate Payouts",
+                "parameters": [
+                    {
+                        "description": "Payout date (default today), merchants and minimum amount override",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.GeneratePayoutsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.PayoutResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payouts/{id}": {
+            "get": {
+                "description": "Get a payout with the settlements it pays out",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payout"
+                ],
+                "summary": "Get Payout",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Payout ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.PayoutResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "PAYOUT_NOT_FOUND",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payouts/{id}/status": {
+            "post": {
+                "description": "Move a payout from PENDING to SENT or FAILED, or from SENT to CONFIRMED or FAILED. FAILED requires a failure_reason and releases the settlements to the next payout generation.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payout"
+                ],
+                "summary": "Update Payout Status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Payout ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New status",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.UpdatePayoutStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.PayoutResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "PAYOUT_NOT_FOUND",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "INVALID_PAYOUT_TRANSITION",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/reconciliations": {
+            "post": {
+                "description": "Upload a bank statement (CSV or MT940) and match its debits against sent payouts and its credits against settlement net amounts by reference, amount and value date. Returns the report of matched, partially matched and unmatched items.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Reconciliation"
+                ],
+                "summary": "Reconcile Bank Statement",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Bank statement",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "CSV or MT940 (default detected from the file)",
+                        "name": "format",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency of lines without one (default IDR)",
+                        "name": "currency",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Largest amount difference that still matches",
+                        "name": "amount_tolerance",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Largest value date difference in days that still matches",
+                        "name": "date_tolerance_days",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReconciliationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/reconciliations/{id}": {
+            "get": {
+                "description": "Get a saved reconciliation report, optionally only its items of one status",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Reconciliation"
+                ],
+                "summary": "Get Reconciliation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reconciliation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "MATCHED, PARTIAL or UNMATCHED",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReconciliationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "RECONCILIATION_NOT_FOUND",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/settlement-runs": {
+            "get": {
+                "description": "List every run that settled a merchant on a date, newest first, with the row each run produced",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Settlement"
+                ],
+                "summary": "List Settlement Runs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Merchant ID",
+                        "name": "merchant_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Settlement date (YYYY-MM-DD)",
+                        "name": "date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency (default IDR)",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.SettlementRunVersionResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/settlement-runs/{id}/rollback": {
+            "post": {
+                "description": "Replace the settlements of a date range with the rows of an earlier run. The range defaults to the run's whole range and must lie within it. Ranges whose merchants have later settlements in the same currency are refused.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Settlement"
+                ],
+                "summary": "Roll Back Settlements To A Run",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Run ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Date range",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/dto.RollbackSettlementRunRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SettlementRunResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "SETTLEMENT_RUN_NOT_FOUND",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "LATER_SETTLEMENTS_EXIST",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/settlements": {
+            "get": {
+                "description": "Search settlements by merchant, date range and run with cursor pagination. Totals cover every matching settlement, not only the page, one entry per currency.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Settlement"
+                ],
+                "summary": "List Settlements",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Merchant ID",
+                        "name": "merchant_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Settlement date on or after (YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Settlement date on or before (YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Run that produced the settlement",
+                        "name": "run_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only settlements whose plan fee differs from the recorded fee (true) or matches it (false)",
+                        "name": "fee_mismatch",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from a previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 1-500 (default 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ListSettlementsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/settlements/{merchant_id}/{date}": {
+            "get": {
+                "description": "Get the settlement of a merchant on a date",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Settlement"
+                ],
+                "summary": "Get Settlement",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Merchant ID",
+                        "name": "merchant_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Settlement date (YYYY-MM-DD)",
+                        "name": "date",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency (default IDR)",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SettlementResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "SETTLEMENT_NOT_FOUND",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/transactions": {
+            "post": {
+                "description": "Record a payment transaction of an existing order. Recording an ID again returns the stored transaction unchanged with 200.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Transaction"
+                ],
+                "summary": "Create Transaction",
+                "parameters": [
+                    {
+                        "description": "Transaction",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateTransactionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Already recorded",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TransactionResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TransactionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "ORDER_NOT_FOUND",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/transactions/bulk": {
+            "post": {
+                "description": "Record transactions in bulk from the request body: NDJSON (one JSON transaction per line) or CSV (a header naming the JSON fields, timestamps in RFC 3339). Invalid rows and rows of unknown orders are reported by line and skipped; IDs recorded already are counted as duplicates.",
+                "consumes": [
+                    "application/x-ndjson",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Transaction"
+                ],
+                "summary": "Import Transactions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "NDJSON or CSV (default from Content-Type, else NDJSON)",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ImportTransactionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/transactions/{id}": {
+            "get": {
+                "description": "Get a recorded transaction by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Transaction"
+                ],
+                "summary": "Get Transaction",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Transaction ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TransactionResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "TRANSACTION_NOT_FOUND",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "dto.AssignFeePlanRequest": {
+            "type": "object",
+            "required": [
+                "effective_from",
+                "plan_id"
+            ],
+            "properties": {
+                "effective_from": {
+                    "type": "string"
+                },
+                "effective_to": {
+                    "type": "string"
+                },
+                "plan_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CancelJobResponse": {
+            "type": "object",
+            "properties": {
+                "job_id": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CreateFeePlanRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "fixed_fee": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "max_fee": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "min_fee": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "name": {
+                    "type": "string"
+                },
+                "percentage_bps": {
+                    "type": "integer",
+                    "maximum": 10000,
+                    "minimum": 0
+                },
+                "tiers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.FeePlanTierRequest"
+                    }
+                }
+            }
+        },
+        "dto.CreateOrderRequest": {
+            "type": "object",
             "required": [
                 "buyer_id",
                 "product_id",
                 "quantity"
             ],
             "properties": {
-                "buyer_id": {
+                "buyer_id": {
+                    "type": "string"
+                },
+                "product_id": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "minimum": 1
+                }
+            }
+        },
+        "dto.CreateOrderResponse": {
+            "type": "object",
+            "properties": {
+                "buyer_id": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "product_id": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total_price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.CreateSettlementJobRequest": {
+            "type": "object",
+            "required": [
+                "exclude_merchant_ids",
+                "from",
+                "merchant_ids",
+                "statuses",
+                "to"
+            ],
+            "properties": {
+                "aggregation": {
+                    "type": "string",
+                    "enum": [
+                        "STREAM",
+                        "DATABASE"
+                    ]
+                },
+                "exclude_merchant_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "merchant_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "report_format": {
+                    "type": "string",
+                    "enum": [
+                        "CSV",
+                        "JSON",
+                        "NDJSON",
+                        "XLSX",
+                        "PARQUET"
+                    ]
+                },
+                "statuses": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CreateSettlementJobResponse": {
+            "type": "object",
+            "properties": {
+                "job_id": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CreateTransactionRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "fee": {
+                    "type": "integer"
+                },
+                "fee_currency": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "merchant_id": {
+                    "type": "string"
+                },
+                "order_id": {
+                    "type": "string"
+                },
+                "paid_at": {
+                    "type": "string"
+                },
+                "reversal_amount": {
+                    "type": "integer"
+                },
+                "reversed_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.FeePlanResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "fixed_fee": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "max_fee": {
+                    "type": "integer"
+                },
+                "min_fee": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "percentage_bps": {
+                    "type": "integer"
+                },
+                "tiers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.FeePlanTierResponse"
+                    }
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.FeePlanTierRequest": {
+            "type": "object",
+            "properties": {
+                "fixed_fee": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "min_volume": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "percentage_bps": {
+                    "type": "integer",
+                    "maximum": 10000,
+                    "minimum": 0
+                }
+            }
+        },
+        "dto.FeePlanTierResponse": {
+            "type": "object",
+            "properties": {
+                "fixed_fee": {
+                    "type": "integer"
+                },
+                "min_volume": {
+                    "type": "integer"
+                },
+                "percentage_bps": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.GeneratePayoutsRequest": {
+            "type": "object",
+            "required": [
+                "merchant_ids"
+            ],
+            "properties": {
+                "merchant_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "min_amount": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "payout_date": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.GetOrderResponse": {
+            "type": "object",
+            "properties": {
+                "buyer_id": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "product_id": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "total_price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.ImportTransactionsResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "duplicates": {
+                    "type": "integer"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.TransactionImportError"
+                    }
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.JobStatusResponse": {
+            "type": "object",
+            "properties": {
+                "aggregation": {
+                    "type": "string"
+                },
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "download_url": {
+                    "type": "string"
+                },
+                "exclude_merchant_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "job_type": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "manifest": {
+                    "$ref": "#/definitions/dto.ReportManifestResponse"
+                },
+                "merchant_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "next_retry_at": {
+                    "type": "string"
+                },
+                "parent_job_id": {
+                    "type": "string"
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "progress": {
+                    "type": "integer"
+                },
+                "report_format": {
+                    "type": "string"
+                },
+                "report_only": {
+                    "type": "boolean"
+                },
+                "result_path": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "statuses": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.LedgerBalanceResponse": {
+            "type": "object",
+            "properties": {
+                "account_id": {
+                    "type": "string"
+                },
+                "balance": {
+                    "type": "integer"
+                },
+                "credit": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "debit": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.LedgerEntryResponse": {
+            "type": "object",
+            "properties": {
+                "account_id": {
+                    "type": "string"
+                },
+                "credit": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "debit": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.LedgerImbalanceResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "journal_id": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.LedgerInvariantsResponse": {
+            "type": "object",
+            "properties": {
+                "balanced": {
+                    "type": "boolean"
+                },
+                "imbalances": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.LedgerImbalanceResponse"
+                    }
+                }
+            }
+        },
+        "dto.LedgerJournalResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.LedgerEntryResponse"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "posted_at": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "source_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ListJobsResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.JobStatusResponse"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ListLedgerJournalsResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.LedgerJournalResponse"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ListPayoutsResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.PayoutResponse"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ListSettlementsResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.SettlementResponse"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "totals": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.SettlementTotalsResponse"
+                    }
+                }
+            }
+        },
+        "dto.MerchantFeePlanResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "effective_from": {
+                    "type": "string"
+                },
+                "effective_to": {
+                    "type": "string"
+                },
+                "merchant_id": {
+                    "type": "string"
+                },
+                "plan": {
+                    "$ref": "#/definitions/dto.FeePlanResponse"
+                },
+                "plan_id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.MerchantSettlementConfigResponse": {
+            "type": "object",
+            "properties": {
+                "cutoff_hour": {
+                    "type": "integer"
+                },
+                "default": {
+                    "type": "boolean"
+                },
+                "merchant_id": {
+                    "type": "string"
+                },
+                "time_zone": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.PayoutItemResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "date": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.PayoutResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "confirmed_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "failed_at": {
+                    "type": "string"
+                },
+                "failure_reason": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.PayoutItemResponse"
+                    }
+                },
+                "merchant_id": {
+                    "type": "string"
+                },
+                "payout_date": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "settlement_count": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ReconciliationItemResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "difference": {
+                    "type": "integer"
+                },
+                "expected_amount": {
+                    "type": "integer"
+                },
+                "expected_date": {
+                    "type": "string"
+                },
+                "line_no": {
+                    "type": "integer"
+                },
+                "match_id": {
+                    "type": "string"
+                },
+                "match_kind": {
+                    "type": "string"
+                },
+                "merchant_id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "value_date": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ReconciliationResponse": {
+            "type": "object",
+            "properties": {
+                "amount_tolerance": {
+                    "type": "integer"
+                },
+                "created_at": {
                     "type": "string"
                 },
-                "product_id": {
+                "date_tolerance_days": {
+                    "type": "integer"
+                },
+                "file_name": {
                     "type": "string"
                 },
-                "quantity": {
-                    "type": "integer",
-                    "minimum": 1
+                "format": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.ReconciliationItemResponse"
+                    }
+                },
+                "line_count": {
+                    "type": "integer"
+                },
+                "matched_count": {
+                    "type": "integer"
+                },
+                "partial_count": {
+                    "type": "integer"
+                },
+                "statement_from": {
+                    "type": "string"
+                },
+                "statement_to": {
+                    "type": "string"
+                },
+                "unmatched_count": {
+                    "type": "integer"
                 }
             }
         },
-        "dto.CreateOrderResponse": {
+        "dto.ReportManifestResponse": {
             "type": "object",
             "properties": {
-                "buyer_id": {
+                "content_type": {
                     "type": "string"
                 },
-                "id": {
+                "file_name": {
                     "type": "string"
                 },
-                "product_id": {
+                "format": {
                     "type": "string"
                 },
-                "quantity": {
+                "generated_at": {
+                    "type": "string"
+                },
+                "rows": {
                     "type": "integer"
                 },
-                "status": {
+                "sha256": {
                     "type": "string"
                 },
-                "total_price": {
+                "size": {
                     "type": "integer"
                 }
             }
         },
-        "dto.CreateSettlementJobRequest": {
+        "dto.ResumeJobResponse": {
+            "type": "object",
+            "properties": {
+                "job_id": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.RollbackSettlementRunRequest": {
             "type": "object",
-            "required": [
-                "from",
-                "to"
-            ],
             "properties": {
                 "from": {
                     "type": "string"
@@ -362,54 +2645,335 @@ const docTemplate = `{
                 }
             }
         },
-        "dto.CreateSettlementJobResponse": {
+        "dto.SettlementProgressResponse": {
             "type": "object",
             "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "download_url": {
+                    "type": "string"
+                },
                 "job_id": {
                     "type": "string"
                 },
-                "message": {
+                "last_error": {
+                    "type": "string"
+                },
+                "next_retry_at": {
                     "type": "string"
                 },
+                "parent_job_id": {
+                    "type": "string"
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "progress": {
+                    "type": "integer"
+                },
                 "status": {
                     "type": "string"
+                },
+                "total": {
+                    "type": "integer"
                 }
             }
         },
-        "dto.ErrorResponse": {
+        "dto.SettlementResponse": {
             "type": "object",
             "properties": {
-                "error": {
+                "carried_in": {
+                    "type": "integer"
+                },
+                "carry_forward": {
+                    "type": "integer"
+                },
+                "chargeback_amount": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "date": {
+                    "type": "string"
+                },
+                "fee_amount": {
+                    "type": "integer"
+                },
+                "fee_mismatch": {
+                    "type": "boolean"
+                },
+                "generated_at": {
+                    "type": "string"
+                },
+                "gross_amount": {
+                    "type": "integer"
+                },
+                "merchant_id": {
+                    "type": "string"
+                },
+                "net_amount": {
+                    "type": "integer"
+                },
+                "payable_amount": {
+                    "type": "integer"
+                },
+                "plan_fee_amount": {
+                    "type": "integer"
+                },
+                "refund_amount": {
+                    "type": "integer"
+                },
+                "reversal_count": {
+                    "type": "integer"
+                },
+                "run_id": {
                     "type": "string"
+                },
+                "txn_count": {
+                    "type": "integer"
                 }
             }
         },
-        "dto.GetOrderResponse": {
+        "dto.SettlementRunResponse": {
             "type": "object",
             "properties": {
-                "buyer_id": {
+                "created_at": {
+                    "type": "string"
+                },
+                "exclude_merchant_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "merchant_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "run_id": {
+                    "type": "string"
+                },
+                "source_run_id": {
+                    "type": "string"
+                },
+                "statuses": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.SettlementRunVersionResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "current": {
+                    "type": "boolean"
+                },
+                "exclude_merchant_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "merchant_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "run_id": {
+                    "type": "string"
+                },
+                "settlement": {
+                    "$ref": "#/definitions/dto.SettlementResponse"
+                },
+                "source_run_id": {
+                    "type": "string"
+                },
+                "statuses": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.SettlementTotalsResponse": {
+            "type": "object",
+            "properties": {
+                "chargeback_amount": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "fee_amount": {
+                    "type": "integer"
+                },
+                "fee_mismatches": {
+                    "type": "integer"
+                },
+                "gross_amount": {
+                    "type": "integer"
+                },
+                "net_amount": {
+                    "type": "integer"
+                },
+                "payable_amount": {
+                    "type": "integer"
+                },
+                "plan_fee_amount": {
+                    "type": "integer"
+                },
+                "refund_amount": {
+                    "type": "integer"
+                },
+                "txn_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.TransactionImportError": {
+            "type": "object",
+            "properties": {
+                "error": {
                     "type": "string"
                 },
                 "id": {
                     "type": "string"
                 },
-                "product_id": {
+                "row": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.TransactionResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "created_at": {
                     "type": "string"
                 },
-                "quantity": {
+                "currency": {
+                    "type": "string"
+                },
+                "fee": {
                     "type": "integer"
                 },
-                "total_price": {
+                "fee_currency": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "merchant_id": {
+                    "type": "string"
+                },
+                "order_id": {
+                    "type": "string"
+                },
+                "paid_at": {
+                    "type": "string"
+                },
+                "reversal_amount": {
                     "type": "integer"
+                },
+                "reversed_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
                 }
             }
         },
-        "dto.SettlementProgressResponse": {
+        "dto.UpdateMerchantSettlementConfigRequest": {
+            "type": "object",
+            "required": [
+                "cutoff_hour",
+                "time_zone"
+            ],
+            "properties": {
+                "cutoff_hour": {
+                    "type": "integer",
+                    "maximum": 23,
+                    "minimum": 0
+                },
+                "time_zone": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.UpdatePayoutStatusRequest": {
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "failure_reason": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "SENT",
+                        "CONFIRMED",
+                        "FAILED"
+                    ]
+                }
+            }
+        },
+        "models.JobEvent": {
             "type": "object",
             "properties": {
+                "at": {
+                    "type": "string"
+                },
                 "download_url": {
                     "type": "string"
                 },
+                "error": {
+                    "type": "string"
+                },
                 "job_id": {
                     "type": "string"
                 },
@@ -424,6 +2988,9 @@ const docTemplate = `{
                 },
                 "total": {
                     "type": "integer"
+                },
+                "type": {
+                    "type": "string"
                 }
             }
         }
@@ -0,0 +1,52 @@
+package dto
+
+import "time"
+
+type FeePlanTierRequest struct {
+	MinVolume     int `json:"min_volume" binding:"min=0"`
+	PercentageBps int `json:"percentage_bps" binding:"min=0,max=10000"`
+	FixedFee      int `json:"fixed_fee" binding:"min=0"`
+}
+
+type CreateFeePlanRequest struct {
+	Name          string               `json:"name" binding:"required"`
+	PercentageBps int                  `json:"percentage_bps" binding:"min=0,max=10000"`
+	FixedFee      int                  `json:"fixed_fee" binding:"min=0"`
+	MinFee        *int                 `json:"min_fee" binding:"omitempty,min=0"`
+	MaxFee        *int                 `json:"max_fee" binding:"omitempty,min=0"`
+	Tiers         []FeePlanTierRequest `json:"tiers" binding:"omitempty,dive"`
+}
+
+type FeePlanTierResponse struct {
+	MinVolume     int `json:"min_volume"`
+	PercentageBps int `json:"percentage_bps"`
+	FixedFee      int `json:"fixed_fee"`
+}
+
+type FeePlanResponse struct {
+	ID            string                `json:"id"`
+	Name          string                `json:"name"`
+	PercentageBps int                   `json:"percentage_bps"`
+	FixedFee      int                   `json:"fixed_fee"`
+	MinFee        *int                  `json:"min_fee,omitempty"`
+	MaxFee        *int                  `json:"max_fee,omitempty"`
+	Tiers         []FeePlanTierResponse `json:"tiers"`
+	CreatedAt     time.Time             `json:"created_at"`
+	UpdatedAt     time.Time             `json:"updated_at"`
+}
+
+type AssignFeePlanRequest struct {
+	PlanID        string  `json:"plan_id" binding:"required"`
+	EffectiveFrom string  `json:"effective_from" binding:"required"`
+	EffectiveTo   *string `json:"effective_to"`
+}
+
+type MerchantFeePlanResponse struct {
+	MerchantID    string           `json:"merchant_id"`
+	PlanID        string           `json:"plan_id"`
+	EffectiveFrom string           `json:"effective_from"`
+	EffectiveTo   *string          `json:"effective_to,omitempty"`
+	Plan          *FeePlanResponse `json:"plan,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
@@ -1,13 +1,60 @@
 package dto
 
+import "time"
+
+// ReportManifestResponse describes the report a job wrote. SHA256 is the hex
+// digest of the file.
+type ReportManifestResponse struct {
+	FileName    string    `json:"file_name"`
+	Format      string    `json:"format"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	Rows        int       `json:"rows"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
 type JobStatusResponse struct {
-	JobID       string  `json:"job_id"`
-	Status      string  `json:"status"`
-	Progress    int     `json:"progress"`
-	Processed   int     `json:"processed"`
-	Total       int     `json:"total"`
-	ResultPath  *string `json:"result_path,omitempty"`
-	DownloadURL *string `json:"download_url,omitempty"`
+	JobID              string                  `json:"job_id"`
+	JobType            string                  `json:"job_type"`
+	ParentJobID        *string                 `json:"parent_job_id,omitempty"`
+	Aggregation        string                  `json:"aggregation"`
+	ReportFormat       string                  `json:"report_format"`
+	Status             string                  `json:"status"`
+	From               string                  `json:"from"`
+	To                 string                  `json:"to"`
+	MerchantIDs        []string                `json:"merchant_ids,omitempty"`
+	ExcludeMerchantIDs []string                `json:"exclude_merchant_ids,omitempty"`
+	Statuses           []string                `json:"statuses,omitempty"`
+	ReportOnly         bool                    `json:"report_only"`
+	Progress           int                     `json:"progress"`
+	Processed          int                     `json:"processed"`
+	Total              int                     `json:"total"`
+	Attempts           int                     `json:"attempts"`
+	LastError          *string                 `json:"last_error,omitempty"`
+	NextRetryAt        *time.Time              `json:"next_retry_at,omitempty"`
+	ResultPath         *string                 `json:"result_path,omitempty"`
+	Manifest           *ReportManifestResponse `json:"manifest,omitempty"`
+	DownloadURL        *string                 `json:"download_url,omitempty"`
+	CreatedAt          time.Time               `json:"created_at"`
+	UpdatedAt          time.Time               `json:"updated_at"`
+}
+
+type ListJobsRequest struct {
+	Status      []string `form:"status"`
+	JobType     string   `form:"job_type"`
+	CreatedFrom string   `form:"created_from"`
+	CreatedTo   string   `form:"created_to"`
+	From        string   `form:"from"`
+	To          string   `form:"to"`
+	Cursor      string   `form:"cursor"`
+	Sort        string   `form:"sort"`
+	Limit       int      `form:"limit"`
+}
+
+type ListJobsResponse struct {
+	Data       []JobStatusResponse `json:"data"`
+	NextCursor *string             `json:"next_cursor,omitempty"`
 }
 
 type CancelJobResponse struct {
@@ -15,3 +62,9 @@ type CancelJobResponse struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
 }
+
+type ResumeJobResponse struct {
+	JobID   string `json:"job_id"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,59 @@
+package dto
+
+import "time"
+
+type LedgerBalancesRequest struct {
+	AccountID  string `form:"account_id"`
+	MerchantID string `form:"merchant_id"`
+	Currency   string `form:"currency"`
+}
+
+type LedgerBalanceResponse struct {
+	AccountID string `json:"account_id"`
+	Type      string `json:"type"`
+	Currency  string `json:"currency"`
+	Debit     int    `json:"debit"`
+	Credit    int    `json:"credit"`
+	Balance   int    `json:"balance"`
+}
+
+type ListLedgerJournalsRequest struct {
+	Kind      string `form:"kind" binding:"omitempty,oneof=PAYMENT FEE REFUND CHARGEBACK SETTLEMENT PAYOUT PAYOUT_REVERSAL"`
+	SourceID  string `form:"source_id"`
+	AccountID string `form:"account_id"`
+	Cursor    string `form:"cursor"`
+	Limit     int    `form:"limit"`
+}
+
+type LedgerEntryResponse struct {
+	AccountID string `json:"account_id"`
+	Currency  string `json:"currency"`
+	Debit     int    `json:"debit"`
+	Credit    int    `json:"credit"`
+}
+
+type LedgerJournalResponse struct {
+	ID        string                `json:"id"`
+	Kind      string                `json:"kind"`
+	SourceID  string                `json:"source_id"`
+	Reference string                `json:"reference"`
+	PostedAt  time.Time             `json:"posted_at"`
+	Entries   []LedgerEntryResponse `json:"entries"`
+	CreatedAt time.Time             `json:"created_at"`
+}
+
+type ListLedgerJournalsResponse struct {
+	Data       []LedgerJournalResponse `json:"data"`
+	NextCursor *string                 `json:"next_cursor,omitempty"`
+}
+
+type LedgerImbalanceResponse struct {
+	JournalID string `json:"journal_id"`
+	Currency  string `json:"currency"`
+	Total     int    `json:"total"`
+}
+
+type LedgerInvariantsResponse struct {
+	Balanced   bool                      `json:"balanced"`
+	Imbalances []LedgerImbalanceResponse `json:"imbalances"`
+}
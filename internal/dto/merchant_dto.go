@@ -0,0 +1,16 @@
+package dto
+
+import "time"
+
+type UpdateMerchantSettlementConfigRequest struct {
+	TimeZone   string `json:"time_zone" binding:"required"`
+	CutoffHour *int   `json:"cutoff_hour" binding:"required,min=0,max=23"`
+}
+
+type MerchantSettlementConfigResponse struct {
+	MerchantID string     `json:"merchant_id"`
+	TimeZone   string     `json:"time_zone"`
+	CutoffHour int        `json:"cutoff_hour"`
+	Default    bool       `json:"default"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
@@ -0,0 +1,53 @@
+package dto
+
+import "time"
+
+type GeneratePayoutsRequest struct {
+	PayoutDate  string   `json:"payout_date"`
+	MerchantIDs []string `json:"merchant_ids" binding:"omitempty,dive,required"`
+	MinAmount   *int     `json:"min_amount" binding:"omitempty,min=0"`
+}
+
+type UpdatePayoutStatusRequest struct {
+	Status        string  `json:"status" binding:"required,oneof=SENT CONFIRMED FAILED"`
+	Reference     *string `json:"reference"`
+	FailureReason *string `json:"failure_reason"`
+}
+
+type PayoutItemResponse struct {
+	Date   string `json:"date"`
+	Amount int    `json:"amount"`
+}
+
+type PayoutResponse struct {
+	ID              string               `json:"id"`
+	MerchantID      string               `json:"merchant_id"`
+	Currency        string               `json:"currency"`
+	PayoutDate      string               `json:"payout_date"`
+	Amount          int                  `json:"amount"`
+	SettlementCount int                  `json:"settlement_count"`
+	Status          string               `json:"status"`
+	Reference       *string              `json:"reference,omitempty"`
+	FailureReason   *string              `json:"failure_reason,omitempty"`
+	SentAt          *time.Time           `json:"sent_at,omitempty"`
+	ConfirmedAt     *time.Time           `json:"confirmed_at,omitempty"`
+	FailedAt        *time.Time           `json:"failed_at,omitempty"`
+	Items           []PayoutItemResponse `json:"items,omitempty"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
+}
+
+type ListPayoutsRequest struct {
+	MerchantID string `form:"merchant_id"`
+	Currency   string `form:"currency"`
+	Status     string `form:"status" binding:"omitempty,oneof=PENDING SENT CONFIRMED FAILED"`
+	From       string `form:"from"`
+	To         string `form:"to"`
+	Cursor     string `form:"cursor"`
+	Limit      int    `form:"limit"`
+}
+
+type ListPayoutsResponse struct {
+	Data       []PayoutResponse `json:"data"`
+	NextCursor *string          `json:"next_cursor,omitempty"`
+}
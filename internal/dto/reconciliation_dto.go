@@ -0,0 +1,47 @@
+package dto
+
+import "time"
+
+type ReconcileRequest struct {
+	Format            string `form:"format" binding:"omitempty,oneof=CSV MT940"`
+	Currency          string `form:"currency"`
+	AmountTolerance   *int   `form:"amount_tolerance" binding:"omitempty,min=0"`
+	DateToleranceDays *int   `form:"date_tolerance_days" binding:"omitempty,min=0"`
+}
+
+type GetReconciliationRequest struct {
+	Status string `form:"status" binding:"omitempty,oneof=MATCHED PARTIAL UNMATCHED"`
+}
+
+type ReconciliationItemResponse struct {
+	Status         string  `json:"status"`
+	LineNo         *int    `json:"line_no,omitempty"`
+	ValueDate      *string `json:"value_date,omitempty"`
+	Amount         *int    `json:"amount,omitempty"`
+	Currency       string  `json:"currency"`
+	Reference      *string `json:"reference,omitempty"`
+	Description    *string `json:"description,omitempty"`
+	MatchKind      *string `json:"match_kind,omitempty"`
+	MatchID        *string `json:"match_id,omitempty"`
+	MerchantID     *string `json:"merchant_id,omitempty"`
+	ExpectedAmount *int    `json:"expected_amount,omitempty"`
+	ExpectedDate   *string `json:"expected_date,omitempty"`
+	Difference     int     `json:"difference"`
+	Reason         string  `json:"reason"`
+}
+
+type ReconciliationResponse struct {
+	ID                string                       `json:"id"`
+	Format            string                       `json:"format"`
+	FileName          string                       `json:"file_name"`
+	StatementFrom     string                       `json:"statement_from"`
+	StatementTo       string                       `json:"statement_to"`
+	AmountTolerance   int                          `json:"amount_tolerance"`
+	DateToleranceDays int                          `json:"date_tolerance_days"`
+	LineCount         int                          `json:"line_count"`
+	MatchedCount      int                          `json:"matched_count"`
+	PartialCount      int                          `json:"partial_count"`
+	UnmatchedCount    int                          `json:"unmatched_count"`
+	Items             []ReconciliationItemResponse `json:"items"`
+	CreatedAt         time.Time                    `json:"created_at"`
+}
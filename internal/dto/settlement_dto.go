@@ -1,8 +1,15 @@
 package dto
 
+import "time"
+
 type CreateSettlementJobRequest struct {
-	From string `json:"from" binding:"required"`
-	To   string `json:"to" binding:"required"`
+	From               string   `json:"from" binding:"required"`
+	To                 string   `json:"to" binding:"required"`
+	Aggregation        string   `json:"aggregation" binding:"omitempty,oneof=STREAM DATABASE"`
+	MerchantIDs        []string `json:"merchant_ids" binding:"omitempty,dive,required"`
+	ExcludeMerchantIDs []string `json:"exclude_merchant_ids" binding:"omitempty,dive,required"`
+	Statuses           []string `json:"statuses" binding:"omitempty,dive,required"`
+	ReportFormat       string   `json:"report_format" binding:"omitempty,oneof=CSV JSON NDJSON XLSX PARQUET"`
 }
 
 type CreateSettlementJobResponse struct {
@@ -12,10 +19,94 @@ type CreateSettlementJobResponse struct {
 }
 
 type SettlementProgressResponse struct {
-	JobID       string  `json:"job_id"`
-	Status      string  `json:"status"`
-	Progress    int     `json:"progress"`
-	Processed   int     `json:"processed"`
-	Total       int     `json:"total"`
-	DownloadURL *string `json:"download_url,omitempty"`
+	JobID       string     `json:"job_id"`
+	ParentJobID *string    `json:"parent_job_id,omitempty"`
+	Status      string     `json:"status"`
+	Progress    int        `json:"progress"`
+	Processed   int        `json:"processed"`
+	Total       int        `json:"total"`
+	Attempts    int        `json:"attempts"`
+	LastError   *string    `json:"last_error,omitempty"`
+	NextRetryAt *time.Time `json:"next_retry_at,omitempty"`
+	DownloadURL *string    `json:"download_url,omitempty"`
+}
+
+type SettlementResponse struct {
+	MerchantID       string     `json:"merchant_id"`
+	Date             string     `json:"date"`
+	Currency         string     `json:"currency"`
+	GrossAmount      int        `json:"gross_amount"`
+	FeeAmount        int        `json:"fee_amount"`
+	NetAmount        int        `json:"net_amount"`
+	TxnCount         int        `json:"txn_count"`
+	PlanFeeAmount    *int       `json:"plan_fee_amount"`
+	FeeMismatch      bool       `json:"fee_mismatch"`
+	RefundAmount     int        `json:"refund_amount"`
+	ChargebackAmount int        `json:"chargeback_amount"`
+	ReversalCount    int        `json:"reversal_count"`
+	CarriedIn        int        `json:"carried_in"`
+	PayableAmount    int        `json:"payable_amount"`
+	CarryForward     int        `json:"carry_forward"`
+	RunID            string     `json:"run_id"`
+	GeneratedAt      *time.Time `json:"generated_at,omitempty"`
+}
+
+type ListSettlementsRequest struct {
+	MerchantID  string `form:"merchant_id"`
+	Currency    string `form:"currency"`
+	From        string `form:"from"`
+	To          string `form:"to"`
+	RunID       string `form:"run_id"`
+	FeeMismatch *bool  `form:"fee_mismatch"`
+	Cursor      string `form:"cursor"`
+	Limit       int    `form:"limit"`
+}
+
+type SettlementTotalsResponse struct {
+	Currency         string `json:"currency"`
+	GrossAmount      int    `json:"gross_amount"`
+	FeeAmount        int    `json:"fee_amount"`
+	NetAmount        int    `json:"net_amount"`
+	TxnCount         int    `json:"txn_count"`
+	RefundAmount     int    `json:"refund_amount"`
+	ChargebackAmount int    `json:"chargeback_amount"`
+	PayableAmount    int    `json:"payable_amount"`
+	PlanFeeAmount    int    `json:"plan_fee_amount"`
+	FeeMismatches    int    `json:"fee_mismatches"`
+}
+
+type ListSettlementsResponse struct {
+	Data       []SettlementResponse       `json:"data"`
+	Totals     []SettlementTotalsResponse `json:"totals"`
+	NextCursor *string                    `json:"next_cursor,omitempty"`
+}
+
+type SettlementRunResponse struct {
+	RunID              string    `json:"run_id"`
+	JobID              *string   `json:"job_id,omitempty"`
+	Kind               string    `json:"kind"`
+	SourceRunID        *string   `json:"source_run_id,omitempty"`
+	From               string    `json:"from"`
+	To                 string    `json:"to"`
+	MerchantIDs        []string  `json:"merchant_ids,omitempty"`
+	ExcludeMerchantIDs []string  `json:"exclude_merchant_ids,omitempty"`
+	Statuses           []string  `json:"statuses,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+type SettlementRunVersionResponse struct {
+	SettlementRunResponse
+	Current    bool                `json:"current"`
+	Settlement *SettlementResponse `json:"settlement,omitempty"`
+}
+
+type ListSettlementRunsRequest struct {
+	MerchantID string `form:"merchant_id" binding:"required"`
+	Date       string `form:"date" binding:"required"`
+	Currency   string `form:"currency"`
+}
+
+type RollbackSettlementRunRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
 }
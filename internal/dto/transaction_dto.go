@@ -0,0 +1,56 @@
+package dto
+
+import "time"
+
+// CreateTransactionRequest is one transaction to record, as a JSON body or as
+// a row of a bulk import. It is validated by the service rather than by
+// binding tags, so bulk rows are checked the same way.
+type CreateTransactionRequest struct {
+	ID             string     `json:"id"`
+	OrderID        string     `json:"order_id"`
+	MerchantID     string     `json:"merchant_id"`
+	Amount         int        `json:"amount"`
+	Fee            int        `json:"fee"`
+	Currency       string     `json:"currency"`
+	FeeCurrency    string     `json:"fee_currency"`
+	Status         string     `json:"status"`
+	PaidAt         time.Time  `json:"paid_at"`
+	ReversalAmount int        `json:"reversal_amount"`
+	ReversedAt     *time.Time `json:"reversed_at"`
+}
+
+type TransactionResponse struct {
+	ID             string     `json:"id"`
+	OrderID        string     `json:"order_id"`
+	MerchantID     string     `json:"merchant_id"`
+	Amount         int        `json:"amount"`
+	Fee            int        `json:"fee"`
+	Currency       string     `json:"currency"`
+	FeeCurrency    string     `json:"fee_currency"`
+	Status         string     `json:"status"`
+	PaidAt         time.Time  `json:"paid_at"`
+	ReversalAmount int        `json:"reversal_amount"`
+	ReversedAt     *time.Time `json:"reversed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type ImportTransactionsRequest struct {
+	Format string `form:"format" binding:"omitempty,oneof=NDJSON CSV"`
+}
+
+// TransactionImportError is a row of a bulk import that was not recorded.
+// Row is the line of the file the row is on, counting a CSV header.
+type TransactionImportError struct {
+	Row   int    `json:"row"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+type ImportTransactionsResponse struct {
+	Total      int                      `json:"total"`
+	Created    int                      `json:"created"`
+	Duplicates int                      `json:"duplicates"`
+	Failed     int                      `json:"failed"`
+	Errors     []TransactionImportError `json:"errors"`
+}
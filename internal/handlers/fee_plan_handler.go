@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type FeePlanHandler struct {
+	FeePlanService *services.FeePlanService
+}
+
+func NewFeePlanHandler(feePlanService *services.FeePlanService) *FeePlanHandler {
+	return &FeePlanHandler{
+		FeePlanService: feePlanService,
+	}
+}
+
+func (h *FeePlanHandler) Register(r *gin.Engine) {
+	r.POST("/fee-plans", h.CreatePlan)
+	r.GET("/fee-plans", h.ListPlans)
+	r.GET("/fee-plans/:id", h.GetPlan)
+	r.POST("/merchants/:merchant_id/fee-plans", h.AssignPlan)
+	r.GET("/merchants/:merchant_id/fee-plans", h.ListMerchantPlans)
+}
+
+// CreatePlan godoc
+// @Summary Create Fee Plan
+// @Description Create a fee plan of a percentage (in basis points) plus a fixed fee per transaction, with optional volume tiers and a daily minimum/maximum fee
+// @Tags Fee Plan
+// @Accept json
+// @Produce json
+// @Param request body dto.CreateFeePlanRequest true "Fee plan"
+// @Success 201 {object} dto.FeePlanResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /fee-plans [post]
+func (h *FeePlanHandler) CreatePlan(c *gin.Context) {
+	var req dto.CreateFeePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.FeePlanService.CreatePlan(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidFeePlanRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, res)
+}
+
+// ListPlans godoc
+// @Summary List Fee Plans
+// @Description List every fee plan
+// @Tags Fee Plan
+// @Produce json
+// @Success 200 {array} dto.FeePlanResponse
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /fee-plans [get]
+func (h *FeePlanHandler) ListPlans(c *gin.Context) {
+	res, err := h.FeePlanService.ListPlans(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// GetPlan godoc
+// @Summary Get Fee Plan
+// @Description Get a fee plan by ID
+// @Tags Fee Plan
+// @Produce json
+// @Param id path string true "Fee plan ID"
+// @Success 200 {object} dto.FeePlanResponse
+// @Failure 404 {object} dto.ErrorResponse "FEE_PLAN_NOT_FOUND"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /fee-plans/{id} [get]
+func (h *FeePlanHandler) GetPlan(c *gin.Context) {
+	res, err := h.FeePlanService.GetPlan(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrFeePlanNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "FEE_PLAN_NOT_FOUND"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// AssignPlan godoc
+// @Summary Assign Fee Plan To Merchant
+// @Description Put a merchant on a fee plan from a date, optionally until a date. Assigning again with the same effective_from replaces that assignment.
+// @Tags Fee Plan
+// @Accept json
+// @Produce json
+// @Param merchant_id path string true "Merchant ID"
+// @Param request body dto.AssignFeePlanRequest true "Assignment"
+// @Success 201 {object} dto.MerchantFeePlanResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 404 {object} dto.ErrorResponse "FEE_PLAN_NOT_FOUND"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /merchants/{merchant_id}/fee-plans [post]
+func (h *FeePlanHandler) AssignPlan(c *gin.Context) {
+	var req dto.AssignFeePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.FeePlanService.AssignPlan(c.Request.Context(), c.Param("merchant_id"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFeePlanNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "FEE_PLAN_NOT_FOUND"})
+		case errors.Is(err, services.ErrInvalidFeePlanRequest):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, res)
+}
+
+// ListMerchantPlans godoc
+// @Summary List Merchant Fee Plans
+// @Description List the fee plans assigned to a merchant, most recent effective date first
+// @Tags Fee Plan
+// @Produce json
+// @Param merchant_id path string true "Merchant ID"
+// @Success 200 {array} dto.MerchantFeePlanResponse
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /merchants/{merchant_id}/fee-plans [get]
+func (h *FeePlanHandler) ListMerchantPlans(c *gin.Context) {
+	res, err := h.FeePlanService.ListMerchantPlans(c.Request.Context(), c.Param("merchant_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
@@ -1,40 +1,52 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
-	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/models"
 	"github.com/banggibima/be-assignment/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
 type JobHandler struct {
-	JobService *services.JobService
+	JobService      *services.JobService
+	JobEventService *services.JobEventService
 }
 
-func NewJobHandler(jobService *services.JobService) *JobHandler {
+func NewJobHandler(jobService *services.JobService, jobEventService *services.JobEventService) *JobHandler {
 	return &JobHandler{
-		JobService: jobService,
+		JobService:      jobService,
+		JobEventService: jobEventService,
 	}
 }
 
 func (h *JobHandler) Register(r *gin.Engine) {
+	r.GET("/jobs", h.ListJobs)
+	r.GET("/jobs/dead-letter", h.ListDeadLetter)
 	r.GET("/jobs/:id", h.GetJob)
+	r.GET("/jobs/:id/events", h.StreamJobEvents)
 	r.POST("/jobs/:id/cancel", h.CancelJob)
+	r.POST("/jobs/:id/resume", h.ResumeJob)
 	r.POST("/jobs/settlement", h.StartJob)
 	r.GET("/downloads/:job_id", h.Download)
+	r.GET("/downloads/:job_id/manifest", h.GetManifest)
 }
 
 // StartJob godoc
 // @Summary Create Settlement Job
-// @Description Create a new settlement job
+// @Description Create a new settlement job. A job whose statuses leave out some settlement statuses is report-only: it writes its report but does not replace settlements or post to the ledger.
 // @Tags Job
 // @Accept json
 // @Produce json
 // @Param request body dto.CreateSettlementJobRequest true "Job request"
 // @Success 202 {object} dto.CreateSettlementJobResponse
 // @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 409 {object} dto.ErrorResponse "LATER_SETTLEMENTS_EXIST"
 // @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
 // @Router /jobs/settlement [post]
 func (h *JobHandler) StartJob(c *gin.Context) {
@@ -46,13 +58,58 @@ func (h *JobHandler) StartJob(c *gin.Context) {
 
 	res, err := h.JobService.CreateJob(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, services.ErrInvalidJobRequest):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrLaterSettlementsExist):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
 	c.JSON(http.StatusAccepted, res)
 }
 
+// ListJobs godoc
+// @Summary List Jobs
+// @Description Search jobs by status, type, creation time and settlement range with cursor pagination
+// @Tags Job
+// @Produce json
+// @Param status query []string false "Job status, repeatable (QUEUED, RUNNING, DONE, CANCELLED, FAILED, DEAD)" collectionFormat(multi)
+// @Param job_type query string false "Job type, e.g. SETTLEMENT"
+// @Param created_from query string false "Created at or after (RFC 3339 or YYYY-MM-DD)"
+// @Param created_to query string false "Created before (RFC 3339), or on or before (YYYY-MM-DD)"
+// @Param from query string false "Settlement range starts on or after (YYYY-MM-DD)"
+// @Param to query string false "Settlement range ends on or before (YYYY-MM-DD)"
+// @Param cursor query string false "Cursor from a previous page"
+// @Param sort query string false "Sort by creation time: asc or desc (default desc)"
+// @Param limit query int false "Page size, 1-100 (default 20)"
+// @Success 200 {object} dto.ListJobsResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /jobs [get]
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	var req dto.ListJobsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.JobService.ListJobs(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidJobQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
 // GetJob godoc
 // @Summary Get Job Status
 // @Description Get the status of a specific job
@@ -78,6 +135,19 @@ func (h *JobHandler) GetJob(c *gin.Context) {
 		"progress":  job.Progress,
 		"processed": job.Processed,
 		"total":     job.Total,
+		"attempts":  job.Attempts,
+	}
+
+	if job.ParentJobID != nil {
+		resp["parent_job_id"] = *job.ParentJobID
+	}
+
+	if job.LastError != nil {
+		resp["last_error"] = *job.LastError
+	}
+
+	if job.NextRetryAt != nil {
+		resp["next_retry_at"] = *job.NextRetryAt
 	}
 
 	if job.DownloadURL != nil {
@@ -87,6 +157,104 @@ func (h *JobHandler) GetJob(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// ListDeadLetter godoc
+// @Summary List Dead-Letter Jobs
+// @Description List jobs that exhausted their retries. Use POST /jobs/{id}/resume to requeue one.
+// @Tags Job
+// @Produce json
+// @Param limit query int false "Maximum number of jobs (default 100)"
+// @Success 200 {array} dto.JobStatusResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /jobs/dead-letter [get]
+func (h *JobHandler) ListDeadLetter(c *gin.Context) {
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	res, err := h.JobService.ListDeadLetter(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// StreamJobEvents godoc
+// @Summary Stream Job Events
+// @Description Stream progress, status transitions and the final download link of a job as Server-Sent Events. The first event is the current state; the stream ends when the job is DONE, CANCELLED or DEAD.
+// @Tags Job
+// @Produce text/event-stream
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.JobEvent
+// @Failure 404 {object} dto.ErrorResponse "JOB_NOT_FOUND"
+// @Router /jobs/{id}/events [get]
+func (h *JobHandler) StreamJobEvents(c *gin.Context) {
+	jobID := c.Param("id")
+
+	// Subscribe before reading the snapshot so no transition is missed.
+	events, unsubscribe := h.JobEventService.Subscribe(jobID)
+	defer unsubscribe()
+
+	job, err := h.JobService.GetJobStatus(c.Request.Context(), jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "JOB_NOT_FOUND"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent("status", models.JobEvent{
+		JobID:       job.JobID,
+		Type:        "status",
+		Status:      job.Status,
+		Processed:   job.Processed,
+		Total:       job.Total,
+		Progress:    job.Progress,
+		Error:       job.LastError,
+		DownloadURL: job.DownloadURL,
+		At:          time.Now(),
+	})
+	c.Writer.Flush()
+
+	if isFinalJobStatus(job.Status) {
+		return
+	}
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event := <-events:
+			c.SSEvent(event.Type, event)
+			c.Writer.Flush()
+			if isFinalJobStatus(event.Status) {
+				return
+			}
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+func isFinalJobStatus(status string) bool {
+	return status == "DONE" || status == "CANCELLED" || status == "DEAD"
+}
+
 // CancelJob godoc
 // @Summary Cancel Job
 // @Description Cancel a running job
@@ -108,18 +276,111 @@ func (h *JobHandler) CancelJob(c *gin.Context) {
 	c.JSON(http.StatusOK, res)
 }
 
+// ResumeJob godoc
+// @Summary Resume Job
+// @Description Re-queue a cancelled, failed or dead-letter job so it continues from its last checkpoint
+// @Tags Job
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 202 {object} dto.ResumeJobResponse
+// @Failure 404 {object} dto.ResumeJobResponse "Job not found or not resumable"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /jobs/{id}/resume [post]
+func (h *JobHandler) ResumeJob(c *gin.Context) {
+	jobID := c.Param("id")
+
+	res, err := h.JobService.ResumeJob(c.Request.Context(), jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if res.Status == "NOT_FOUND" {
+		c.JSON(http.StatusNotFound, res)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, res)
+}
+
 // Download godoc
 // @Summary Download Job Result
-// @Description Download CSV file of completed job
+// @Description Download the settlement report of a completed job. It is sent in the format chosen when the job was created unless Accept asks for another one: text/csv, application/json, application/x-ndjson, application/vnd.openxmlformats-officedocument.spreadsheetml.sheet (XLSX) or application/vnd.apache.parquet. X-Checksum-SHA256 carries the hex SHA-256 digest of the file.
 // @Tags Job
-// @Produce octet-stream
+// @Produce text/csv
+// @Produce json
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce application/vnd.apache.parquet
 // @Param job_id path string true "Job ID"
 // @Success 200 {file} string
+// @Failure 404 {object} dto.ErrorResponse "JOB_NOT_FOUND or REPORT_NOT_FOUND"
+// @Failure 406 {object} dto.ErrorResponse "UNSUPPORTED_REPORT_FORMAT"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
 // @Router /downloads/{job_id} [get]
 func (h *JobHandler) Download(c *gin.Context) {
 	jobID := c.Param("job_id")
-	filename := jobID + ".csv"
-	path := filepath.Join("/tmp/settlements", filename)
 
-	c.File(path)
+	job, err := h.JobService.GetJobStatus(c.Request.Context(), jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "JOB_NOT_FOUND"})
+		return
+	}
+
+	format := ""
+	if c.GetHeader("Accept") != "" {
+		format = services.ReportFormatOf(c.NegotiateFormat(services.ReportContentTypes(job.ReportFormat)...))
+		if format == "" {
+			c.JSON(http.StatusNotAcceptable, gin.H{"error": "UNSUPPORTED_REPORT_FORMAT"})
+			return
+		}
+	}
+
+	report, err := h.JobService.OpenReport(c.Request.Context(), jobID, format)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrJobNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "JOB_NOT_FOUND"})
+		case errors.Is(err, services.ErrReportNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "REPORT_NOT_FOUND"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	defer report.Body.Close()
+
+	size := int64(-1)
+	headers := map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", report.FileName),
+	}
+	if report.Manifest != nil {
+		size = report.Manifest.Size
+		headers["X-Checksum-SHA256"] = report.Manifest.SHA256
+	}
+
+	c.DataFromReader(http.StatusOK, size, report.ContentType, report.Body, headers)
+}
+
+// GetManifest godoc
+// @Summary Get Job Result Manifest
+// @Description Get the manifest of the settlement report a completed job wrote: file name, format, size, row count, generation time and SHA-256 digest
+// @Tags Job
+// @Produce json
+// @Param job_id path string true "Job ID"
+// @Success 200 {object} dto.ReportManifestResponse
+// @Failure 404 {object} dto.ErrorResponse "JOB_NOT_FOUND or REPORT_NOT_FOUND"
+// @Router /downloads/{job_id}/manifest [get]
+func (h *JobHandler) GetManifest(c *gin.Context) {
+	job, err := h.JobService.GetJobStatus(c.Request.Context(), c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "JOB_NOT_FOUND"})
+		return
+	}
+	if job.Status != "DONE" || job.Manifest == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "REPORT_NOT_FOUND"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job.Manifest)
 }
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type LedgerHandler struct {
+	LedgerService *services.LedgerService
+}
+
+func NewLedgerHandler(ledgerService *services.LedgerService) *LedgerHandler {
+	return &LedgerHandler{
+		LedgerService: ledgerService,
+	}
+}
+
+func (h *LedgerHandler) Register(r *gin.Engine) {
+	r.GET("/ledger/balances", h.GetBalances)
+	r.GET("/ledger/journals", h.ListJournals)
+	r.GET("/ledger/invariants", h.CheckInvariants)
+}
+
+// GetBalances godoc
+// @Summary Get Ledger Balances
+// @Description Get the debits, credits and balance per currency of ledger accounts. Accounts are platform:cash, platform:fee_revenue, merchant:{id}:pending and merchant:{id}:payable.
+// @Tags Ledger
+// @Produce json
+// @Param account_id query string false "Account ID"
+// @Param merchant_id query string false "Merchant ID, selects its pending and payable accounts"
+// @Param currency query string false "Currency"
+// @Success 200 {array} dto.LedgerBalanceResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /ledger/balances [get]
+func (h *LedgerHandler) GetBalances(c *gin.Context) {
+	var req dto.LedgerBalancesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.LedgerService.GetBalances(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// ListJournals godoc
+// @Summary List Ledger Journals
+// @Description Search ledger journals with their entries by kind, source and account with cursor pagination
+// @Tags Ledger
+// @Produce json
+// @Param kind query string false "PAYMENT, FEE, REFUND, CHARGEBACK, SETTLEMENT, PAYOUT or PAYOUT_REVERSAL"
+// @Param source_id query string false "Transaction, settlement run or payout ID"
+// @Param account_id query string false "Account ID"
+// @Param cursor query string false "Cursor from a previous page"
+// @Param limit query int false "Page size, 1-500 (default 100)"
+// @Success 200 {object} dto.ListLedgerJournalsResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /ledger/journals [get]
+func (h *LedgerHandler) ListJournals(c *gin.Context) {
+	var req dto.ListLedgerJournalsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.LedgerService.ListJournals(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidLedgerRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// CheckInvariants godoc
+// @Summary Check Ledger Invariants
+// @Description Check that every ledger journal sums to zero per currency and list the ones that do not
+// @Tags Ledger
+// @Produce json
+// @Success 200 {object} dto.LedgerInvariantsResponse
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /ledger/invariants [get]
+func (h *LedgerHandler) CheckInvariants(c *gin.Context) {
+	res, err := h.LedgerService.CheckInvariants(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
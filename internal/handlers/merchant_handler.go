@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type MerchantHandler struct {
+	MerchantService *services.MerchantService
+}
+
+func NewMerchantHandler(merchantService *services.MerchantService) *MerchantHandler {
+	return &MerchantHandler{
+		MerchantService: merchantService,
+	}
+}
+
+func (h *MerchantHandler) Register(r *gin.Engine) {
+	r.GET("/merchants/:merchant_id/settlement-config", h.GetSettlementConfig)
+	r.PUT("/merchants/:merchant_id/settlement-config", h.UpdateSettlementConfig)
+	r.DELETE("/merchants/:merchant_id/settlement-config", h.ResetSettlementConfig)
+}
+
+// GetSettlementConfig godoc
+// @Summary Get Merchant Settlement Config
+// @Description Get the time zone and cut-off hour that define a merchant's settlement day. Merchants without their own config get the default one with default=true.
+// @Tags Merchant
+// @Produce json
+// @Param merchant_id path string true "Merchant ID"
+// @Success 200 {object} dto.MerchantSettlementConfigResponse
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /merchants/{merchant_id}/settlement-config [get]
+func (h *MerchantHandler) GetSettlementConfig(c *gin.Context) {
+	res, err := h.MerchantService.GetSettlementConfig(c.Request.Context(), c.Param("merchant_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// UpdateSettlementConfig godoc
+// @Summary Update Merchant Settlement Config
+// @Description Set a merchant's IANA time zone and cut-off hour (0-23). Payments from the cut-off hour onwards belong to the next settlement day.
+// @Tags Merchant
+// @Accept json
+// @Produce json
+// @Param merchant_id path string true "Merchant ID"
+// @Param request body dto.UpdateMerchantSettlementConfigRequest true "Settlement config"
+// @Success 200 {object} dto.MerchantSettlementConfigResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /merchants/{merchant_id}/settlement-config [put]
+func (h *MerchantHandler) UpdateSettlementConfig(c *gin.Context) {
+	var req dto.UpdateMerchantSettlementConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.MerchantService.UpdateSettlementConfig(c.Request.Context(), c.Param("merchant_id"), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMerchantRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// ResetSettlementConfig godoc
+// @Summary Reset Merchant Settlement Config
+// @Description Remove a merchant's own settlement config so it uses the default time zone and cut-off hour again
+// @Tags Merchant
+// @Produce json
+// @Param merchant_id path string true "Merchant ID"
+// @Success 200 {object} dto.MerchantSettlementConfigResponse
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /merchants/{merchant_id}/settlement-config [delete]
+func (h *MerchantHandler) ResetSettlementConfig(c *gin.Context) {
+	res, err := h.MerchantService.ResetSettlementConfig(c.Request.Context(), c.Param("merchant_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
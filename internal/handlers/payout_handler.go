@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PayoutHandler struct {
+	PayoutService *services.PayoutService
+}
+
+func NewPayoutHandler(payoutService *services.PayoutService) *PayoutHandler {
+	return &PayoutHandler{
+		PayoutService: payoutService,
+	}
+}
+
+func (h *PayoutHandler) Register(r *gin.Engine) {
+	r.POST("/payouts/generate", h.GeneratePayouts)
+	r.GET("/payouts", h.ListPayouts)
+	r.GET("/payouts/:id", h.GetPayout)
+	r.POST("/payouts/:id/status", h.UpdatePayoutStatus)
+}
+
+// GeneratePayouts godoc
+// @Summary Generate Payouts
+// @Description Batch the settlements dated before the payout date that are not paid out yet into one PENDING payout per merchant and currency. Balances below the minimum amount wait for a later payout date.
+// @Tags Payout
+// @Accept json
+// @Produce json
+// @Param request body dto.GeneratePayoutsRequest true "Payout date (default today), merchants and minimum amount override"
+// @Success 201 {array} dto.PayoutResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /payouts/generate [post]
+func (h *PayoutHandler) GeneratePayouts(c *gin.Context) {
+	var req dto.GeneratePayoutsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.PayoutService.GeneratePayouts(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPayoutRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, res)
+}
+
+// ListPayouts godoc
+// @Summary List Payouts
+// @Description Search payouts by merchant, currency, status and payout date range with cursor pagination
+// @Tags Payout
+// @Produce json
+// @Param merchant_id query string false "Merchant ID"
+// @Param currency query string false "Currency"
+// @Param status query string false "PENDING, SENT, CONFIRMED or FAILED"
+// @Param from query string false "Payout date on or after (YYYY-MM-DD)"
+// @Param to query string false "Payout date on or before (YYYY-MM-DD)"
+// @Param cursor query string false "Cursor from a previous page"
+// @Param limit query int false "Page size, 1-500 (default 100)"
+// @Success 200 {object} dto.ListPayoutsResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /payouts [get]
+func (h *PayoutHandler) ListPayouts(c *gin.Context) {
+	var req dto.ListPayoutsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.PayoutService.ListPayouts(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPayoutRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// GetPayout godoc
+// @Summary Get Payout
+// @Description Get a payout with the settlements it pays out
+// @Tags Payout
+// @Produce json
+// @Param id path string true "Payout ID"
+// @Success 200 {object} dto.PayoutResponse
+// @Failure 404 {object} dto.ErrorResponse "PAYOUT_NOT_FOUND"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /payouts/{id} [get]
+func (h *PayoutHandler) GetPayout(c *gin.Context) {
+	res, err := h.PayoutService.GetPayout(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrPayoutNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "PAYOUT_NOT_FOUND"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// UpdatePayoutStatus godoc
+// @Summary Update Payout Status
+// @Description Move a payout from PENDING to SENT or FAILED, or from SENT to CONFIRMED or FAILED. FAILED requires a failure_reason and releases the settlements to the next payout generation.
+// @Tags Payout
+// @Accept json
+// @Produce json
+// @Param id path string true "Payout ID"
+// @Param request body dto.UpdatePayoutStatusRequest true "New status"
+// @Success 200 {object} dto.PayoutResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 404 {object} dto.ErrorResponse "PAYOUT_NOT_FOUND"
+// @Failure 409 {object} dto.ErrorResponse "INVALID_PAYOUT_TRANSITION"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /payouts/{id}/status [post]
+func (h *PayoutHandler) UpdatePayoutStatus(c *gin.Context) {
+	var req dto.UpdatePayoutStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.PayoutService.UpdatePayoutStatus(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPayoutNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "PAYOUT_NOT_FOUND"})
+		case errors.Is(err, services.ErrInvalidPayoutTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidPayoutRequest):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
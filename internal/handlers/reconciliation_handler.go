@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ReconciliationHandler struct {
+	ReconciliationService *services.ReconciliationService
+}
+
+func NewReconciliationHandler(reconciliationService *services.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		ReconciliationService: reconciliationService,
+	}
+}
+
+func (h *ReconciliationHandler) Register(r *gin.Engine) {
+	r.POST("/reconciliations", h.Reconcile)
+	r.GET("/reconciliations/:id", h.GetReconciliation)
+}
+
+// Reconcile godoc
+// @Summary Reconcile Bank Statement
+// @Description Upload a bank statement (CSV or MT940) and match its debits against sent payouts and its credits against settlement net amounts by reference, amount and value date. Returns the report of matched, partially matched and unmatched items.
+// @Tags Reconciliation
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Bank statement"
+// @Param format formData string false "CSV or MT940 (default detected from the file)"
+// @Param currency formData string false "Currency of lines without one (default IDR)"
+// @Param amount_tolerance formData int false "Largest amount difference that still matches"
+// @Param date_tolerance_days formData int false "Largest value date difference in days that still matches"
+// @Success 201 {object} dto.ReconciliationResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /reconciliations [post]
+func (h *ReconciliationHandler) Reconcile(c *gin.Context) {
+	var req dto.ReconcileRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.ReconciliationService.Reconcile(c.Request.Context(), header.Filename, content, req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidBankStatement) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, res)
+}
+
+// GetReconciliation godoc
+// @Summary Get Reconciliation
+// @Description Get a saved reconciliation report, optionally only its items of one status
+// @Tags Reconciliation
+// @Produce json
+// @Param id path string true "Reconciliation ID"
+// @Param status query string false "MATCHED, PARTIAL or UNMATCHED"
+// @Success 200 {object} dto.ReconciliationResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 404 {object} dto.ErrorResponse "RECONCILIATION_NOT_FOUND"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /reconciliations/{id} [get]
+func (h *ReconciliationHandler) GetReconciliation(c *gin.Context) {
+	var req dto.GetReconciliationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.ReconciliationService.GetReconciliation(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		if errors.Is(err, services.ErrReconciliationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "RECONCILIATION_NOT_FOUND"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type SettlementHandler struct {
+	SettlementService *services.SettlementService
+}
+
+func NewSettlementHandler(settlementService *services.SettlementService) *SettlementHandler {
+	return &SettlementHandler{
+		SettlementService: settlementService,
+	}
+}
+
+func (h *SettlementHandler) Register(r *gin.Engine) {
+	r.GET("/settlements", h.ListSettlements)
+	r.GET("/settlements/:merchant_id/:date", h.GetSettlement)
+}
+
+// ListSettlements godoc
+// @Summary List Settlements
+// @Description Search settlements by merchant, date range and run with cursor pagination. Totals cover every matching settlement, not only the page, one entry per currency.
+// @Tags Settlement
+// @Produce json
+// @Param merchant_id query string false "Merchant ID"
+// @Param currency query string false "Currency"
+// @Param from query string false "Settlement date on or after (YYYY-MM-DD)"
+// @Param to query string false "Settlement date on or before (YYYY-MM-DD)"
+// @Param run_id query string false "Run that produced the settlement"
+// @Param fee_mismatch query bool false "Only settlements whose plan fee differs from the recorded fee (true) or matches it (false)"
+// @Param cursor query string false "Cursor from a previous page"
+// @Param limit query int false "Page size, 1-500 (default 100)"
+// @Success 200 {object} dto.ListSettlementsResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /settlements [get]
+func (h *SettlementHandler) ListSettlements(c *gin.Context) {
+	var req dto.ListSettlementsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.SettlementService.ListSettlements(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSettlementQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// GetSettlement godoc
+// @Summary Get Settlement
+// @Description Get the settlement of a merchant on a date
+// @Tags Settlement
+// @Produce json
+// @Param merchant_id path string true "Merchant ID"
+// @Param date path string true "Settlement date (YYYY-MM-DD)"
+// @Param currency query string false "Currency (default IDR)"
+// @Success 200 {object} dto.SettlementResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 404 {object} dto.ErrorResponse "SETTLEMENT_NOT_FOUND"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /settlements/{merchant_id}/{date} [get]
+func (h *SettlementHandler) GetSettlement(c *gin.Context) {
+	res, err := h.SettlementService.GetSettlement(c.Request.Context(), c.Param("merchant_id"), c.Param("date"), c.Query("currency"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSettlementNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "SETTLEMENT_NOT_FOUND"})
+		case errors.Is(err, services.ErrInvalidSettlementQuery):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type SettlementRunHandler struct {
+	SettlementRunService *services.SettlementRunService
+}
+
+func NewSettlementRunHandler(settlementRunService *services.SettlementRunService) *SettlementRunHandler {
+	return &SettlementRunHandler{
+		SettlementRunService: settlementRunService,
+	}
+}
+
+func (h *SettlementRunHandler) Register(r *gin.Engine) {
+	r.GET("/settlement-runs", h.ListRuns)
+	r.POST("/settlement-runs/:id/rollback", h.Rollback)
+}
+
+// ListRuns godoc
+// @Summary List Settlement Runs
+// @Description List every run that settled a merchant on a date, newest first, with the row each run produced
+// @Tags Settlement
+// @Produce json
+// @Param merchant_id query string true "Merchant ID"
+// @Param date query string true "Settlement date (YYYY-MM-DD)"
+// @Param currency query string false "Currency (default IDR)"
+// @Success 200 {array} dto.SettlementRunVersionResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /settlement-runs [get]
+func (h *SettlementRunHandler) ListRuns(c *gin.Context) {
+	var req dto.ListSettlementRunsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.SettlementRunService.ListRuns(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSettlementRunRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// Rollback godoc
+// @Summary Roll Back Settlements To A Run
+// @Description Replace the settlements of a date range with the rows of an earlier run. The range defaults to the run's whole range and must lie within it. Ranges whose merchants have later settlements in the same currency are refused.
+// @Tags Settlement
+// @Accept json
+// @Produce json
+// @Param id path string true "Run ID"
+// @Param request body dto.RollbackSettlementRunRequest false "Date range"
+// @Success 200 {object} dto.SettlementRunResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 404 {object} dto.ErrorResponse "SETTLEMENT_RUN_NOT_FOUND"
+// @Failure 409 {object} dto.ErrorResponse "LATER_SETTLEMENTS_EXIST"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /settlement-runs/{id}/rollback [post]
+func (h *SettlementRunHandler) Rollback(c *gin.Context) {
+	var req dto.RollbackSettlementRunRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	res, err := h.SettlementRunService.Rollback(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSettlementRunNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "SETTLEMENT_RUN_NOT_FOUND"})
+		case errors.Is(err, services.ErrInvalidSettlementRunRequest):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrLaterSettlementsExist):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type TransactionHandler struct {
+	TransactionService *services.TransactionService
+}
+
+func NewTransactionHandler(transactionService *services.TransactionService) *TransactionHandler {
+	return &TransactionHandler{
+		TransactionService: transactionService,
+	}
+}
+
+func (h *TransactionHandler) Register(r *gin.Engine) {
+	r.POST("/transactions", h.CreateTransaction)
+	r.POST("/transactions/bulk", h.ImportTransactions)
+	r.GET("/transactions/:id", h.GetTransaction)
+}
+
+// CreateTransaction godoc
+// @Summary Create Transaction
+// @Description Record a payment transaction of an existing order. Recording an ID again returns the stored transaction unchanged with 200.
+// @Tags Transaction
+// @Accept json
+// @Produce json
+// @Param request body dto.CreateTransactionRequest true "Transaction"
+// @Success 201 {object} dto.TransactionResponse
+// @Success 200 {object} dto.TransactionResponse "Already recorded"
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 404 {object} dto.ErrorResponse "ORDER_NOT_FOUND"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /transactions [post]
+func (h *TransactionHandler) CreateTransaction(c *gin.Context) {
+	var req dto.CreateTransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, created, err := h.TransactionService.CreateTransaction(c.Request.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTransaction):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrOrderNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "ORDER_NOT_FOUND"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if !created {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	c.JSON(http.StatusCreated, res)
+}
+
+// ImportTransactions godoc
+// @Summary Import Transactions
+// @Description Record transactions in bulk from the request body: NDJSON (one JSON transaction per line) or CSV (a header naming the JSON fields, timestamps in RFC 3339). Invalid rows and rows of unknown orders are reported by line and skipped; IDs recorded already are counted as duplicates.
+// @Tags Transaction
+// @Accept application/x-ndjson
+// @Accept text/csv
+// @Produce json
+// @Param format query string false "NDJSON or CSV (default from Content-Type, else NDJSON)"
+// @Success 200 {object} dto.ImportTransactionsResponse
+// @Failure 400 {object} dto.ErrorResponse "Bad Request"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /transactions/bulk [post]
+func (h *TransactionHandler) ImportTransactions(c *gin.Context) {
+	var req dto.ImportTransactionsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := req.Format
+	if format == "" {
+		format = "NDJSON"
+		if strings.Contains(c.ContentType(), "csv") {
+			format = "CSV"
+		}
+	}
+
+	res, err := h.TransactionService.ImportTransactions(c.Request.Context(), format, c.Request.Body)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidImport) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// GetTransaction godoc
+// @Summary Get Transaction
+// @Description Get a recorded transaction by ID
+// @Tags Transaction
+// @Produce json
+// @Param id path string true "Transaction ID"
+// @Success 200 {object} dto.TransactionResponse
+// @Failure 404 {object} dto.ErrorResponse "TRANSACTION_NOT_FOUND"
+// @Failure 500 {object} dto.ErrorResponse "Internal Server Error"
+// @Router /transactions/{id} [get]
+func (h *TransactionHandler) GetTransaction(c *gin.Context) {
+	res, err := h.TransactionService.GetTransaction(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrTransactionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "TRANSACTION_NOT_FOUND"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
@@ -21,42 +21,524 @@ type Order struct {
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// DefaultCurrency is the currency of transactions recorded before amounts
+// carried a currency.
+const DefaultCurrency = "IDR"
+
+// Kinds of settlement lines.
+const (
+	SettlementLinePayment    = "PAYMENT"
+	SettlementLineRefund     = "REFUND"
+	SettlementLineChargeback = "CHARGEBACK"
+)
+
+// TransactionStatusChargeback is the status of a transaction the buyer's bank
+// took back.
+const TransactionStatusChargeback = "CHARGEBACK"
+
+// ReversalStatuses are the transaction statuses that reverse all or part of
+// a payment.
+var ReversalStatuses = []string{"REFUNDED", "PARTIALLY_REFUNDED", TransactionStatusChargeback}
+
+// SettlementStatuses are the transaction statuses settlement jobs read when
+// they are not given any.
+var SettlementStatuses = append([]string{"PAID"}, ReversalStatuses...)
+
+// TransactionStatuses are every status a transaction can be recorded with.
+var TransactionStatuses = append([]string{"PENDING", "FAILED"}, SettlementStatuses...)
+
 type Transaction struct {
-	ID         string    `json:"id"`
-	OrderID    string    `json:"order_id"`
+	ID          string    `json:"id"`
+	OrderID     string    `json:"order_id"`
+	MerchantID  string    `json:"merchant_id"`
+	Amount      int       `json:"amount"`
+	Fee         int       `json:"fee"`
+	Currency    string    `json:"currency"`
+	FeeCurrency string    `json:"fee_currency"`
+	Status      string    `json:"status"`
+	PaidAt      time.Time `json:"paid_at"`
+	// ReversalAmount is the part of Amount refunded or charged back, at
+	// ReversedAt. It is the whole Amount for REFUNDED and CHARGEBACK.
+	ReversalAmount int        `json:"reversal_amount"`
+	ReversedAt     *time.Time `json:"reversed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// SettlementLine is one entry a transaction contributes to its merchant's
+// settlement: a PAYMENT on the settlement day it was paid, and a negative
+// REFUND or CHARGEBACK on the settlement day it was reversed.
+type SettlementLine struct {
+	Kind           string    `json:"kind"`
+	TransactionID  string    `json:"transaction_id"`
+	MerchantID     string    `json:"merchant_id"`
+	Currency       string    `json:"currency"`
+	Amount         int       `json:"amount"`
+	Fee            int       `json:"fee"`
+	OccurredAt     time.Time `json:"occurred_at"`
+	SettlementDate time.Time `json:"settlement_date"`
+}
+
+// SettlementLineCursor is the keyset position of a settlement line, which
+// lines are ordered by.
+type SettlementLineCursor struct {
+	OccurredAt    string
+	TransactionID string
+	Kind          string
+}
+
+// SettlementDay defines when a merchant's settlement day ends: at CutoffHour
+// o'clock in TimeZone. Payments from the cut-off onwards belong to the next
+// day, so a CutoffHour of 0 makes settlement days plain calendar days.
+type SettlementDay struct {
+	TimeZone   string `json:"time_zone"`
+	CutoffHour int    `json:"cutoff_hour"`
+}
+
+// MerchantSettlementConfig is the settlement day of one merchant. Merchants
+// without one use the default SettlementDay.
+type MerchantSettlementConfig struct {
 	MerchantID string    `json:"merchant_id"`
-	Amount     int       `json:"amount"`
-	Fee        int       `json:"fee"`
-	Status     string    `json:"status"`
-	PaidAt     time.Time `json:"paid_at"`
+	TimeZone   string    `json:"time_zone"`
+	CutoffHour int       `json:"cutoff_hour"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 type Settlement struct {
-	ID          string    `json:"id"`
-	MerchantID  string    `json:"merchant_id"`
-	Date        time.Time `json:"date"`
-	GrossAmount int       `json:"gross_amount"`
-	FeeAmount   int       `json:"fee_amount"`
-	NetAmount   int       `json:"net_amount"`
-	TxnCount    int       `json:"txn_count"`
-	UniqueRunID string    `json:"unique_run_id"`
-	GeneratedAt time.Time `json:"generated_at"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID               string    `json:"id"`
+	MerchantID       string    `json:"merchant_id"`
+	Date             time.Time `json:"date"`
+	Currency         string    `json:"currency"`
+	GrossAmount      int       `json:"gross_amount"`
+	FeeAmount        int       `json:"fee_amount"`
+	NetAmount        int       `json:"net_amount"`
+	TxnCount         int       `json:"txn_count"`
+	PlanFeeAmount    *int      `json:"plan_fee_amount"`
+	FeeMismatch      bool      `json:"fee_mismatch"`
+	RefundAmount     int       `json:"refund_amount"`
+	ChargebackAmount int       `json:"chargeback_amount"`
+	ReversalCount    int       `json:"reversal_count"`
+	CarriedIn        int       `json:"carried_in"`
+	PayableAmount    int       `json:"payable_amount"`
+	CarryForward     int       `json:"carry_forward"`
+	UniqueRunID      string    `json:"unique_run_id"`
+	GeneratedAt      time.Time `json:"generated_at"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// TransactionFilter narrows the transactions a settlement job reads. Empty
+// fields do not filter.
+type TransactionFilter struct {
+	MerchantIDs        []string `json:"merchant_ids,omitempty"`
+	ExcludeMerchantIDs []string `json:"exclude_merchant_ids,omitempty"`
+	Statuses           []string `json:"statuses,omitempty"`
+}
+
+type SettlementFilter struct {
+	MerchantID  string
+	Currency    string
+	From        string
+	To          string
+	RunID       string
+	FeeMismatch *bool
+	After       *SettlementCursor
+	Limit       int
+}
+
+type SettlementCursor struct {
+	Date       string
+	MerchantID string
+	Currency   string
+}
+
+type SettlementRun struct {
+	ID          string             `json:"id"`
+	JobID       *string            `json:"job_id"`
+	Kind        string             `json:"kind"`
+	SourceRunID *string            `json:"source_run_id"`
+	Filter      *TransactionFilter `json:"filter"`
+	From        string             `json:"from"`
+	To          string             `json:"to"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+// SettlementRunVersion is a run that covered a merchant and date, with the
+// settlement row it produced for them. Settlement is nil when the run had no
+// PAID transactions for that merchant and date.
+type SettlementRunVersion struct {
+	Run        SettlementRun `json:"run"`
+	Settlement *Settlement   `json:"settlement"`
+	Current    bool          `json:"current"`
 }
 
 type Job struct {
-	ID         string    `json:"id"`
-	JobID      string    `json:"job_id"`
-	Status     string    `json:"status"`
-	Processed  int       `json:"processed"`
-	Total      int       `json:"total"`
-	Progress   int       `json:"progress"`
-	From       string    `json:"from"`
-	To         string    `json:"to"`
-	ResultPath *string   `json:"result_path"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID           string             `json:"id"`
+	JobID        string             `json:"job_id"`
+	JobType      string             `json:"job_type"`
+	Aggregation  string             `json:"aggregation"`
+	Status       string             `json:"status"`
+	Processed    int                `json:"processed"`
+	Total        int                `json:"total"`
+	Progress     int                `json:"progress"`
+	From         string             `json:"from"`
+	To           string             `json:"to"`
+	ResultPath   *string            `json:"result_path"`
+	WorkerID     *string            `json:"worker_id"`
+	HeartbeatAt  *time.Time         `json:"heartbeat_at"`
+	Attempts     int                `json:"attempts"`
+	LastError    *string            `json:"last_error"`
+	RunAfter     *time.Time         `json:"run_after"`
+	Checkpoint   *JobCheckpoint     `json:"checkpoint"`
+	ParentJobID  *string            `json:"parent_job_id"`
+	Filter       *TransactionFilter `json:"filter"`
+	ReportFormat string             `json:"report_format"`
+	Manifest     *ReportManifest    `json:"manifest"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+}
+
+// Settlement report formats a job can write its result in.
+const (
+	ReportFormatCSV     = "CSV"
+	ReportFormatJSON    = "JSON"
+	ReportFormatNDJSON  = "NDJSON"
+	ReportFormatXLSX    = "XLSX"
+	ReportFormatParquet = "PARQUET"
+)
+
+// ReportManifest describes the report file a job wrote, so recipients can
+// check they received it intact.
+type ReportManifest struct {
+	FileName    string    `json:"file_name"`
+	Format      string    `json:"format"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	Rows        int       `json:"rows"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// JobFilter selects jobs for listing. Zero-valued fields do not filter.
+// After is a keyset cursor: only jobs strictly after it in the requested
+// order are returned.
+type JobFilter struct {
+	Statuses    []string
+	JobType     string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	From        string
+	To          string
+	After       *JobCursor
+	Desc        bool
+	Limit       int
+}
+
+// JobCursor is the position of a job in (created_at, id) order.
+type JobCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// RetryPolicy controls how failed jobs are retried: the n-th attempt is
+// delayed by BaseDelay * 2^(n-1), capped at MaxDelay, and a job that failed
+// MaxAttempts times is moved to DEAD.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// JobCheckpoint is the resumable state of a settlement job. The cursor is the
+// (paid_at, id) of the last transaction folded into Settlements, or the last
+// completed settlement day when aggregating in the database.
+type JobCheckpoint struct {
+	CursorPaidAt string                          `json:"cursor_paid_at"`
+	CursorID     string                          `json:"cursor_id"`
+	CursorKind   string                          `json:"cursor_kind,omitempty"`
+	Processed    int                             `json:"processed"`
+	Settlements  map[string]*SettlementAggregate `json:"settlements"`
+}
+
+// SettlementAggregate is the running total of one merchant, day and
+// currency, keyed merchant|date|currency in job checkpoints. GrossAmount is
+// net of refunds and chargebacks, so NetAmount can go negative. TxnCount
+// counts payments and ReversalCount refunds and chargebacks.
+// PlanFeeAmount, FeeMismatch and the carry-forward fields are only set right
+// before the settlement is saved.
+type SettlementAggregate struct {
+	GrossAmount      int  `json:"gross_amount"`
+	FeeAmount        int  `json:"fee_amount"`
+	NetAmount        int  `json:"net_amount"`
+	TxnCount         int  `json:"txn_count"`
+	RefundAmount     int  `json:"refund_amount,omitempty"`
+	ChargebackAmount int  `json:"chargeback_amount,omitempty"`
+	ReversalCount    int  `json:"reversal_count,omitempty"`
+	PlanFeeAmount    *int `json:"plan_fee_amount,omitempty"`
+	FeeMismatch      bool `json:"fee_mismatch,omitempty"`
+	CarriedIn        int  `json:"carried_in,omitempty"`
+	PayableAmount    int  `json:"payable_amount,omitempty"`
+	CarryForward     int  `json:"carry_forward,omitempty"`
+}
+
+// SettlementTotals sums the settlements of one currency.
+type SettlementTotals struct {
+	Currency         string
+	GrossAmount      int
+	FeeAmount        int
+	NetAmount        int
+	TxnCount         int
+	RefundAmount     int
+	ChargebackAmount int
+	PayableAmount    int
+	PlanFeeAmount    int
+	FeeMismatches    int
+}
+
+type FeePlan struct {
+	ID            string        `json:"id"`
+	Name          string        `json:"name"`
+	PercentageBps int           `json:"percentage_bps"`
+	FixedFee      int           `json:"fixed_fee"`
+	MinFee        *int          `json:"min_fee"`
+	MaxFee        *int          `json:"max_fee"`
+	Tiers         []FeePlanTier `json:"tiers"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// FeePlanTier replaces the base rate of its plan on days when a merchant's
+// gross volume reaches MinVolume.
+type FeePlanTier struct {
+	MinVolume     int `json:"min_volume"`
+	PercentageBps int `json:"percentage_bps"`
+	FixedFee      int `json:"fixed_fee"`
+}
+
+// MerchantFeePlan assigns a fee plan to a merchant from EffectiveFrom until
+// EffectiveTo, inclusive. A nil EffectiveTo has no end.
+type MerchantFeePlan struct {
+	MerchantID    string    `json:"merchant_id"`
+	PlanID        string    `json:"plan_id"`
+	EffectiveFrom string    `json:"effective_from"`
+	EffectiveTo   *string   `json:"effective_to"`
+	Plan          *FeePlan  `json:"plan"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// JobEvent is published whenever a job makes progress or changes status.
+// Events travel between instances over Postgres NOTIFY, so they stay small.
+type JobEvent struct {
+	JobID       string    `json:"job_id"`
+	Type        string    `json:"type"`
+	Status      string    `json:"status"`
+	Processed   int       `json:"processed"`
+	Total       int       `json:"total"`
+	Progress    int       `json:"progress"`
+	Error       *string   `json:"error,omitempty"`
+	DownloadURL *string   `json:"download_url,omitempty"`
+	At          time.Time `json:"at"`
+}
+
+// Payout moves the payable amount of a merchant's settlements in one currency
+// to the merchant on PayoutDate. It goes PENDING -> SENT -> CONFIRMED, or
+// FAILED before or after it is sent, which releases its settlements to a
+// later payout.
+type Payout struct {
+	ID              string       `json:"id"`
+	MerchantID      string       `json:"merchant_id"`
+	Currency        string       `json:"currency"`
+	PayoutDate      time.Time    `json:"payout_date"`
+	Amount          int          `json:"amount"`
+	SettlementCount int          `json:"settlement_count"`
+	Status          string       `json:"status"`
+	Reference       *string      `json:"reference"`
+	FailureReason   *string      `json:"failure_reason"`
+	SentAt          *time.Time   `json:"sent_at"`
+	ConfirmedAt     *time.Time   `json:"confirmed_at"`
+	FailedAt        *time.Time   `json:"failed_at"`
+	Items           []PayoutItem `json:"items,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// PayoutItem is the payable amount one settlement contributed to a payout.
+type PayoutItem struct {
+	PayoutID   string    `json:"payout_id"`
+	MerchantID string    `json:"merchant_id"`
+	Date       time.Time `json:"date"`
+	Currency   string    `json:"currency"`
+	Amount     int       `json:"amount"`
+}
+
+type PayoutFilter struct {
+	MerchantID string
+	Currency   string
+	Status     string
+	From       string
+	To         string
+	After      *PayoutCursor
+	Limit      int
+}
+
+type PayoutCursor struct {
+	PayoutDate string
+	ID         string
+}
+
+// Ledger accounts of the platform. Every merchant also has a pending account
+// for payments not settled yet and a payable account for settled amounts not
+// paid out yet, named by MerchantPendingAccount and MerchantPayableAccount.
+const (
+	LedgerAccountCash       = "platform:cash"
+	LedgerAccountFeeRevenue = "platform:fee_revenue"
+)
+
+func MerchantPendingAccount(merchantID string) string {
+	return "merchant:" + merchantID + ":pending"
+}
+
+func MerchantPayableAccount(merchantID string) string {
+	return "merchant:" + merchantID + ":payable"
+}
+
+// LedgerAccountType returns whether accountID is an ASSET, LIABILITY or
+// REVENUE account.
+func LedgerAccountType(accountID string) string {
+	switch accountID {
+	case LedgerAccountCash:
+		return "ASSET"
+	case LedgerAccountFeeRevenue:
+		return "REVENUE"
+	default:
+		return "LIABILITY"
+	}
+}
+
+// LedgerJournal is one balanced posting to the ledger: its entries sum to
+// zero per currency. Reference is unique per kind, so posting the same event
+// twice records it once. SourceID is the transaction, settlement run or
+// payout the journal comes from.
+type LedgerJournal struct {
+	ID        string        `json:"id"`
+	Kind      string        `json:"kind"`
+	SourceID  string        `json:"source_id"`
+	Reference string        `json:"reference"`
+	PostedAt  time.Time     `json:"posted_at"`
+	Entries   []LedgerEntry `json:"entries"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// LedgerEntry debits its account by a positive Amount and credits it by a
+// negative one.
+type LedgerEntry struct {
+	JournalID string `json:"journal_id"`
+	AccountID string `json:"account_id"`
+	Currency  string `json:"currency"`
+	Amount    int    `json:"amount"`
+}
+
+type LedgerBalance struct {
+	AccountID string `json:"account_id"`
+	Currency  string `json:"currency"`
+	Debit     int    `json:"debit"`
+	Credit    int    `json:"credit"`
+}
+
+// LedgerImbalance is a journal whose entries in Currency do not sum to zero.
+type LedgerImbalance struct {
+	JournalID string `json:"journal_id"`
+	Currency  string `json:"currency"`
+	Total     int    `json:"total"`
+}
+
+type LedgerJournalFilter struct {
+	Kind      string
+	SourceID  string
+	AccountID string
+	After     *LedgerJournalCursor
+	Limit     int
+}
+
+type LedgerJournalCursor struct {
+	PostedAt string
+	ID       string
+}
+
+// Bank statement formats a reconciliation reads.
+const (
+	BankStatementCSV   = "CSV"
+	BankStatementMT940 = "MT940"
+)
+
+// Reconciliation item statuses. A PARTIAL item was matched by reference but
+// its amount or value date is outside the tolerance.
+const (
+	ReconciliationMatched   = "MATCHED"
+	ReconciliationPartial   = "PARTIAL"
+	ReconciliationUnmatched = "UNMATCHED"
+)
+
+// BankStatementLine is one booking on the platform's bank statement. Credits
+// have a positive Amount and debits a negative one.
+type BankStatementLine struct {
+	LineNo      int
+	ValueDate   time.Time
+	Amount      int
+	Currency    string
+	Reference   string
+	Description string
+}
+
+// ReconciliationCandidate is a money movement the bank statement should
+// show: a sent payout as a debit, or a settlement's net amount as a credit.
+// Amount carries the sign it has on the statement.
+type ReconciliationCandidate struct {
+	Kind       string
+	ID         string
+	MerchantID string
+	Currency   string
+	Amount     int
+	Date       time.Time
+	Reference  string
+}
+
+type Reconciliation struct {
+	ID                string               `json:"id"`
+	Format            string               `json:"format"`
+	FileName          string               `json:"file_name"`
+	StatementFrom     time.Time            `json:"statement_from"`
+	StatementTo       time.Time            `json:"statement_to"`
+	AmountTolerance   int                  `json:"amount_tolerance"`
+	DateToleranceDays int                  `json:"date_tolerance_days"`
+	LineCount         int                  `json:"line_count"`
+	MatchedCount      int                  `json:"matched_count"`
+	PartialCount      int                  `json:"partial_count"`
+	UnmatchedCount    int                  `json:"unmatched_count"`
+	Items             []ReconciliationItem `json:"items"`
+	CreatedAt         time.Time            `json:"created_at"`
+}
+
+// ReconciliationItem is one line of a reconciliation report. Items of bank
+// statement lines have a LineNo; UNMATCHED items without one are payouts or
+// settlements missing from the statement.
+type ReconciliationItem struct {
+	ReconciliationID string     `json:"reconciliation_id"`
+	Position         int        `json:"position"`
+	Status           string     `json:"status"`
+	LineNo           *int       `json:"line_no"`
+	ValueDate        *time.Time `json:"value_date"`
+	Amount           *int       `json:"amount"`
+	Currency         string     `json:"currency"`
+	Reference        *string    `json:"reference"`
+	Description      *string    `json:"description"`
+	MatchKind        *string    `json:"match_kind"`
+	MatchID          *string    `json:"match_id"`
+	MerchantID       *string    `json:"merchant_id"`
+	ExpectedAmount   *int       `json:"expected_amount"`
+	ExpectedDate     *time.Time `json:"expected_date"`
+	Difference       int        `json:"difference"`
+	Reason           string     `json:"reason"`
 }
@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DatabaseFeePlanRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDatabaseFeePlanRepository(db *pgxpool.Pool) *DatabaseFeePlanRepository {
+	return &DatabaseFeePlanRepository{db: db}
+}
+
+func (r *DatabaseFeePlanRepository) Create(ctx context.Context, plan *models.FeePlan) error {
+	query := "INSERT INTO fee_plans (id, name, percentage_bps, fixed_fee, min_fee, max_fee, tiers, created_at, updated_at) "
+	query += "VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW()) "
+	query += "RETURNING created_at, updated_at"
+
+	return r.db.QueryRow(ctx, query, plan.ID, plan.Name, plan.PercentageBps, plan.FixedFee, plan.MinFee, plan.MaxFee, plan.Tiers).Scan(&plan.CreatedAt, &plan.UpdatedAt)
+}
+
+func (r *DatabaseFeePlanRepository) GetByID(ctx context.Context, id string) (*models.FeePlan, error) {
+	query := "SELECT " + feePlanColumns + " "
+	query += "FROM fee_plans WHERE id = $1"
+
+	plan, err := scanFeePlan(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func (r *DatabaseFeePlanRepository) List(ctx context.Context) ([]models.FeePlan, error) {
+	query := "SELECT " + feePlanColumns + " "
+	query += "FROM fee_plans ORDER BY created_at ASC, id ASC"
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []models.FeePlan{}
+	for rows.Next() {
+		plan, err := scanFeePlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, *plan)
+	}
+
+	return plans, rows.Err()
+}
+
+// Assign gives merchantID a fee plan from effectiveFrom. Assigning again
+// with the same effectiveFrom replaces the earlier assignment.
+func (r *DatabaseFeePlanRepository) Assign(ctx context.Context, assignment *models.MerchantFeePlan) error {
+	query := "INSERT INTO merchant_fee_plans (merchant_id, plan_id, effective_from, effective_to, created_at, updated_at) "
+	query += "VALUES ($1, $2, $3, $4, NOW(), NOW()) "
+	query += "ON CONFLICT (merchant_id, effective_from) "
+	query += "DO UPDATE SET plan_id = EXCLUDED.plan_id, effective_to = EXCLUDED.effective_to, updated_at = NOW() "
+	query += "RETURNING created_at, updated_at"
+
+	return r.db.QueryRow(ctx, query, assignment.MerchantID, assignment.PlanID, assignment.EffectiveFrom, assignment.EffectiveTo).Scan(&assignment.CreatedAt, &assignment.UpdatedAt)
+}
+
+// ListAssignments returns the fee plans assigned to merchantID, most recent
+// effective date first.
+func (r *DatabaseFeePlanRepository) ListAssignments(ctx context.Context, merchantID string) ([]models.MerchantFeePlan, error) {
+	query := "SELECT " + merchantFeePlanColumns + " "
+	query += "FROM merchant_fee_plans m JOIN fee_plans p ON p.id = m.plan_id "
+	query += "WHERE m.merchant_id = $1 "
+	query += "ORDER BY m.effective_from DESC"
+
+	return r.queryAssignments(ctx, query, merchantID)
+}
+
+// ListEffective returns the assignments, with their plans, that are in
+// effect on any day of [from, to]. An empty merchantIDs returns them for
+// every merchant.
+func (r *DatabaseFeePlanRepository) ListEffective(ctx context.Context, merchantIDs []string, from, to string) ([]models.MerchantFeePlan, error) {
+	query := "SELECT " + merchantFeePlanColumns + " "
+	query += "FROM merchant_fee_plans m JOIN fee_plans p ON p.id = m.plan_id "
+	query += "WHERE m.effective_from <= $2::date AND (m.effective_to IS NULL OR m.effective_to >= $1::date) "
+
+	if len(merchantIDs) > 0 {
+		query += "AND m.merchant_id = ANY($3) "
+		query += "ORDER BY m.merchant_id ASC, m.effective_from DESC"
+		return r.queryAssignments(ctx, query, from, to, merchantIDs)
+	}
+
+	query += "ORDER BY m.merchant_id ASC, m.effective_from DESC"
+	return r.queryAssignments(ctx, query, from, to)
+}
+
+func (r *DatabaseFeePlanRepository) queryAssignments(ctx context.Context, query string, args ...any) ([]models.MerchantFeePlan, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments := []models.MerchantFeePlan{}
+	for rows.Next() {
+		var a models.MerchantFeePlan
+		var plan models.FeePlan
+		var effectiveFrom time.Time
+		var effectiveTo *time.Time
+		if err := rows.Scan(&a.MerchantID, &a.PlanID, &effectiveFrom, &effectiveTo, &a.CreatedAt, &a.UpdatedAt, &plan.ID, &plan.Name, &plan.PercentageBps, &plan.FixedFee, &plan.MinFee, &plan.MaxFee, &plan.Tiers, &plan.CreatedAt, &plan.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		a.EffectiveFrom = effectiveFrom.Format("2006-01-02")
+		if effectiveTo != nil {
+			to := effectiveTo.Format("2006-01-02")
+			a.EffectiveTo = &to
+		}
+		a.Plan = &plan
+		assignments = append(assignments, a)
+	}
+
+	return assignments, rows.Err()
+}
+
+const feePlanColumns = "id, name, percentage_bps, fixed_fee, min_fee, max_fee, tiers, created_at, updated_at"
+
+const merchantFeePlanColumns = "m.merchant_id, m.plan_id, m.effective_from, m.effective_to, m.created_at, m.updated_at, " +
+	"p.id, p.name, p.percentage_bps, p.fixed_fee, p.min_fee, p.max_fee, p.tiers, p.created_at, p.updated_at"
+
+func scanFeePlan(row pgx.Row) (*models.FeePlan, error) {
+	var p models.FeePlan
+	if err := row.Scan(&p.ID, &p.Name, &p.PercentageBps, &p.FixedFee, &p.MinFee, &p.MaxFee, &p.Tiers, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const jobEventsChannel = "job_events"
+
+type DatabaseJobEventRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDatabaseJobEventRepository(db *pgxpool.Pool) *DatabaseJobEventRepository {
+	return &DatabaseJobEventRepository{db: db}
+}
+
+// Publish sends event to every instance listening on the job events channel.
+func (r *DatabaseJobEventRepository) Publish(ctx context.Context, event models.JobEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, "SELECT pg_notify($1, $2)", jobEventsChannel, string(payload))
+	return err
+}
+
+// Listen holds one pooled connection in LISTEN mode and calls handle for
+// every event until ctx is done or the connection fails.
+func (r *DatabaseJobEventRepository) Listen(ctx context.Context, handle func(models.JobEvent)) error {
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+jobEventsChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event models.JobEvent
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			fmt.Printf("[JobEvents] invalid payload: %v\n", err)
+			continue
+		}
+		handle(event)
+	}
+}
@@ -2,11 +2,15 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/banggibima/be-assignment/internal/models"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -21,69 +25,444 @@ func NewDatabaseJobRepository(db *pgxpool.Pool) *DatabaseJobRepository {
 func (r *DatabaseJobRepository) Create(ctx context.Context, tx pgx.Tx, job *models.Job) error {
 	job.ID = uuid.New().String()
 
-	query := "INSERT INTO jobs (id, job_id, status, processed, total, progress, from_date, to_date, created_at, updated_at) "
-	query += "VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())"
+	query := "INSERT INTO jobs (id, job_id, job_type, aggregation, status, processed, total, progress, from_date, to_date, parent_job_id, filter, report_format, created_at, updated_at) "
+	query += "VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW(), NOW())"
 
 	if tx != nil {
-		_, err := tx.Exec(ctx, query, job.ID, job.JobID, job.Status, job.Processed, job.Total, job.Progress, job.From, job.To)
+		_, err := tx.Exec(ctx, query, job.ID, job.JobID, job.JobType, job.Aggregation, job.Status, job.Processed, job.Total, job.Progress, job.From, job.To, job.ParentJobID, job.Filter, job.ReportFormat)
 		return err
 	}
 
-	_, err := r.db.Exec(ctx, query, job.ID, job.JobID, job.Status, job.Processed, job.Total, job.Progress, job.From, job.To)
+	_, err := r.db.Exec(ctx, query, job.ID, job.JobID, job.JobType, job.Aggregation, job.Status, job.Processed, job.Total, job.Progress, job.From, job.To, job.ParentJobID, job.Filter, job.ReportFormat)
 	return err
 }
 
-func (r *DatabaseJobRepository) UpdateProgress(ctx context.Context, tx pgx.Tx, jobID string, processed, progress int) error {
+// UpdateProgress stores the progress of a RUNNING job owned by workerID. It
+// never changes the status, and reports false when the job was cancelled or
+// taken over, so a late update cannot resurrect it.
+func (r *DatabaseJobRepository) UpdateProgress(ctx context.Context, tx pgx.Tx, jobID, workerID string, processed, progress int) (bool, error) {
 	query := "UPDATE jobs "
-	query += "SET processed = $1, progress = $2, status = 'RUNNING', updated_at = NOW() "
-	query += "WHERE job_id = $3"
+	query += "SET processed = $1, progress = $2, updated_at = NOW() "
+	query += "WHERE job_id = $3 AND worker_id = $4 AND status = 'RUNNING'"
 
+	var tag pgconn.CommandTag
+	var err error
 	if tx != nil {
-		_, err := tx.Exec(ctx, query, processed, progress, jobID)
-		return err
+		tag, err = tx.Exec(ctx, query, processed, progress, jobID, workerID)
+	} else {
+		tag, err = r.db.Exec(ctx, query, processed, progress, jobID, workerID)
+	}
+	if err != nil {
+		return false, err
 	}
 
-	_, err := r.db.Exec(ctx, query, processed, progress, jobID)
-	return err
+	return tag.RowsAffected() > 0, nil
 }
 
-func (r *DatabaseJobRepository) MarkDone(ctx context.Context, tx pgx.Tx, jobID, resultPath string) error {
+// MarkDone completes a RUNNING job owned by workerID with the report it
+// wrote. Partitions write no report and pass an empty resultPath and a nil
+// manifest. It reports false when the worker no longer owns the job, so a
+// worker that lost its lease cannot complete a job another worker took over.
+func (r *DatabaseJobRepository) MarkDone(ctx context.Context, tx pgx.Tx, jobID, workerID, resultPath string, manifest *models.ReportManifest) (bool, error) {
 	query := "UPDATE jobs "
-	query += "SET status = 'DONE', progress = 100, result_path = $1, updated_at = NOW() "
-	query += "WHERE job_id = $2"
+	query += "SET status = 'DONE', progress = 100, result_path = $1, manifest = $2, updated_at = NOW() "
+	query += "WHERE job_id = $3 AND worker_id = $4 AND status = 'RUNNING'"
 
+	var tag pgconn.CommandTag
+	var err error
 	if tx != nil {
-		_, err := tx.Exec(ctx, query, resultPath, jobID)
-		return err
+		tag, err = tx.Exec(ctx, query, resultPath, manifest, jobID, workerID)
+	} else {
+		tag, err = r.db.Exec(ctx, query, resultPath, manifest, jobID, workerID)
+	}
+	if err != nil {
+		return false, err
 	}
 
-	_, err := r.db.Exec(ctx, query, resultPath, jobID)
-	return err
+	return tag.RowsAffected() > 0, nil
 }
 
-func (r *DatabaseJobRepository) MarkCancelled(ctx context.Context, tx pgx.Tx, jobID string) error {
+// MarkCancelled cancels a RUNNING job owned by workerID, so a job that
+// finished or was taken over in the meantime keeps its status.
+func (r *DatabaseJobRepository) MarkCancelled(ctx context.Context, tx pgx.Tx, jobID, workerID string) error {
 	query := "UPDATE jobs "
 	query += "SET status = 'CANCELLED', updated_at = NOW() "
-	query += "WHERE job_id = $1"
+	query += "WHERE job_id = $1 AND worker_id = $2 AND status = 'RUNNING'"
 
 	if tx != nil {
-		_, err := tx.Exec(ctx, query, jobID)
+		_, err := tx.Exec(ctx, query, jobID, workerID)
 		return err
 	}
 
-	_, err := r.db.Exec(ctx, query, jobID)
+	_, err := r.db.Exec(ctx, query, jobID, workerID)
 	return err
 }
 
 func (r *DatabaseJobRepository) GetByID(ctx context.Context, jobID string) (*models.Job, error) {
-	query := "SELECT id, job_id, status, processed, total, progress, from_date, to_date, result_path, created_at, updated_at "
+	query := "SELECT " + jobColumns + " "
 	query += "FROM jobs WHERE job_id = $1"
 
 	row := r.db.QueryRow(ctx, query, jobID)
 
+	return scanJob(row)
+}
+
+// ClaimNext atomically moves the oldest QUEUED job, or FAILED job whose retry
+// is due, to RUNNING under a lease
+// held by workerID and returns it. SKIP LOCKED lets several workers, in this
+// process or in other replicas, poll the same table without ever claiming the
+// same job twice.
+func (r *DatabaseJobRepository) ClaimNext(ctx context.Context, workerID string) (*models.Job, error) {
+	query := "UPDATE jobs "
+	query += "SET status = 'RUNNING', worker_id = $1, heartbeat_at = NOW(), attempts = attempts + 1, updated_at = NOW() "
+	query += "WHERE id = ("
+	query += "SELECT id FROM jobs "
+	query += "WHERE status = 'QUEUED' OR (status = 'FAILED' AND run_after <= NOW()) "
+	query += "ORDER BY created_at ASC LIMIT 1 "
+	query += "FOR UPDATE SKIP LOCKED"
+	query += ") "
+	query += "RETURNING " + jobColumns
+
+	row := r.db.QueryRow(ctx, query, workerID)
+
+	job, err := scanJob(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// CancelActive marks a QUEUED, RUNNING or retrying FAILED job, and its
+// unfinished partitions, as CANCELLED and reports whether a row was changed,
+// so finished jobs keep their final status.
+func (r *DatabaseJobRepository) CancelActive(ctx context.Context, jobID string) (bool, error) {
+	query := "UPDATE jobs "
+	query += "SET status = 'CANCELLED', updated_at = NOW() "
+	query += "WHERE (job_id = $1 OR parent_job_id = $1) AND status IN ('QUEUED', 'RUNNING', 'FAILED')"
+
+	tag, err := r.db.Exec(ctx, query, jobID)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// Heartbeat extends the lease of a RUNNING job. It reports false when the
+// worker no longer owns the job, e.g. after it was cancelled or reaped.
+func (r *DatabaseJobRepository) Heartbeat(ctx context.Context, jobID, workerID string) (bool, error) {
+	query := "UPDATE jobs "
+	query += "SET heartbeat_at = NOW() "
+	query += "WHERE job_id = $1 AND worker_id = $2 AND status = 'RUNNING'"
+
+	tag, err := r.db.Exec(ctx, query, jobID, workerID)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// MarkFailed records why a RUNNING job owned by workerID failed. The job is
+// scheduled for a retry with exponential backoff, or moved to DEAD when it is
+// not retryable or has used up its attempts.
+func (r *DatabaseJobRepository) MarkFailed(ctx context.Context, jobID, workerID, reason string, retryable bool, policy models.RetryPolicy) (string, error) {
+	query := "UPDATE jobs "
+	query += "SET " + failureSet(4, 5) + ", last_error = $3, updated_at = NOW() "
+	query += "WHERE job_id = $1 AND worker_id = $2 AND status = 'RUNNING' "
+	query += "RETURNING status"
+
+	var status string
+	err := r.db.QueryRow(ctx, query, jobID, workerID, reason, policy.MaxAttempts, retryable, policy.BaseDelay.Seconds(), policy.MaxDelay.Seconds()).Scan(&status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+
+	return status, nil
+}
+
+// ReapExpired recovers RUNNING jobs whose lease has not been renewed within
+// ttl. They are failed like any other run, so they are retried with backoff
+// or moved to DEAD once they have used up their attempts. The returned jobs
+// only carry their job ID, new status and error.
+func (r *DatabaseJobRepository) ReapExpired(ctx context.Context, ttl time.Duration, policy models.RetryPolicy) ([]models.Job, error) {
+	query := "UPDATE jobs "
+	query += "SET " + failureSet(2, 3) + ", last_error = 'lease expired on ' || COALESCE(worker_id, 'unknown worker'), updated_at = NOW() "
+	query += "WHERE status = 'RUNNING' AND heartbeat_at < NOW() - make_interval(secs => $1) "
+	query += "RETURNING job_id, status, last_error"
+
+	rows, err := r.db.Query(ctx, query, ttl.Seconds(), policy.MaxAttempts, true, policy.BaseDelay.Seconds(), policy.MaxDelay.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		var j models.Job
+		if err := rows.Scan(&j.JobID, &j.Status, &j.LastError); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, rows.Err()
+}
+
+// List returns the jobs matching filter in (created_at, id) order. It reads
+// one row past filter.Limit so callers can tell whether another page exists.
+func (r *DatabaseJobRepository) List(ctx context.Context, filter models.JobFilter) ([]models.Job, error) {
+	conditions := []string{}
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(filter.Statuses) > 0 {
+		conditions = append(conditions, "status = ANY("+arg(filter.Statuses)+")")
+	}
+	if filter.JobType != "" {
+		conditions = append(conditions, "job_type = "+arg(filter.JobType))
+	}
+	if filter.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= "+arg(*filter.CreatedFrom))
+	}
+	if filter.CreatedTo != nil {
+		conditions = append(conditions, "created_at < "+arg(*filter.CreatedTo))
+	}
+	if filter.From != "" {
+		conditions = append(conditions, "from_date >= "+arg(filter.From)+"::date")
+	}
+	if filter.To != "" {
+		conditions = append(conditions, "to_date <= "+arg(filter.To)+"::date")
+	}
+
+	order := "ASC"
+	compare := ">"
+	if filter.Desc {
+		order = "DESC"
+		compare = "<"
+	}
+	if filter.After != nil {
+		conditions = append(conditions, "(created_at, id) "+compare+" ("+arg(filter.After.CreatedAt)+", "+arg(filter.After.ID)+")")
+	}
+
+	query := "SELECT " + jobColumns + " FROM jobs "
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ") + " "
+	}
+	query += "ORDER BY created_at " + order + ", id " + order + " "
+	query += "LIMIT " + arg(filter.Limit+1)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// failureSet builds the SET clause shared by MarkFailed and ReapExpired. The
+// retryable placeholder must be followed by the base and max retry delay in
+// seconds.
+func failureSet(maxAttemptsArg, retryableArg int) string {
+	return fmt.Sprintf(
+		"status = CASE WHEN attempts >= $%[1]d OR NOT $%[2]d::boolean THEN 'DEAD' ELSE 'FAILED' END, "+
+			"run_after = NOW() + LEAST(make_interval(secs => $%[3]d * POWER(2, GREATEST(attempts - 1, 0))), make_interval(secs => $%[4]d)), "+
+			"worker_id = NULL, heartbeat_at = NULL",
+		maxAttemptsArg, retryableArg, retryableArg+1, retryableArg+2,
+	)
+}
+
+// SaveCheckpoint stores the resumable state of a job owned by workerID. It
+// reports false when the worker no longer owns the job.
+func (r *DatabaseJobRepository) SaveCheckpoint(ctx context.Context, jobID, workerID string, checkpoint *models.JobCheckpoint) (bool, error) {
+	query := "UPDATE jobs "
+	query += "SET checkpoint = $1, updated_at = NOW() "
+	query += "WHERE job_id = $2 AND worker_id = $3 AND status = 'RUNNING'"
+
+	tag, err := r.db.Exec(ctx, query, checkpoint, jobID, workerID)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// Release puts a RUNNING job owned by workerID back to QUEUED without
+// counting the interrupted run as an attempt.
+func (r *DatabaseJobRepository) Release(ctx context.Context, jobID, workerID string) error {
+	query := "UPDATE jobs "
+	query += "SET status = 'QUEUED', worker_id = NULL, heartbeat_at = NULL, attempts = GREATEST(attempts - 1, 0), updated_at = NOW() "
+	query += "WHERE job_id = $1 AND worker_id = $2 AND status = 'RUNNING'"
+
+	_, err := r.db.Exec(ctx, query, jobID, workerID)
+	return err
+}
+
+// Resume re-queues a CANCELLED, FAILED or DEAD job, keeping its checkpoint.
+func (r *DatabaseJobRepository) Resume(ctx context.Context, jobID string) (bool, error) {
+	query := "UPDATE jobs "
+	query += "SET status = 'QUEUED', worker_id = NULL, heartbeat_at = NULL, attempts = 0, run_after = NULL, updated_at = NOW() "
+	query += "WHERE job_id = $1 AND status IN ('CANCELLED', 'FAILED', 'DEAD')"
+
+	tag, err := r.db.Exec(ctx, query, jobID)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetForUpdate reads a job and locks its row until tx ends.
+func (r *DatabaseJobRepository) GetForUpdate(ctx context.Context, tx pgx.Tx, jobID string) (*models.Job, error) {
+	query := "SELECT " + jobColumns + " "
+	query += "FROM jobs WHERE job_id = $1 FOR UPDATE"
+
+	row := tx.QueryRow(ctx, query, jobID)
+
+	return scanJob(row)
+}
+
+// ListPartitions returns the partitions of parentJobID ordered by range.
+func (r *DatabaseJobRepository) ListPartitions(ctx context.Context, tx pgx.Tx, parentJobID string) ([]models.Job, error) {
+	query := "SELECT " + jobColumns + " "
+	query += "FROM jobs WHERE parent_job_id = $1 "
+	query += "ORDER BY from_date ASC"
+
+	var rows pgx.Rows
+	var err error
+	if tx != nil {
+		rows, err = tx.Query(ctx, query, parentJobID)
+	} else {
+		rows, err = r.db.Query(ctx, query, parentJobID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// Detach drops workerID's lease on a RUNNING job without changing its status.
+// A partitioned parent stays RUNNING while its partitions are processed, and
+// without a heartbeat the reaper leaves it alone.
+func (r *DatabaseJobRepository) Detach(ctx context.Context, jobID, workerID string) (bool, error) {
+	query := "UPDATE jobs "
+	query += "SET worker_id = NULL, heartbeat_at = NULL, updated_at = NOW() "
+	query += "WHERE job_id = $1 AND worker_id = $2 AND status = 'RUNNING'"
+
+	tag, err := r.db.Exec(ctx, query, jobID, workerID)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// ResumePartitions re-queues the CANCELLED, FAILED and DEAD partitions of
+// parentJobID, keeping their checkpoints.
+func (r *DatabaseJobRepository) ResumePartitions(ctx context.Context, parentJobID string) (int, error) {
+	query := "UPDATE jobs "
+	query += "SET status = 'QUEUED', worker_id = NULL, heartbeat_at = NULL, attempts = 0, run_after = NULL, updated_at = NOW() "
+	query += "WHERE parent_job_id = $1 AND status IN ('CANCELLED', 'FAILED', 'DEAD')"
+
+	tag, err := r.db.Exec(ctx, query, parentJobID)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// RollupProgress sets the progress of a RUNNING parent job to the sum of its
+// partitions. The returned job only carries its job ID and progress fields.
+func (r *DatabaseJobRepository) RollupProgress(ctx context.Context, parentJobID string) (*models.Job, error) {
+	query := "UPDATE jobs p "
+	query += "SET processed = c.processed, "
+	query += "progress = CASE WHEN p.total > 0 THEN LEAST(c.processed * 100 / p.total, 100) ELSE 0 END, "
+	query += "updated_at = NOW() "
+	query += "FROM (SELECT COALESCE(SUM(processed), 0)::int AS processed FROM jobs WHERE parent_job_id = $1) c "
+	query += "WHERE p.job_id = $1 AND p.status = 'RUNNING' "
+	query += "RETURNING p.job_id, p.processed, p.total, p.progress"
+
+	var j models.Job
+	err := r.db.QueryRow(ctx, query, parentJobID).Scan(&j.JobID, &j.Processed, &j.Total, &j.Progress)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &j, nil
+}
+
+// MarkMerged completes a RUNNING parent job with the merged result of its
+// partitions.
+func (r *DatabaseJobRepository) MarkMerged(ctx context.Context, tx pgx.Tx, jobID, resultPath string, manifest *models.ReportManifest, checkpoint *models.JobCheckpoint) error {
+	query := "UPDATE jobs "
+	query += "SET status = 'DONE', processed = $1, progress = 100, result_path = $2, manifest = $3, checkpoint = $4, updated_at = NOW() "
+	query += "WHERE job_id = $5 AND status = 'RUNNING'"
+
+	_, err := tx.Exec(ctx, query, checkpoint.Processed, resultPath, manifest, checkpoint, jobID)
+	return err
+}
+
+// MarkParentDead moves the RUNNING parent of a dead partition to DEAD and
+// returns the parent's job ID, or ErrNotFound when there is nothing to update.
+func (r *DatabaseJobRepository) MarkParentDead(ctx context.Context, partitionJobID, reason string) (string, error) {
+	query := "UPDATE jobs "
+	query += "SET status = 'DEAD', last_error = $2, updated_at = NOW() "
+	query += "WHERE job_id = (SELECT parent_job_id FROM jobs WHERE job_id = $1) AND status = 'RUNNING' "
+	query += "RETURNING job_id"
+
+	var parentJobID string
+	err := r.db.QueryRow(ctx, query, partitionJobID, reason).Scan(&parentJobID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+
+	return parentJobID, nil
+}
+
+const jobColumns = "id, job_id, job_type, aggregation, status, processed, total, progress, from_date, to_date, result_path, worker_id, heartbeat_at, attempts, last_error, run_after, checkpoint, parent_job_id, filter, report_format, manifest, created_at, updated_at"
+
+func scanJob(row pgx.Row) (*models.Job, error) {
 	var j models.Job
 	var fromDate, toDate time.Time
-	if err := row.Scan(&j.ID, &j.JobID, &j.Status, &j.Processed, &j.Total, &j.Progress, &fromDate, &toDate, &j.ResultPath, &j.CreatedAt, &j.UpdatedAt); err != nil {
+	if err := row.Scan(&j.ID, &j.JobID, &j.JobType, &j.Aggregation, &j.Status, &j.Processed, &j.Total, &j.Progress, &fromDate, &toDate, &j.ResultPath, &j.WorkerID, &j.HeartbeatAt, &j.Attempts, &j.LastError, &j.RunAfter, &j.Checkpoint, &j.ParentJobID, &j.Filter, &j.ReportFormat, &j.Manifest, &j.CreatedAt, &j.UpdatedAt); err != nil {
 		return nil, err
 	}
 
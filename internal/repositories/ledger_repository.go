@@ -0,0 +1,356 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DatabaseLedgerRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDatabaseLedgerRepository(db *pgxpool.Pool) *DatabaseLedgerRepository {
+	return &DatabaseLedgerRepository{db: db}
+}
+
+// PostJournal records journal and its entries unless a journal of the same
+// kind and reference exists already. It reports whether it was recorded.
+func (r *DatabaseLedgerRepository) PostJournal(ctx context.Context, tx pgx.Tx, journal *models.LedgerJournal) (bool, error) {
+	query := "INSERT INTO ledger_journals (id, kind, source_id, reference, posted_at, created_at) "
+	query += "VALUES ($1, $2, $3, $4, $5, NOW()) "
+	query += "ON CONFLICT (kind, reference) DO NOTHING "
+	query += "RETURNING created_at"
+
+	err := tx.QueryRow(ctx, query, journal.ID, journal.Kind, journal.SourceID, journal.Reference, journal.PostedAt).Scan(&journal.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for i := range journal.Entries {
+		entry := &journal.Entries[i]
+		entry.JournalID = journal.ID
+
+		query := "INSERT INTO ledger_entries (journal_id, account_id, currency, amount) "
+		query += "VALUES ($1, $2, $3, $4)"
+
+		if _, err := tx.Exec(ctx, query, entry.JournalID, entry.AccountID, entry.Currency, entry.Amount); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// PostTransactionJournals posts the journals of the transactions with
+// transactionIDs, or of every transaction when transactionIDs is nil. Each
+// paid transaction gets a PAYMENT journal, a FEE journal when it has a fee,
+// and a REFUND or CHARGEBACK journal whenever its reversal amount grew since
+// it was last posted. Transactions posted before are skipped, so it can run
+// any number of times. It returns the number of journals posted.
+func (r *DatabaseLedgerRepository) PostTransactionJournals(ctx context.Context, tx pgx.Tx, transactionIDs []string) (int, error) {
+	if transactionIDs != nil && len(transactionIDs) == 0 {
+		return 0, nil
+	}
+
+	args := []any{}
+	ids := ""
+	if transactionIDs != nil {
+		args = append(args, transactionIDs)
+		ids = fmt.Sprintf("$%d", len(args))
+	}
+
+	return r.postTransactionJournals(ctx, tx, args, func(alias, _ string) string {
+		if ids == "" {
+			return ""
+		}
+		return " AND " + alias + "id = ANY(" + ids + ")"
+	})
+}
+
+// PostTransactionJournalsInRange posts the journals of the transactions of
+// the merchants in scope paid or reversed between two days before from and
+// three days after to in UTC, which covers the settlement days in [from, to]
+// of every time zone and cut-off. It posts them like PostTransactionJournals,
+// so the window may overlap earlier calls.
+func (r *DatabaseLedgerRepository) PostTransactionJournalsInRange(ctx context.Context, tx pgx.Tx, from, to string, scope models.TransactionFilter) (int, error) {
+	args := []any{from, to}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := ""
+	for _, condition := range transactionConditions(models.TransactionFilter{MerchantIDs: scope.MerchantIDs, ExcludeMerchantIDs: scope.ExcludeMerchantIDs}, arg) {
+		conditions += " AND " + condition
+	}
+
+	return r.postTransactionJournals(ctx, tx, args, func(alias, column string) string {
+		at := alias + column
+		return " AND " + at + " >= ($1::date - 2)::timestamp AT TIME ZONE 'UTC' AND " + at + " < ($2::date + 3)::timestamp AT TIME ZONE 'UTC'" + conditions
+	})
+}
+
+// postTransactionJournals posts the journals of the transactions selected by
+// the conditions returned by selected, which is given the alias of the
+// transactions table and the column of the time the journal is posted at.
+// args holds the arguments those conditions refer to.
+func (r *DatabaseLedgerRepository) postTransactionJournals(ctx context.Context, tx pgx.Tx, args []any, selected func(alias, column string) string) (int, error) {
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	statuses := arg(models.SettlementStatuses)
+
+	payments := "SELECT 'PAYMENT' AS kind, id AS source_id, id AS reference, paid_at AS posted_at, currency, "
+	payments += "'" + models.LedgerAccountCash + "' AS debit_account, 'merchant:' || merchant_id || ':pending' AS credit_account, amount "
+	payments += "FROM transactions WHERE status = ANY(" + statuses + ")" + selected("", "paid_at")
+
+	fees := "SELECT 'FEE', id, id, paid_at, currency, "
+	fees += "'merchant:' || merchant_id || ':pending', '" + models.LedgerAccountFeeRevenue + "', fee "
+	fees += "FROM transactions WHERE status = ANY(" + statuses + ") AND fee > 0" + selected("", "paid_at")
+
+	// A reversal that grows later, such as a second partial refund, is posted
+	// again for the part not in the ledger yet.
+	reversals := "SELECT CASE WHEN t.status = '" + models.TransactionStatusChargeback + "' THEN 'CHARGEBACK' ELSE 'REFUND' END, "
+	reversals += "t.id, t.id || '@' || t.reversal_amount, t.reversed_at, t.currency, "
+	reversals += "'merchant:' || t.merchant_id || ':pending', '" + models.LedgerAccountCash + "', "
+	reversals += "t.reversal_amount - COALESCE(("
+	reversals += "SELECT SUM(e.amount) FROM ledger_journals j JOIN ledger_entries e ON e.journal_id = j.id "
+	reversals += "WHERE j.kind IN ('REFUND', 'CHARGEBACK') AND j.source_id = t.id AND e.amount > 0"
+	reversals += "), 0) "
+	reversals += "FROM transactions t WHERE t.status = ANY(" + arg(models.ReversalStatuses) + ") AND t.reversal_amount > 0" + selected("t.", "reversed_at")
+
+	query := "WITH source AS (" + payments + " UNION ALL " + fees + " UNION ALL " + reversals + "), "
+	query += "journals AS ("
+	query += "INSERT INTO ledger_journals (id, kind, source_id, reference, posted_at, created_at) "
+	query += "SELECT gen_random_uuid()::text, kind, source_id, reference, posted_at, NOW() FROM source WHERE amount > 0 "
+	query += "ON CONFLICT (kind, reference) DO NOTHING "
+	query += "RETURNING id, kind, reference"
+	query += "), "
+	query += "entries AS ("
+	query += "INSERT INTO ledger_entries (journal_id, account_id, currency, amount) "
+	query += "SELECT j.id, e.account_id, s.currency, e.amount "
+	query += "FROM journals j JOIN source s ON s.kind = j.kind AND s.reference = j.reference "
+	query += "CROSS JOIN LATERAL (VALUES (s.debit_account, s.amount), (s.credit_account, -s.amount)) AS e(account_id, amount) "
+	query += "RETURNING journal_id"
+	query += ") "
+	query += "SELECT COUNT(DISTINCT journal_id) FROM entries"
+
+	var row pgx.Row
+	if tx != nil {
+		row = tx.QueryRow(ctx, query, args...)
+	} else {
+		row = r.db.QueryRow(ctx, query, args...)
+	}
+
+	var posted int
+	if err := row.Scan(&posted); err != nil {
+		return 0, err
+	}
+
+	return posted, nil
+}
+
+// SettlementRunDeltas returns, per merchant and currency, how much the net
+// amount of the rows of runID dated in [from, to] differs from the
+// settlements it is about to replace there. Only merchants in the scope of
+// filter are compared, as ReplaceFromRun only replaces those. Merchants and
+// currencies without a difference are left out.
+func (r *DatabaseLedgerRepository) SettlementRunDeltas(ctx context.Context, tx pgx.Tx, runID, from, to string, filter *models.TransactionFilter) ([]models.Settlement, error) {
+	args := []any{runID, from, to}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	current := "SELECT merchant_id, currency, -net_amount FROM settlements WHERE date BETWEEN $2::date AND $3::date"
+	if filter != nil {
+		scope := models.TransactionFilter{MerchantIDs: filter.MerchantIDs, ExcludeMerchantIDs: filter.ExcludeMerchantIDs}
+		for _, condition := range transactionConditions(scope, arg) {
+			current += " AND " + condition
+		}
+	}
+
+	query := "SELECT merchant_id, currency, SUM(net) FROM ("
+	query += "SELECT merchant_id, currency, net_amount AS net FROM settlement_run_rows WHERE run_id = $1 AND date BETWEEN $2::date AND $3::date "
+	query += "UNION ALL " + current
+	query += ") d GROUP BY merchant_id, currency HAVING SUM(net) <> 0 "
+	query += "ORDER BY merchant_id ASC, currency ASC"
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deltas := []models.Settlement{}
+	for rows.Next() {
+		var s models.Settlement
+		if err := rows.Scan(&s.MerchantID, &s.Currency, &s.NetAmount); err != nil {
+			return nil, err
+		}
+		deltas = append(deltas, s)
+	}
+
+	return deltas, rows.Err()
+}
+
+// Balances sums the entries of every account in accountIDs, or of every
+// account when accountIDs is empty, per currency.
+func (r *DatabaseLedgerRepository) Balances(ctx context.Context, accountIDs []string, currency string) ([]models.LedgerBalance, error) {
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{}
+	if len(accountIDs) > 0 {
+		conditions = append(conditions, "account_id = ANY("+arg(accountIDs)+")")
+	}
+	if currency != "" {
+		conditions = append(conditions, "currency = "+arg(currency))
+	}
+
+	query := "SELECT account_id, currency, "
+	query += "COALESCE(SUM(amount) FILTER (WHERE amount > 0), 0), COALESCE(-SUM(amount) FILTER (WHERE amount < 0), 0) "
+	query += "FROM ledger_entries "
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ") + " "
+	}
+	query += "GROUP BY account_id, currency ORDER BY account_id ASC, currency ASC"
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := []models.LedgerBalance{}
+	for rows.Next() {
+		var b models.LedgerBalance
+		if err := rows.Scan(&b.AccountID, &b.Currency, &b.Debit, &b.Credit); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+
+	return balances, rows.Err()
+}
+
+// ListJournals returns the journals matching filter with their entries in
+// (posted_at, id) order. It reads one journal past filter.Limit so callers
+// can tell whether another page exists.
+func (r *DatabaseLedgerRepository) ListJournals(ctx context.Context, filter models.LedgerJournalFilter) ([]models.LedgerJournal, error) {
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{}
+	if filter.Kind != "" {
+		conditions = append(conditions, "kind = "+arg(filter.Kind))
+	}
+	if filter.SourceID != "" {
+		conditions = append(conditions, "source_id = "+arg(filter.SourceID))
+	}
+	if filter.AccountID != "" {
+		conditions = append(conditions, "id IN (SELECT journal_id FROM ledger_entries WHERE account_id = "+arg(filter.AccountID)+")")
+	}
+	if filter.After != nil {
+		conditions = append(conditions, "(posted_at, id) > ("+arg(filter.After.PostedAt)+"::timestamptz, "+arg(filter.After.ID)+")")
+	}
+
+	query := "SELECT id, kind, source_id, reference, posted_at, created_at FROM ledger_journals "
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ") + " "
+	}
+	query += "ORDER BY posted_at ASC, id ASC "
+	query += "LIMIT " + arg(filter.Limit+1)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	journals := []models.LedgerJournal{}
+	index := map[string]int{}
+	for rows.Next() {
+		var j models.LedgerJournal
+		if err := rows.Scan(&j.ID, &j.Kind, &j.SourceID, &j.Reference, &j.PostedAt, &j.CreatedAt); err != nil {
+			return nil, err
+		}
+		j.Entries = []models.LedgerEntry{}
+		index[j.ID] = len(journals)
+		journals = append(journals, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(journals) == 0 {
+		return journals, nil
+	}
+
+	ids := make([]string, 0, len(journals))
+	for _, j := range journals {
+		ids = append(ids, j.ID)
+	}
+
+	query = "SELECT journal_id, account_id, currency, amount FROM ledger_entries "
+	query += "WHERE journal_id = ANY($1) ORDER BY journal_id, amount DESC, account_id"
+
+	rows, err = r.db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.LedgerEntry
+		if err := rows.Scan(&e.JournalID, &e.AccountID, &e.Currency, &e.Amount); err != nil {
+			return nil, err
+		}
+		j := &journals[index[e.JournalID]]
+		j.Entries = append(j.Entries, e)
+	}
+
+	return journals, rows.Err()
+}
+
+// ListImbalances returns every journal whose entries do not sum to zero in
+// some currency.
+func (r *DatabaseLedgerRepository) ListImbalances(ctx context.Context) ([]models.LedgerImbalance, error) {
+	query := "SELECT e.journal_id, e.currency, SUM(e.amount) FROM ledger_entries e "
+	query += "GROUP BY e.journal_id, e.currency HAVING SUM(e.amount) <> 0 "
+	query += "ORDER BY e.journal_id, e.currency"
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	imbalances := []models.LedgerImbalance{}
+	for rows.Next() {
+		var i models.LedgerImbalance
+		if err := rows.Scan(&i.JournalID, &i.Currency, &i.Total); err != nil {
+			return nil, err
+		}
+		imbalances = append(imbalances, i)
+	}
+
+	return imbalances, rows.Err()
+}
@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DatabaseMerchantSettlementConfigRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDatabaseMerchantSettlementConfigRepository(db *pgxpool.Pool) *DatabaseMerchantSettlementConfigRepository {
+	return &DatabaseMerchantSettlementConfigRepository{db: db}
+}
+
+func (r *DatabaseMerchantSettlementConfigRepository) GetByMerchantID(ctx context.Context, merchantID string) (*models.MerchantSettlementConfig, error) {
+	query := "SELECT merchant_id, time_zone, cutoff_hour, created_at, updated_at "
+	query += "FROM merchant_settlement_configs WHERE merchant_id = $1"
+
+	var c models.MerchantSettlementConfig
+	err := r.db.QueryRow(ctx, query, merchantID).Scan(&c.MerchantID, &c.TimeZone, &c.CutoffHour, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (r *DatabaseMerchantSettlementConfigRepository) Upsert(ctx context.Context, config *models.MerchantSettlementConfig) error {
+	query := "INSERT INTO merchant_settlement_configs (merchant_id, time_zone, cutoff_hour, created_at, updated_at) "
+	query += "VALUES ($1, $2, $3, NOW(), NOW()) "
+	query += "ON CONFLICT (merchant_id) "
+	query += "DO UPDATE SET time_zone = EXCLUDED.time_zone, cutoff_hour = EXCLUDED.cutoff_hour, updated_at = NOW() "
+	query += "RETURNING created_at, updated_at"
+
+	return r.db.QueryRow(ctx, query, config.MerchantID, config.TimeZone, config.CutoffHour).Scan(&config.CreatedAt, &config.UpdatedAt)
+}
+
+func (r *DatabaseMerchantSettlementConfigRepository) Delete(ctx context.Context, merchantID string) (bool, error) {
+	query := "DELETE FROM merchant_settlement_configs WHERE merchant_id = $1"
+
+	tag, err := r.db.Exec(ctx, query, merchantID)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
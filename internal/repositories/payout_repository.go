@@ -0,0 +1,268 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DatabasePayoutRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDatabasePayoutRepository(db *pgxpool.Pool) *DatabasePayoutRepository {
+	return &DatabasePayoutRepository{db: db}
+}
+
+const payoutColumns = "id, merchant_id, currency, payout_date, amount, settlement_count, status, reference, failure_reason, sent_at, confirmed_at, failed_at, created_at, updated_at"
+
+func scanPayout(row pgx.Row) (*models.Payout, error) {
+	var p models.Payout
+	if err := row.Scan(&p.ID, &p.MerchantID, &p.Currency, &p.PayoutDate, &p.Amount, &p.SettlementCount, &p.Status, &p.Reference, &p.FailureReason, &p.SentAt, &p.ConfirmedAt, &p.FailedAt, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// Lock takes a transaction level advisory lock, so payouts are generated one
+// batch at a time and a settlement never ends up in two payouts.
+func (r *DatabasePayoutRepository) Lock(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('payouts'))")
+	return err
+}
+
+// ListUnpaidSettlements returns, per settlement dated before date, how much
+// of its payable amount is not covered by payouts that have not FAILED, in
+// (merchant_id, currency, date) order. A settlement whose payable amount
+// dropped after it was paid out, or that no longer exists, comes back with a
+// negative amount. Settlements paid out exactly are left out. An empty
+// merchantIDs lists every merchant.
+func (r *DatabasePayoutRepository) ListUnpaidSettlements(ctx context.Context, tx pgx.Tx, date string, merchantIDs []string) ([]models.Settlement, error) {
+	args := []any{date}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	merchants := ""
+	if len(merchantIDs) > 0 {
+		merchants = "AND merchant_id = ANY(" + arg(merchantIDs) + ") "
+	}
+
+	query := "SELECT merchant_id, date, currency, SUM(amount) FROM ("
+	query += "SELECT merchant_id, date, currency, payable_amount AS amount FROM settlements "
+	query += "WHERE date < $1::date " + merchants
+	query += "UNION ALL "
+	query += "SELECT merchant_id, date, currency, -amount FROM payout_items "
+	query += "WHERE date < $1::date " + merchants
+	query += "AND payout_id IN (SELECT id FROM payouts WHERE status <> 'FAILED')"
+	query += ") u "
+	query += "GROUP BY merchant_id, date, currency HAVING SUM(amount) <> 0 "
+	query += "ORDER BY merchant_id ASC, currency ASC, date ASC"
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settlements := []models.Settlement{}
+	for rows.Next() {
+		var s models.Settlement
+		if err := rows.Scan(&s.MerchantID, &s.Date, &s.Currency, &s.PayableAmount); err != nil {
+			return nil, err
+		}
+		settlements = append(settlements, s)
+	}
+
+	return settlements, rows.Err()
+}
+
+// GetActive returns the payout of merchantID in currency on payoutDate that
+// has not FAILED. There is at most one.
+func (r *DatabasePayoutRepository) GetActive(ctx context.Context, tx pgx.Tx, merchantID, currency, payoutDate string) (*models.Payout, error) {
+	query := "SELECT " + payoutColumns + " "
+	query += "FROM payouts WHERE merchant_id = $1 AND currency = $2 AND payout_date = $3::date AND status <> 'FAILED'"
+
+	payout, err := scanPayout(tx.QueryRow(ctx, query, merchantID, currency, payoutDate))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return payout, nil
+}
+
+// Create inserts an empty PENDING payout. Its amount follows from the items
+// added to it.
+func (r *DatabasePayoutRepository) Create(ctx context.Context, tx pgx.Tx, payout *models.Payout) error {
+	query := "INSERT INTO payouts (id, merchant_id, currency, payout_date, amount, settlement_count, status, created_at, updated_at) "
+	query += "VALUES ($1, $2, $3, $4::date, 0, 0, 'PENDING', NOW(), NOW()) "
+	query += "RETURNING " + payoutColumns
+
+	created, err := scanPayout(tx.QueryRow(ctx, query, payout.ID, payout.MerchantID, payout.Currency, payout.PayoutDate))
+	if err != nil {
+		return err
+	}
+	*payout = *created
+
+	return nil
+}
+
+// AddItems adds items to payoutID and recomputes its amount and settlement
+// count from all of its items. An item for a settlement the payout already
+// covers adds to that item's amount.
+func (r *DatabasePayoutRepository) AddItems(ctx context.Context, tx pgx.Tx, payoutID string, items []models.PayoutItem) (*models.Payout, error) {
+	for _, item := range items {
+		query := "INSERT INTO payout_items (payout_id, merchant_id, date, currency, amount) "
+		query += "VALUES ($1, $2, $3, $4, $5) "
+		query += "ON CONFLICT (payout_id, merchant_id, date, currency) DO UPDATE SET amount = payout_items.amount + EXCLUDED.amount"
+
+		if _, err := tx.Exec(ctx, query, payoutID, item.MerchantID, item.Date, item.Currency, item.Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	query := "UPDATE payouts SET "
+	query += "amount = (SELECT COALESCE(SUM(amount), 0) FROM payout_items WHERE payout_id = $1), "
+	query += "settlement_count = (SELECT COUNT(*) FROM payout_items WHERE payout_id = $1), "
+	query += "updated_at = NOW() "
+	query += "WHERE id = $1 "
+	query += "RETURNING " + payoutColumns
+
+	return scanPayout(tx.QueryRow(ctx, query, payoutID))
+}
+
+func (r *DatabasePayoutRepository) GetByID(ctx context.Context, id string) (*models.Payout, error) {
+	query := "SELECT " + payoutColumns + " "
+	query += "FROM payouts WHERE id = $1"
+
+	payout, err := scanPayout(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return payout, nil
+}
+
+// ListItems returns the settlements paid out by payoutID in date order.
+func (r *DatabasePayoutRepository) ListItems(ctx context.Context, payoutID string) ([]models.PayoutItem, error) {
+	query := "SELECT payout_id, merchant_id, date, currency, amount "
+	query += "FROM payout_items WHERE payout_id = $1 ORDER BY date ASC"
+
+	rows, err := r.db.Query(ctx, query, payoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.PayoutItem{}
+	for rows.Next() {
+		var item models.PayoutItem
+		if err := rows.Scan(&item.PayoutID, &item.MerchantID, &item.Date, &item.Currency, &item.Amount); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// List returns the payouts matching filter in (payout_date, id) order. It
+// reads one row past filter.Limit so callers can tell whether another page
+// exists.
+func (r *DatabasePayoutRepository) List(ctx context.Context, filter models.PayoutFilter) ([]models.Payout, error) {
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{}
+	if filter.MerchantID != "" {
+		conditions = append(conditions, "merchant_id = "+arg(filter.MerchantID))
+	}
+	if filter.Currency != "" {
+		conditions = append(conditions, "currency = "+arg(filter.Currency))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+arg(filter.Status))
+	}
+	if filter.From != "" {
+		conditions = append(conditions, "payout_date >= "+arg(filter.From)+"::date")
+	}
+	if filter.To != "" {
+		conditions = append(conditions, "payout_date <= "+arg(filter.To)+"::date")
+	}
+	if filter.After != nil {
+		conditions = append(conditions, "(payout_date, id) > ("+arg(filter.After.PayoutDate)+"::date, "+arg(filter.After.ID)+")")
+	}
+
+	query := "SELECT " + payoutColumns + " FROM payouts "
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ") + " "
+	}
+	query += "ORDER BY payout_date ASC, id ASC "
+	query += "LIMIT " + arg(filter.Limit+1)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payouts := []models.Payout{}
+	for rows.Next() {
+		payout, err := scanPayout(rows)
+		if err != nil {
+			return nil, err
+		}
+		payouts = append(payouts, *payout)
+	}
+
+	return payouts, rows.Err()
+}
+
+// UpdateStatus moves payout id from status from to status to, stamping the
+// time it was sent, confirmed or failed. It returns ErrNotFound when the
+// payout is not in status from anymore, so concurrent updates cannot skip a
+// step of the lifecycle.
+func (r *DatabasePayoutRepository) UpdateStatus(ctx context.Context, tx pgx.Tx, id, from, to string, reference, failureReason *string) (*models.Payout, error) {
+	query := "UPDATE payouts SET status = $3, "
+	query += "reference = COALESCE($4, reference), "
+	query += "failure_reason = COALESCE($5, failure_reason), "
+	query += "sent_at = CASE WHEN $3 = 'SENT' THEN NOW() ELSE sent_at END, "
+	query += "confirmed_at = CASE WHEN $3 = 'CONFIRMED' THEN NOW() ELSE confirmed_at END, "
+	query += "failed_at = CASE WHEN $3 = 'FAILED' THEN NOW() ELSE failed_at END, "
+	query += "updated_at = NOW() "
+	query += "WHERE id = $1 AND status = $2 "
+	query += "RETURNING " + payoutColumns
+
+	var row pgx.Row
+	if tx != nil {
+		row = tx.QueryRow(ctx, query, id, from, to, reference, failureReason)
+	} else {
+		row = r.db.QueryRow(ctx, query, id, from, to, reference, failureReason)
+	}
+
+	payout, err := scanPayout(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return payout, nil
+}
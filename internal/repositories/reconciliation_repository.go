@@ -0,0 +1,191 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DatabaseReconciliationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDatabaseReconciliationRepository(db *pgxpool.Pool) *DatabaseReconciliationRepository {
+	return &DatabaseReconciliationRepository{db: db}
+}
+
+const reconciliationColumns = "id, format, file_name, statement_from, statement_to, amount_tolerance, date_tolerance_days, line_count, matched_count, partial_count, unmatched_count, created_at"
+
+// ListCandidates returns the payouts sent and the settlements with a positive
+// net amount in currencies whose date lies in [from, to]: the money movements
+// a bank statement covering that range should show. A payout is dated on the
+// day it was sent, or its payout date when that is unknown.
+func (r *DatabaseReconciliationRepository) ListCandidates(ctx context.Context, from, to string, currencies []string) ([]models.ReconciliationCandidate, error) {
+	query := "SELECT 'PAYOUT', id, merchant_id, currency, -amount, COALESCE(sent_at::date, payout_date), COALESCE(reference, '') "
+	query += "FROM payouts WHERE status IN ('SENT', 'CONFIRMED') AND amount > 0 AND currency = ANY($3) "
+	query += "AND COALESCE(sent_at::date, payout_date) BETWEEN $1::date AND $2::date "
+	query += "UNION ALL "
+	query += "SELECT 'SETTLEMENT', id, merchant_id, currency, net_amount, date, '' "
+	query += "FROM settlements WHERE net_amount > 0 AND currency = ANY($3) "
+	query += "AND date BETWEEN $1::date AND $2::date "
+	query += "ORDER BY 6, 1, 2"
+
+	rows, err := r.db.Query(ctx, query, from, to, currencies)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []models.ReconciliationCandidate{}
+	for rows.Next() {
+		var c models.ReconciliationCandidate
+		if err := rows.Scan(&c.Kind, &c.ID, &c.MerchantID, &c.Currency, &c.Amount, &c.Date, &c.Reference); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// Create inserts reconciliation with its items.
+func (r *DatabaseReconciliationRepository) Create(ctx context.Context, tx pgx.Tx, reconciliation *models.Reconciliation) error {
+	query := "INSERT INTO bank_reconciliations (" + reconciliationColumns + ") "
+	query += "VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW()) "
+	query += "RETURNING created_at"
+
+	err := tx.QueryRow(ctx, query,
+		reconciliation.ID,
+		reconciliation.Format,
+		reconciliation.FileName,
+		reconciliation.StatementFrom,
+		reconciliation.StatementTo,
+		reconciliation.AmountTolerance,
+		reconciliation.DateToleranceDays,
+		reconciliation.LineCount,
+		reconciliation.MatchedCount,
+		reconciliation.PartialCount,
+		reconciliation.UnmatchedCount,
+	).Scan(&reconciliation.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for i := range reconciliation.Items {
+		item := &reconciliation.Items[i]
+		item.ReconciliationID = reconciliation.ID
+
+		query := "INSERT INTO bank_reconciliation_items (reconciliation_id, position, status, line_no, value_date, amount, currency, "
+		query += "reference, description, match_kind, match_id, merchant_id, expected_amount, expected_date, difference, reason) "
+		query += "VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)"
+
+		_, err := tx.Exec(ctx, query,
+			item.ReconciliationID,
+			item.Position,
+			item.Status,
+			item.LineNo,
+			item.ValueDate,
+			item.Amount,
+			item.Currency,
+			item.Reference,
+			item.Description,
+			item.MatchKind,
+			item.MatchID,
+			item.MerchantID,
+			item.ExpectedAmount,
+			item.ExpectedDate,
+			item.Difference,
+			item.Reason,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *DatabaseReconciliationRepository) GetByID(ctx context.Context, id string) (*models.Reconciliation, error) {
+	query := "SELECT " + reconciliationColumns + " "
+	query += "FROM bank_reconciliations WHERE id = $1"
+
+	var rec models.Reconciliation
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&rec.ID,
+		&rec.Format,
+		&rec.FileName,
+		&rec.StatementFrom,
+		&rec.StatementTo,
+		&rec.AmountTolerance,
+		&rec.DateToleranceDays,
+		&rec.LineCount,
+		&rec.MatchedCount,
+		&rec.PartialCount,
+		&rec.UnmatchedCount,
+		&rec.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+// ListItems returns the items of reconciliationID in report order. An empty
+// status lists every item.
+func (r *DatabaseReconciliationRepository) ListItems(ctx context.Context, reconciliationID, status string) ([]models.ReconciliationItem, error) {
+	args := []any{reconciliationID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	query := "SELECT reconciliation_id, position, status, line_no, value_date, amount, currency, reference, description, "
+	query += "match_kind, match_id, merchant_id, expected_amount, expected_date, difference, reason "
+	query += "FROM bank_reconciliation_items WHERE reconciliation_id = $1 "
+	if status != "" {
+		query += "AND status = " + arg(status) + " "
+	}
+	query += "ORDER BY position ASC"
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ReconciliationItem{}
+	for rows.Next() {
+		var item models.ReconciliationItem
+		if err := rows.Scan(
+			&item.ReconciliationID,
+			&item.Position,
+			&item.Status,
+			&item.LineNo,
+			&item.ValueDate,
+			&item.Amount,
+			&item.Currency,
+			&item.Reference,
+			&item.Description,
+			&item.MatchKind,
+			&item.MatchID,
+			&item.MerchantID,
+			&item.ExpectedAmount,
+			&item.ExpectedDate,
+			&item.Difference,
+			&item.Reason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
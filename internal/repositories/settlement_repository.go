@@ -2,8 +2,12 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
-	"github.com/google/uuid"
+	"github.com/banggibima/be-assignment/internal/models"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -20,20 +24,390 @@ func NewDatabaseSettlementRepository(
 	}
 }
 
-func (r *DatabaseSettlementRepository) UpsertJob(ctx context.Context, tx pgx.Tx, runID, merchantID, date string, gross, fee, net, txnCount int) error {
-	id := uuid.New().String()
+// List returns the settlements matching filter in (date, merchant_id,
+// currency) order.
+// It reads one row past filter.Limit so callers can tell whether another page
+// exists.
+func (r *DatabaseSettlementRepository) List(ctx context.Context, filter models.SettlementFilter) ([]models.Settlement, error) {
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := settlementConditions(filter, arg)
+	if filter.After != nil {
+		conditions = append(conditions, "(date, merchant_id, currency) > ("+arg(filter.After.Date)+"::date, "+arg(filter.After.MerchantID)+", "+arg(filter.After.Currency)+")")
+	}
+
+	query := "SELECT " + settlementColumns + " FROM settlements "
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ") + " "
+	}
+	query += "ORDER BY date ASC, merchant_id ASC, currency ASC "
+	query += "LIMIT " + arg(filter.Limit+1)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settlements := []models.Settlement{}
+	for rows.Next() {
+		settlement, err := scanSettlement(rows)
+		if err != nil {
+			return nil, err
+		}
+		settlements = append(settlements, *settlement)
+	}
+
+	return settlements, rows.Err()
+}
+
+// Totals sums every settlement matching filter per currency, ignoring its
+// cursor and limit.
+func (r *DatabaseSettlementRepository) Totals(ctx context.Context, filter models.SettlementFilter) ([]models.SettlementTotals, error) {
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := settlementConditions(filter, arg)
+
+	query := "SELECT currency, SUM(gross_amount), SUM(fee_amount), SUM(net_amount), SUM(txn_count), "
+	query += "SUM(refund_amount), SUM(chargeback_amount), SUM(payable_amount), "
+	query += "COALESCE(SUM(plan_fee_amount), 0), COUNT(*) FILTER (WHERE fee_mismatch) "
+	query += "FROM settlements "
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ") + " "
+	}
+	query += "GROUP BY currency ORDER BY currency ASC"
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []models.SettlementTotals{}
+	for rows.Next() {
+		var t models.SettlementTotals
+		if err := rows.Scan(&t.Currency, &t.GrossAmount, &t.FeeAmount, &t.NetAmount, &t.TxnCount, &t.RefundAmount, &t.ChargebackAmount, &t.PayableAmount, &t.PlanFeeAmount, &t.FeeMismatches); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+
+	return totals, rows.Err()
+}
+
+func (r *DatabaseSettlementRepository) GetByMerchantAndDate(ctx context.Context, merchantID, date, currency string) (*models.Settlement, error) {
+	query := "SELECT " + settlementColumns + " "
+	query += "FROM settlements WHERE merchant_id = $1 AND date = $2::date AND currency = $3"
+
+	settlement, err := scanSettlement(r.db.QueryRow(ctx, query, merchantID, date, currency))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return settlement, nil
+}
+
+func settlementConditions(filter models.SettlementFilter, arg func(any) string) []string {
+	conditions := []string{}
+	if filter.MerchantID != "" {
+		conditions = append(conditions, "merchant_id = "+arg(filter.MerchantID))
+	}
+	if filter.Currency != "" {
+		conditions = append(conditions, "currency = "+arg(filter.Currency))
+	}
+	if filter.From != "" {
+		conditions = append(conditions, "date >= "+arg(filter.From)+"::date")
+	}
+	if filter.To != "" {
+		conditions = append(conditions, "date <= "+arg(filter.To)+"::date")
+	}
+	if filter.RunID != "" {
+		conditions = append(conditions, "unique_run_id = "+arg(filter.RunID))
+	}
+	if filter.FeeMismatch != nil {
+		conditions = append(conditions, "fee_mismatch = "+arg(*filter.FeeMismatch))
+	}
+
+	return conditions
+}
+
+// settlementRowColumns are the columns settlements share with
+// settlement_run_rows.
+const settlementRowColumns = "merchant_id, date, currency, gross_amount, fee_amount, net_amount, txn_count, plan_fee_amount, fee_mismatch, " +
+	"refund_amount, chargeback_amount, reversal_count, carried_in, payable_amount, carry_forward"
+
+const settlementColumns = "id, " + settlementRowColumns + ", unique_run_id, generated_at, created_at, updated_at"
+
+func scanSettlement(row pgx.Row) (*models.Settlement, error) {
+	var s models.Settlement
+	if err := row.Scan(&s.ID, &s.MerchantID, &s.Date, &s.Currency, &s.GrossAmount, &s.FeeAmount, &s.NetAmount, &s.TxnCount, &s.PlanFeeAmount, &s.FeeMismatch, &s.RefundAmount, &s.ChargebackAmount, &s.ReversalCount, &s.CarriedIn, &s.PayableAmount, &s.CarryForward, &s.UniqueRunID, &s.GeneratedAt, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// scanRunRow scans a settlement_run_rows row selected as run_id followed by
+// settlementRowColumns.
+func scanRunRow(row pgx.Row) (*models.Settlement, error) {
+	var s models.Settlement
+	if err := row.Scan(&s.UniqueRunID, &s.MerchantID, &s.Date, &s.Currency, &s.GrossAmount, &s.FeeAmount, &s.NetAmount, &s.TxnCount, &s.PlanFeeAmount, &s.FeeMismatch, &s.RefundAmount, &s.ChargebackAmount, &s.ReversalCount, &s.CarriedIn, &s.PayableAmount, &s.CarryForward); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (r *DatabaseSettlementRepository) CreateRun(ctx context.Context, tx pgx.Tx, run *models.SettlementRun) error {
+	query := "INSERT INTO settlement_runs (id, job_id, kind, source_run_id, filter, from_date, to_date, created_at) "
+	query += "VALUES ($1, $2, $3, $4, $5, $6, $7, NOW()) "
+	query += "RETURNING created_at"
+
+	return tx.QueryRow(ctx, query, run.ID, run.JobID, run.Kind, run.SourceRunID, run.Filter, run.From, run.To).Scan(&run.CreatedAt)
+}
+
+func (r *DatabaseSettlementRepository) GetRun(ctx context.Context, runID string) (*models.SettlementRun, error) {
+	query := "SELECT id, job_id, kind, source_run_id, filter, from_date, to_date, created_at "
+	query += "FROM settlement_runs WHERE id = $1"
+
+	var run models.SettlementRun
+	var fromDate, toDate time.Time
+	err := r.db.QueryRow(ctx, query, runID).Scan(&run.ID, &run.JobID, &run.Kind, &run.SourceRunID, &run.Filter, &fromDate, &toDate, &run.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	run.From = fromDate.Format("2006-01-02")
+	run.To = toDate.Format("2006-01-02")
+
+	return &run, nil
+}
+
+// AddRunRow records the settlement one run produced for a merchant, date and
+// currency. Rows are kept after the run is superseded so it can be restored
+// later.
+func (r *DatabaseSettlementRepository) AddRunRow(ctx context.Context, tx pgx.Tx, runID, merchantID, date, currency string, settlement models.SettlementAggregate) error {
+	query := "INSERT INTO settlement_run_rows (run_id, " + settlementRowColumns + ") "
+	query += "VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)"
+
+	_, err := tx.Exec(ctx, query, runID, merchantID, date, currency, settlement.GrossAmount, settlement.FeeAmount, settlement.NetAmount, settlement.TxnCount, settlement.PlanFeeAmount, settlement.FeeMismatch,
+		settlement.RefundAmount, settlement.ChargebackAmount, settlement.ReversalCount, settlement.CarriedIn, settlement.PayableAmount, settlement.CarryForward)
+	return err
+}
+
+// Lock takes the transaction level advisory lock ReplaceFromRun takes, so
+// settlements read after it cannot change before tx ends.
+func (r *DatabaseSettlementRepository) Lock(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('settlements'))")
+	return err
+}
+
+// ListCarryForward returns, per merchant and currency, the deficit the latest
+// settlement dated before date carries forward. Merchants and currencies whose
+// latest settlement was paid out in full are left out.
+func (r *DatabaseSettlementRepository) ListCarryForward(ctx context.Context, tx pgx.Tx, date string) ([]models.Settlement, error) {
+	query := "SELECT merchant_id, date, currency, carry_forward FROM ("
+	query += "SELECT DISTINCT ON (merchant_id, currency) merchant_id, date, currency, carry_forward "
+	query += "FROM settlements WHERE date < $1::date "
+	query += "ORDER BY merchant_id, currency, date DESC"
+	query += ") s WHERE carry_forward < 0"
 
-	query := "INSERT INTO settlements (id, merchant_id, date, gross_amount, fee_amount, net_amount, txn_count, unique_run_id, created_at, updated_at) "
-	query += "VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW()) "
-	query += "ON CONFLICT (merchant_id, date) "
-	query += "DO UPDATE SET "
-	query += "gross_amount = settlements.gross_amount + EXCLUDED.gross_amount, "
-	query += "fee_amount = settlements.fee_amount + EXCLUDED.fee_amount, "
-	query += "net_amount = settlements.net_amount + EXCLUDED.net_amount, "
-	query += "txn_count = settlements.txn_count + EXCLUDED.txn_count, "
-	query += "unique_run_id = EXCLUDED.unique_run_id, "
-	query += "updated_at = NOW()"
+	rows, err := tx.Query(ctx, query, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	_, err := tx.Exec(ctx, query, id, merchantID, date, gross, fee, net, txnCount, runID)
+	settlements := []models.Settlement{}
+	for rows.Next() {
+		var s models.Settlement
+		if err := rows.Scan(&s.MerchantID, &s.Date, &s.Currency, &s.CarryForward); err != nil {
+			return nil, err
+		}
+		settlements = append(settlements, s)
+	}
+
+	return settlements, rows.Err()
+}
+
+// CopyRunRows copies the rows of sourceRunID dated in [from, to] to runID.
+func (r *DatabaseSettlementRepository) CopyRunRows(ctx context.Context, tx pgx.Tx, sourceRunID, runID, from, to string) error {
+	query := "INSERT INTO settlement_run_rows (run_id, " + settlementRowColumns + ") "
+	query += "SELECT $2, " + settlementRowColumns + " "
+	query += "FROM settlement_run_rows WHERE run_id = $1 AND date BETWEEN $3 AND $4"
+
+	_, err := tx.Exec(ctx, query, sourceRunID, runID, from, to)
 	return err
 }
+
+// HasLaterSettlements reports whether a merchant and currency that runID has
+// rows for in [from, to], or that is settled there now within the scope of
+// filter, also has a settlement dated after to. Those later settlements
+// carried in what the settlements in [from, to] carried forward. An empty
+// runID only checks the current settlements.
+func (r *DatabaseSettlementRepository) HasLaterSettlements(ctx context.Context, tx pgx.Tx, runID, from, to string, filter *models.TransactionFilter) (bool, error) {
+	args := []any{runID, from, to}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	current := "SELECT merchant_id, currency FROM settlements WHERE date BETWEEN $2::date AND $3::date"
+	if filter != nil {
+		scope := models.TransactionFilter{MerchantIDs: filter.MerchantIDs, ExcludeMerchantIDs: filter.ExcludeMerchantIDs}
+		for _, condition := range transactionConditions(scope, arg) {
+			current += " AND " + condition
+		}
+	}
+
+	query := "SELECT EXISTS (SELECT 1 FROM settlements WHERE date > $3::date AND (merchant_id, currency) IN ("
+	query += "SELECT merchant_id, currency FROM settlement_run_rows WHERE run_id = $1 AND date BETWEEN $2::date AND $3::date "
+	query += "UNION " + current
+	query += "))"
+
+	var exists bool
+	if err := tx.QueryRow(ctx, query, args...).Scan(&exists); err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// ReplaceFromRun replaces the settlements dated in [from, to] with the rows
+// recorded for runID, so merchants and dates missing from the run disappear
+// and applying the same run twice leaves the same result. Only merchants in
+// the scope of filter are replaced; its statuses do not narrow the scope. It
+// first takes a transaction level advisory lock, so runs over overlapping
+// ranges replace their rows one after the other instead of interleaving.
+func (r *DatabaseSettlementRepository) ReplaceFromRun(ctx context.Context, tx pgx.Tx, runID, from, to string, filter *models.TransactionFilter) error {
+	if err := r.Lock(ctx, tx); err != nil {
+		return err
+	}
+
+	args := []any{from, to}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	deleteQuery := "DELETE FROM settlements WHERE date BETWEEN $1 AND $2"
+	if filter != nil {
+		scope := models.TransactionFilter{MerchantIDs: filter.MerchantIDs, ExcludeMerchantIDs: filter.ExcludeMerchantIDs}
+		for _, condition := range transactionConditions(scope, arg) {
+			deleteQuery += " AND " + condition
+		}
+	}
+
+	if _, err := tx.Exec(ctx, deleteQuery, args...); err != nil {
+		return err
+	}
+
+	query := "INSERT INTO settlements (id, " + settlementRowColumns + ", unique_run_id, generated_at, created_at, updated_at) "
+	query += "SELECT gen_random_uuid()::text, " + settlementRowColumns + ", run_id, NOW(), NOW(), NOW() "
+	query += "FROM settlement_run_rows WHERE run_id = $1 AND date BETWEEN $2 AND $3"
+
+	_, err := tx.Exec(ctx, query, runID, from, to)
+	return err
+}
+
+// ListRunVersions returns every run whose range covers date, newest first,
+// with the row each produced for merchantID in currency. Current marks the
+// run whose row is in the settlements table now.
+func (r *DatabaseSettlementRepository) ListRunVersions(ctx context.Context, merchantID, date, currency string) ([]models.SettlementRunVersion, error) {
+	query := "SELECT r.id, r.job_id, r.kind, r.source_run_id, r.filter, r.from_date, r.to_date, r.created_at, "
+	query += "COALESCE(s.unique_run_id = r.id, false) "
+	query += "FROM settlement_runs r "
+	query += "LEFT JOIN settlements s ON s.merchant_id = $1 AND s.date = $2::date AND s.currency = $3 "
+	query += "WHERE $2::date BETWEEN r.from_date AND r.to_date "
+	query += "ORDER BY r.created_at DESC, r.id DESC"
+
+	rows, err := r.db.Query(ctx, query, merchantID, date, currency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []models.SettlementRunVersion{}
+	for rows.Next() {
+		var v models.SettlementRunVersion
+		var fromDate, toDate time.Time
+		if err := rows.Scan(&v.Run.ID, &v.Run.JobID, &v.Run.Kind, &v.Run.SourceRunID, &v.Run.Filter, &fromDate, &toDate, &v.Run.CreatedAt, &v.Current); err != nil {
+			return nil, err
+		}
+
+		v.Run.From = fromDate.Format("2006-01-02")
+		v.Run.To = toDate.Format("2006-01-02")
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	query = "SELECT run_id, " + settlementRowColumns + " "
+	query += "FROM settlement_run_rows WHERE merchant_id = $1 AND date = $2::date AND currency = $3"
+
+	rows, err = r.db.Query(ctx, query, merchantID, date, currency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byRun := map[string]*models.Settlement{}
+	for rows.Next() {
+		settlement, err := scanRunRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		byRun[settlement.UniqueRunID] = settlement
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range versions {
+		versions[i].Settlement = byRun[versions[i].Run.ID]
+	}
+
+	return versions, nil
+}
+
+// ListJobRunRows returns the rows of the settlement run jobID recorded,
+// ordered by merchant, date and currency.
+func (r *DatabaseSettlementRepository) ListJobRunRows(ctx context.Context, jobID string) ([]models.Settlement, error) {
+	query := "SELECT run_id, " + settlementRowColumns + " "
+	query += "FROM settlement_run_rows WHERE run_id = ("
+	query += "SELECT id FROM settlement_runs WHERE job_id = $1 AND kind = 'JOB' ORDER BY created_at DESC LIMIT 1"
+	query += ") ORDER BY merchant_id, date, currency"
+
+	rows, err := r.db.Query(ctx, query, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settlements := []models.Settlement{}
+	for rows.Next() {
+		settlement, err := scanRunRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		settlements = append(settlements, *settlement)
+	}
+
+	return settlements, rows.Err()
+}
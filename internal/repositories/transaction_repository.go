@@ -2,34 +2,139 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/banggibima/be-assignment/internal/models"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type DatabaseTransactionRepository struct {
-	db *pgxpool.Pool
+	db         *pgxpool.Pool
+	defaultDay models.SettlementDay
 }
 
+// NewDatabaseTransactionRepository returns a repository that buckets
+// transactions into settlement days by each merchant's settlement config,
+// falling back to defaultDay for merchants without one.
 func NewDatabaseTransactionRepository(
 	db *pgxpool.Pool,
+	defaultDay models.SettlementDay,
 ) *DatabaseTransactionRepository {
 	return &DatabaseTransactionRepository{
-		db: db,
+		db:         db,
+		defaultDay: defaultDay,
 	}
 }
 
-func (r *DatabaseTransactionRepository) FetchBatch(ctx context.Context, from, to string, limit, offset int) (pgx.Rows, error) {
-	query := "SELECT id, order_id, merchant_id, amount, fee, status, paid_at, created_at, updated_at "
-	query += "FROM transactions WHERE paid_at BETWEEN $1 AND $2 "
-	query += "ORDER BY paid_at ASC LIMIT $3 OFFSET $4"
+// FetchBatch returns up to limit settlement lines whose settlement day is in
+// [from, to] and whose transaction matches filter, ordered by (occurred_at,
+// transaction id, kind), starting after the given cursor. An empty
+// after.TransactionID starts from the beginning of the range.
+// Unlike LIMIT/OFFSET, the keyset cursor is unique per line, so lines sharing
+// an occurred_at are never skipped or repeated between batches, and later
+// batches cost the same as the first.
+func (r *DatabaseTransactionRepository) FetchBatch(ctx context.Context, from, to string, filter models.TransactionFilter, after models.SettlementLineCursor, limit int) ([]models.SettlementLine, error) {
+	args := []any{from, to}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	payments, reversals := r.settlementLines(filter, arg)
+	if after.TransactionID != "" {
+		kind := after.Kind
+		if kind == "" {
+			kind = models.SettlementLinePayment
+		}
+		payments += " AND t.paid_at >= " + arg(after.OccurredAt) + "::timestamptz"
+		payments += " AND (t.paid_at, t.id, '" + models.SettlementLinePayment + "') > (" + arg(after.OccurredAt) + "::timestamptz, " + arg(after.TransactionID) + ", " + arg(kind) + "::text)"
+		reversals += " AND t.reversed_at >= " + arg(after.OccurredAt) + "::timestamptz"
+		reversals += " AND (t.reversed_at, t.id, " + reversalKind + ") > (" + arg(after.OccurredAt) + "::timestamptz, " + arg(after.TransactionID) + ", " + arg(kind) + "::text)"
+	}
+	limitArg := arg(limit)
+	payments += " ORDER BY t.paid_at ASC, t.id ASC LIMIT " + limitArg
+	reversals += " ORDER BY t.reversed_at ASC, t.id ASC LIMIT " + limitArg
+
+	query := "SELECT kind, id, merchant_id, currency, amount, fee, occurred_at, settlement_date "
+	query += "FROM ((" + payments + ") UNION ALL (" + reversals + ")) l "
+	query += "ORDER BY occurred_at ASC, id ASC, kind ASC LIMIT " + limitArg
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []models.SettlementLine{}
+	for rows.Next() {
+		var l models.SettlementLine
+		if err := rows.Scan(&l.Kind, &l.TransactionID, &l.MerchantID, &l.Currency, &l.Amount, &l.Fee, &l.OccurredAt, &l.SettlementDate); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+
+	return lines, rows.Err()
+}
+
+// AggregateByDay sums the settlement lines of one settlement day matching
+// filter per merchant and currency in the database. The returned settlements
+// only carry merchant, date, currency and totals.
+func (r *DatabaseTransactionRepository) AggregateByDay(ctx context.Context, date string, filter models.TransactionFilter) ([]models.Settlement, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []any{date, date}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	payments, reversals := r.settlementLines(filter, arg)
+	query := "SELECT merchant_id, currency, SUM(amount), SUM(fee), "
+	query += "COUNT(*) FILTER (WHERE kind = '" + models.SettlementLinePayment + "'), "
+	query += "COALESCE(-SUM(amount) FILTER (WHERE kind = '" + models.SettlementLineRefund + "'), 0), "
+	query += "COALESCE(-SUM(amount) FILTER (WHERE kind = '" + models.SettlementLineChargeback + "'), 0), "
+	query += "COUNT(*) FILTER (WHERE kind <> '" + models.SettlementLinePayment + "') "
+	query += "FROM (" + payments + " UNION ALL " + reversals + ") l "
+	query += "GROUP BY merchant_id, currency"
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	return r.db.Query(ctx, query, from, to, limit, offset)
+	settlements := []models.Settlement{}
+	for rows.Next() {
+		s := models.Settlement{Date: day}
+		if err := rows.Scan(&s.MerchantID, &s.Currency, &s.GrossAmount, &s.FeeAmount, &s.TxnCount, &s.RefundAmount, &s.ChargebackAmount, &s.ReversalCount); err != nil {
+			return nil, err
+		}
+		s.NetAmount = s.GrossAmount - s.FeeAmount
+		settlements = append(settlements, s)
+	}
+
+	return settlements, rows.Err()
 }
 
-func (r *DatabaseTransactionRepository) CountByDateRange(ctx context.Context, from, to string) (int, error) {
-	query := "SELECT COUNT(*) FROM transactions WHERE paid_at BETWEEN $1 AND $2"
-	row := r.db.QueryRow(ctx, query, from, to)
+// CountByDateRange counts the settlement lines FetchBatch returns for the
+// same range and filter.
+func (r *DatabaseTransactionRepository) CountByDateRange(ctx context.Context, from, to string, filter models.TransactionFilter) (int, error) {
+	args := []any{from, to}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	payments, reversals := r.settlementLines(filter, arg)
+	query := "SELECT COUNT(*) FROM (" + payments + " UNION ALL " + reversals + ") l"
+	row := r.db.QueryRow(ctx, query, args...)
 
 	var count int
 	err := row.Scan(&count)
@@ -39,3 +144,204 @@ func (r *DatabaseTransactionRepository) CountByDateRange(ctx context.Context, fr
 
 	return count, nil
 }
+
+// CountCurrencyMismatches counts the transactions in [from, to] matching
+// filter whose fee is in another currency than their amount. Such fees
+// cannot be netted against the amount.
+func (r *DatabaseTransactionRepository) CountCurrencyMismatches(ctx context.Context, from, to string, filter models.TransactionFilter) (int, error) {
+	args := []any{from, to}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	query := "SELECT COUNT(*) FROM transactions t LEFT JOIN merchant_settlement_configs c USING (merchant_id) "
+	query += "WHERE " + r.settlementDayRange(arg, "t.paid_at") + " AND t.fee_currency <> t.currency"
+	for _, condition := range transactionConditions(filter, arg) {
+		query += " AND " + condition
+	}
+
+	var count int
+	if err := r.db.QueryRow(ctx, query, args...).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// reversalKind is the SQL expression of the kind of a transaction's reversal
+// line.
+const reversalKind = "(CASE WHEN t.status = '" + models.TransactionStatusChargeback + "' THEN '" + models.SettlementLineChargeback + "' ELSE '" + models.SettlementLineRefund + "' END)"
+
+// settlementLines returns the two SELECTs whose UNION ALL is the settlement
+// lines of the transactions matching filter with a settlement day between $1
+// and $2: one PAYMENT per transaction on its paid_at, and one negative REFUND
+// or CHARGEBACK per reversed transaction on its reversed_at. The platform
+// keeps the fee of a reversed payment, so reversal lines carry no fee. Both
+// SELECTs end in a WHERE clause callers may extend.
+func (r *DatabaseTransactionRepository) settlementLines(filter models.TransactionFilter, arg func(any) string) (string, string) {
+	conditions := ""
+	for _, condition := range transactionConditions(filter, arg) {
+		conditions += " AND " + condition
+	}
+
+	payments := "SELECT '" + models.SettlementLinePayment + "'::text AS kind, t.id, merchant_id, t.currency, t.amount, t.fee, t.paid_at AS occurred_at, " + r.settlementDate(arg, "t.paid_at") + " AS settlement_date "
+	payments += "FROM transactions t LEFT JOIN merchant_settlement_configs c USING (merchant_id) "
+	payments += "WHERE " + r.settlementDayRange(arg, "t.paid_at") + conditions
+
+	reversals := "SELECT " + reversalKind + " AS kind, t.id, merchant_id, t.currency, -t.reversal_amount, 0, t.reversed_at, " + r.settlementDate(arg, "t.reversed_at") + " "
+	reversals += "FROM transactions t LEFT JOIN merchant_settlement_configs c USING (merchant_id) "
+	reversals += "WHERE " + r.settlementDayRange(arg, "t.reversed_at") + conditions
+	reversals += " AND status = ANY(" + arg(models.ReversalStatuses) + ") AND t.reversal_amount > 0"
+
+	return payments, reversals
+}
+
+// settlementDate is the SQL expression of the settlement day of the
+// timestamp column: column in the merchant's time zone, moved to the next day
+// from the cut-off hour. It expects transactions as t joined with their
+// merchant's settlement config as c using merchant_id, which keeps
+// merchant_id unambiguous for transactionConditions.
+func (r *DatabaseTransactionRepository) settlementDate(arg func(any) string, column string) string {
+	timeZone := "COALESCE(c.time_zone, " + arg(r.defaultDay.TimeZone) + "::text)"
+	cutoffHour := "COALESCE(c.cutoff_hour, " + arg(r.defaultDay.CutoffHour) + "::int)"
+
+	return "((" + column + " AT TIME ZONE " + timeZone + ") + make_interval(hours => (24 - " + cutoffHour + ") % 24))::date"
+}
+
+// settlementDayRange matches transactions whose settlement day of column is
+// between $1 and $2. Time zones and cut-offs never move a timestamp more than
+// two days away from its UTC date, so the plain column bounds let its index
+// narrow the scan before the exact settlement day is computed.
+func (r *DatabaseTransactionRepository) settlementDayRange(arg func(any) string, column string) string {
+	condition := column + " >= ($1::date - 2)::timestamp AT TIME ZONE 'UTC' "
+	condition += "AND " + column + " < ($2::date + 3)::timestamp AT TIME ZONE 'UTC' "
+	condition += "AND " + r.settlementDate(arg, column) + " BETWEEN $1::date AND $2::date"
+
+	return condition
+}
+
+// transactionConditions turns filter into WHERE conditions. Empty fields do
+// not filter.
+func transactionConditions(filter models.TransactionFilter, arg func(any) string) []string {
+	conditions := []string{}
+	if len(filter.MerchantIDs) > 0 {
+		conditions = append(conditions, "merchant_id = ANY("+arg(filter.MerchantIDs)+")")
+	}
+	if len(filter.ExcludeMerchantIDs) > 0 {
+		conditions = append(conditions, "NOT (merchant_id = ANY("+arg(filter.ExcludeMerchantIDs)+"))")
+	}
+	if len(filter.Statuses) > 0 {
+		conditions = append(conditions, "status = ANY("+arg(filter.Statuses)+")")
+	}
+
+	return conditions
+}
+
+const transactionColumns = "id, order_id, merchant_id, amount, fee, currency, fee_currency, status, paid_at, reversal_amount, reversed_at, created_at, updated_at"
+
+func (r *DatabaseTransactionRepository) GetByID(ctx context.Context, id string) (*models.Transaction, error) {
+	query := "SELECT " + transactionColumns + " "
+	query += "FROM transactions WHERE id = $1"
+
+	var t models.Transaction
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&t.ID,
+		&t.OrderID,
+		&t.MerchantID,
+		&t.Amount,
+		&t.Fee,
+		&t.Currency,
+		&t.FeeCurrency,
+		&t.Status,
+		&t.PaidAt,
+		&t.ReversalAmount,
+		&t.ReversedAt,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// ListUnknownOrders returns the orderIDs no order exists for.
+func (r *DatabaseTransactionRepository) ListUnknownOrders(ctx context.Context, orderIDs []string) ([]string, error) {
+	query := "SELECT DISTINCT i.id FROM unnest($1::text[]) AS i(id) "
+	query += "WHERE NOT EXISTS (SELECT 1 FROM orders o WHERE o.id = i.id)"
+
+	rows, err := r.db.Query(ctx, query, orderIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	unknown := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		unknown = append(unknown, id)
+	}
+
+	return unknown, rows.Err()
+}
+
+// Insert records transactions in one statement, skipping those whose ID is
+// recorded already, and returns the IDs it inserted.
+func (r *DatabaseTransactionRepository) Insert(ctx context.Context, tx pgx.Tx, transactions []models.Transaction) ([]string, error) {
+	ids := make([]string, len(transactions))
+	orderIDs := make([]string, len(transactions))
+	merchantIDs := make([]string, len(transactions))
+	amounts := make([]int, len(transactions))
+	fees := make([]int, len(transactions))
+	currencies := make([]string, len(transactions))
+	feeCurrencies := make([]string, len(transactions))
+	statuses := make([]string, len(transactions))
+	paidAts := make([]time.Time, len(transactions))
+	reversalAmounts := make([]int, len(transactions))
+	reversedAts := make([]*time.Time, len(transactions))
+	for i, t := range transactions {
+		ids[i] = t.ID
+		orderIDs[i] = t.OrderID
+		merchantIDs[i] = t.MerchantID
+		amounts[i] = t.Amount
+		fees[i] = t.Fee
+		currencies[i] = t.Currency
+		feeCurrencies[i] = t.FeeCurrency
+		statuses[i] = t.Status
+		paidAts[i] = t.PaidAt
+		reversalAmounts[i] = t.ReversalAmount
+		reversedAts[i] = t.ReversedAt
+	}
+
+	query := "INSERT INTO transactions (id, order_id, merchant_id, amount, fee, currency, fee_currency, status, paid_at, reversal_amount, reversed_at, created_at, updated_at) "
+	query += "SELECT id, order_id, merchant_id, amount, fee, currency, fee_currency, status, paid_at, reversal_amount, reversed_at, NOW(), NOW() "
+	query += "FROM unnest($1::text[], $2::text[], $3::text[], $4::int[], $5::int[], $6::text[], $7::text[], $8::text[], $9::timestamptz[], $10::int[], $11::timestamptz[]) "
+	query += "AS t(id, order_id, merchant_id, amount, fee, currency, fee_currency, status, paid_at, reversal_amount, reversed_at) "
+	query += "ON CONFLICT (id) DO NOTHING "
+	query += "RETURNING id"
+
+	rows, err := tx.Query(ctx, query, ids, orderIDs, merchantIDs, amounts, fees, currencies, feeCurrencies, statuses, paidAts, reversalAmounts, reversedAts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inserted := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		inserted = append(inserted, id)
+	}
+
+	return inserted, rows.Err()
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/banggibima/be-assignment/internal/models"
+)
+
+// detectStatementFormat picks the format of a statement from its file
+// extension, or from its content when the extension says nothing.
+func detectStatementFormat(fileName string, content []byte) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return models.BankStatementCSV
+	case ".sta", ".mt940", ".940":
+		return models.BankStatementMT940
+	}
+
+	trimmed := bytes.TrimSpace(content)
+	if bytes.HasPrefix(trimmed, []byte(":20:")) || bytes.Contains(trimmed, []byte("\n:61:")) {
+		return models.BankStatementMT940
+	}
+	return models.BankStatementCSV
+}
+
+func parseStatement(format string, content []byte, currency string) ([]models.BankStatementLine, error) {
+	switch format {
+	case models.BankStatementCSV:
+		return parseCSVStatement(content, currency)
+	case models.BankStatementMT940:
+		return parseMT940Statement(content, currency)
+	default:
+		return nil, fmt.Errorf("unknown statement format %q", format)
+	}
+}
+
+// parseCSVStatement reads a CSV statement with a header row. It needs a date
+// column (date, value_date or booking_date, YYYY-MM-DD) and either a signed
+// amount column or credit and debit columns. Currency, reference and
+// description columns are optional; lines without a currency are in currency.
+func parseCSVStatement(content []byte, currency string) ([]models.BankStatementLine, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("statement is empty")
+		}
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case "value_date", "booking_date":
+			name = "date"
+		case "ref":
+			name = "reference"
+		case "narrative":
+			name = "description"
+		}
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+	}
+
+	if _, ok := columns["date"]; !ok {
+		return nil, fmt.Errorf("header has no date column")
+	}
+	_, hasAmount := columns["amount"]
+	_, hasCredit := columns["credit"]
+	_, hasDebit := columns["debit"]
+	if !hasAmount && !(hasCredit && hasDebit) {
+		return nil, fmt.Errorf("header needs an amount column or credit and debit columns")
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	lines := []models.BankStatementLine{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		lineNo, _ := reader.FieldPos(0)
+
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		valueDate, err := time.Parse("2006-01-02", field(record, "date"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: date must be YYYY-MM-DD", lineNo)
+		}
+
+		var amount int
+		if hasAmount {
+			amount, err = parseStatementAmount(field(record, "amount"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		} else {
+			credit, debit := field(record, "credit"), field(record, "debit")
+			if (credit == "") == (debit == "") {
+				return nil, fmt.Errorf("line %d: exactly one of credit and debit must be set", lineNo)
+			}
+			if credit != "" {
+				amount, err = parseStatementAmount(credit)
+			} else {
+				amount, err = parseStatementAmount(debit)
+				amount = -amount
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		}
+
+		lineCurrency := strings.ToUpper(field(record, "currency"))
+		if lineCurrency == "" {
+			lineCurrency = currency
+		}
+
+		lines = append(lines, models.BankStatementLine{
+			LineNo:      lineNo,
+			ValueDate:   valueDate,
+			Amount:      amount,
+			Currency:    lineCurrency,
+			Reference:   field(record, "reference"),
+			Description: field(record, "description"),
+		})
+	}
+
+	return lines, nil
+}
+
+// parseMT940Statement reads the :61: statement lines of an MT940-style
+// statement, with the :86: information that follows each of them as its
+// description. The currency comes from the opening balance (:60F: or :60M:)
+// and defaults to currency. Of the :61: reference, the part for the account
+// owner is used, or the bank's reference after // when it is NONREF.
+func parseMT940Statement(content []byte, currency string) ([]models.BankStatementLine, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	lines := []models.BankStatementLine{}
+	// field is the tag whose continuation lines follow, so they are only
+	// appended to the description of :86:.
+	field := ""
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "-}") {
+			continue
+		}
+
+		if !strings.HasPrefix(text, ":") {
+			if field == "86" && len(lines) > 0 {
+				last := &lines[len(lines)-1]
+				last.Description = strings.TrimSpace(last.Description + " " + strings.TrimSpace(text))
+			}
+			continue
+		}
+
+		tag, value, ok := strings.Cut(text[1:], ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: malformed field %q", lineNo, text)
+		}
+		field = tag
+
+		switch tag {
+		case "60F", "60M":
+			// [C|D]YYMMDD, then the three letter currency.
+			if len(value) < 10 {
+				return nil, fmt.Errorf("line %d: malformed opening balance", lineNo)
+			}
+			currency = strings.ToUpper(value[7:10])
+		case "61":
+			line, err := parseMT940StatementLine(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			line.LineNo = lineNo
+			line.Currency = currency
+			lines = append(lines, line)
+		case "86":
+			if len(lines) > 0 {
+				lines[len(lines)-1].Description = strings.TrimSpace(value)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// parseMT940StatementLine parses the value of a :61: field:
+// YYMMDD[MMDD](C|D|RC|RD)[funds code]amount(type)reference[//bank reference].
+func parseMT940StatementLine(value string) (models.BankStatementLine, error) {
+	var line models.BankStatementLine
+
+	if len(value) < 6 {
+		return line, fmt.Errorf("statement line is too short")
+	}
+	valueDate, err := time.Parse("060102", value[:6])
+	if err != nil {
+		return line, fmt.Errorf("value date must be YYMMDD")
+	}
+	line.ValueDate = valueDate
+	rest := value[6:]
+
+	// The optional entry date is four more digits.
+	if len(rest) >= 4 && isDigits(rest[:4]) {
+		rest = rest[4:]
+	}
+
+	sign := 0
+	for _, mark := range []struct {
+		prefix string
+		sign   int
+	}{{"RC", -1}, {"RD", 1}, {"C", 1}, {"D", -1}} {
+		if strings.HasPrefix(rest, mark.prefix) {
+			sign = mark.sign
+			rest = rest[len(mark.prefix):]
+			break
+		}
+	}
+	if sign == 0 {
+		return line, fmt.Errorf("debit/credit mark must be C, D, RC or RD")
+	}
+
+	// The optional funds code is the third letter of the currency.
+	if rest != "" && (rest[0] < '0' || rest[0] > '9') {
+		rest = rest[1:]
+	}
+
+	end := strings.IndexFunc(rest, func(r rune) bool { return (r < '0' || r > '9') && r != ',' })
+	if end < 0 {
+		end = len(rest)
+	}
+	if end == 0 {
+		return line, fmt.Errorf("amount is missing")
+	}
+	amount, err := parseStatementAmount(rest[:end])
+	if err != nil {
+		return line, err
+	}
+	line.Amount = sign * amount
+	rest = rest[end:]
+
+	// The transaction type is four characters, such as NTRF.
+	if len(rest) < 4 {
+		return line, fmt.Errorf("transaction type is missing")
+	}
+	rest = rest[4:]
+
+	reference, bankReference, _ := strings.Cut(rest, "//")
+	reference = strings.TrimSpace(reference)
+	if reference == "" || strings.EqualFold(reference, "NONREF") {
+		reference = strings.TrimSpace(bankReference)
+	}
+	line.Reference = reference
+
+	return line, nil
+}
+
+// parseStatementAmount parses an amount in the platform's integer units. It
+// may have a sign and decimals after a dot or comma, which must be zero.
+func parseStatementAmount(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	whole, decimals, found := strings.Cut(value, ",")
+	if !found {
+		whole, decimals, _ = strings.Cut(value, ".")
+	}
+	if strings.Trim(decimals, "0") != "" {
+		return 0, fmt.Errorf("amount %q has a fractional part", value)
+	}
+	if whole == "" || whole == "-" || whole == "+" {
+		return 0, fmt.Errorf("amount %q is not a number", value)
+	}
+
+	amount, err := strconv.Atoi(whole)
+	if err != nil {
+		return 0, fmt.Errorf("amount %q is not a number", value)
+	}
+
+	return amount, nil
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
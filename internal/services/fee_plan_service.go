@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrFeePlanNotFound       = errors.New("FEE_PLAN_NOT_FOUND")
+	ErrInvalidFeePlanRequest = errors.New("INVALID_FEE_PLAN_REQUEST")
+)
+
+type FeePlanRepository interface {
+	Create(ctx context.Context, plan *models.FeePlan) error
+	GetByID(ctx context.Context, id string) (*models.FeePlan, error)
+	List(ctx context.Context) ([]models.FeePlan, error)
+	Assign(ctx context.Context, assignment *models.MerchantFeePlan) error
+	ListAssignments(ctx context.Context, merchantID string) ([]models.MerchantFeePlan, error)
+	ListEffective(ctx context.Context, merchantIDs []string, from, to string) ([]models.MerchantFeePlan, error)
+}
+
+// FeePlanService manages fee plans and their assignment to merchants.
+// Settlement jobs evaluate the assigned plans against each day's volume.
+type FeePlanService struct {
+	feePlanRepo FeePlanRepository
+}
+
+func NewFeePlanService(feePlanRepo FeePlanRepository) *FeePlanService {
+	return &FeePlanService{
+		feePlanRepo: feePlanRepo,
+	}
+}
+
+func (s *FeePlanService) CreatePlan(ctx context.Context, req dto.CreateFeePlanRequest) (*dto.FeePlanResponse, error) {
+	if req.MinFee != nil && req.MaxFee != nil && *req.MinFee > *req.MaxFee {
+		return nil, fmt.Errorf("%w: min_fee must not exceed max_fee", ErrInvalidFeePlanRequest)
+	}
+
+	plan := &models.FeePlan{
+		ID:            uuid.New().String(),
+		Name:          req.Name,
+		PercentageBps: req.PercentageBps,
+		FixedFee:      req.FixedFee,
+		MinFee:        req.MinFee,
+		MaxFee:        req.MaxFee,
+		Tiers:         []models.FeePlanTier{},
+	}
+
+	seen := make(map[int]bool, len(req.Tiers))
+	for _, tier := range req.Tiers {
+		if seen[tier.MinVolume] {
+			return nil, fmt.Errorf("%w: duplicate tier min_volume %d", ErrInvalidFeePlanRequest, tier.MinVolume)
+		}
+		seen[tier.MinVolume] = true
+		plan.Tiers = append(plan.Tiers, models.FeePlanTier{
+			MinVolume:     tier.MinVolume,
+			PercentageBps: tier.PercentageBps,
+			FixedFee:      tier.FixedFee,
+		})
+	}
+	sort.Slice(plan.Tiers, func(i, j int) bool {
+		return plan.Tiers[i].MinVolume < plan.Tiers[j].MinVolume
+	})
+
+	if err := s.feePlanRepo.Create(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	return toFeePlanResponse(plan), nil
+}
+
+func (s *FeePlanService) ListPlans(ctx context.Context) ([]dto.FeePlanResponse, error) {
+	plans, err := s.feePlanRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.FeePlanResponse, 0, len(plans))
+	for i := range plans {
+		res = append(res, *toFeePlanResponse(&plans[i]))
+	}
+
+	return res, nil
+}
+
+func (s *FeePlanService) GetPlan(ctx context.Context, id string) (*dto.FeePlanResponse, error) {
+	plan, err := s.feePlanRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, ErrFeePlanNotFound
+		}
+		return nil, err
+	}
+
+	return toFeePlanResponse(plan), nil
+}
+
+// AssignPlan puts merchantID on a fee plan from req.EffectiveFrom. Settlements
+// already saved keep their plan fee until their range is settled again.
+func (s *FeePlanService) AssignPlan(ctx context.Context, merchantID string, req dto.AssignFeePlanRequest) (*dto.MerchantFeePlanResponse, error) {
+	effectiveFrom, err := time.Parse("2006-01-02", req.EffectiveFrom)
+	if err != nil {
+		return nil, fmt.Errorf("%w: effective_from must be YYYY-MM-DD", ErrInvalidFeePlanRequest)
+	}
+	if req.EffectiveTo != nil {
+		effectiveTo, err := time.Parse("2006-01-02", *req.EffectiveTo)
+		if err != nil {
+			return nil, fmt.Errorf("%w: effective_to must be YYYY-MM-DD", ErrInvalidFeePlanRequest)
+		}
+		if effectiveTo.Before(effectiveFrom) {
+			return nil, fmt.Errorf("%w: effective_to must not be before effective_from", ErrInvalidFeePlanRequest)
+		}
+	}
+
+	plan, err := s.feePlanRepo.GetByID(ctx, req.PlanID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, ErrFeePlanNotFound
+		}
+		return nil, err
+	}
+
+	assignment := &models.MerchantFeePlan{
+		MerchantID:    merchantID,
+		PlanID:        plan.ID,
+		EffectiveFrom: req.EffectiveFrom,
+		EffectiveTo:   req.EffectiveTo,
+		Plan:          plan,
+	}
+	if err := s.feePlanRepo.Assign(ctx, assignment); err != nil {
+		return nil, err
+	}
+
+	return toMerchantFeePlanResponse(assignment), nil
+}
+
+func (s *FeePlanService) ListMerchantPlans(ctx context.Context, merchantID string) ([]dto.MerchantFeePlanResponse, error) {
+	assignments, err := s.feePlanRepo.ListAssignments(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.MerchantFeePlanResponse, 0, len(assignments))
+	for i := range assignments {
+		res = append(res, *toMerchantFeePlanResponse(&assignments[i]))
+	}
+
+	return res, nil
+}
+
+// effectivePlan returns the plan merchantID was on for date: the assignment
+// with the latest effective_from on or before date that has not ended yet.
+func effectivePlan(assignments []models.MerchantFeePlan, merchantID, date string) *models.FeePlan {
+	var found *models.MerchantFeePlan
+	for i := range assignments {
+		a := &assignments[i]
+		if a.MerchantID != merchantID || a.EffectiveFrom > date {
+			continue
+		}
+		if a.EffectiveTo != nil && *a.EffectiveTo < date {
+			continue
+		}
+		if found == nil || a.EffectiveFrom > found.EffectiveFrom {
+			found = a
+		}
+	}
+
+	if found == nil {
+		return nil
+	}
+	return found.Plan
+}
+
+// planFee computes the fee plan charges on one merchant's day of gross
+// volume over txnCount transactions. The highest tier reached replaces the
+// base rate, and the caps apply to the day's total fee.
+func planFee(plan *models.FeePlan, gross, txnCount int) int {
+	bps := plan.PercentageBps
+	fixed := plan.FixedFee
+	for _, tier := range plan.Tiers {
+		if gross >= tier.MinVolume {
+			bps = tier.PercentageBps
+			fixed = tier.FixedFee
+		}
+	}
+
+	fee := (gross*bps+5000)/10000 + fixed*txnCount
+	if plan.MinFee != nil && fee < *plan.MinFee {
+		fee = *plan.MinFee
+	}
+	if plan.MaxFee != nil && fee > *plan.MaxFee {
+		fee = *plan.MaxFee
+	}
+
+	return fee
+}
+
+func toFeePlanResponse(plan *models.FeePlan) *dto.FeePlanResponse {
+	res := &dto.FeePlanResponse{
+		ID:            plan.ID,
+		Name:          plan.Name,
+		PercentageBps: plan.PercentageBps,
+		FixedFee:      plan.FixedFee,
+		MinFee:        plan.MinFee,
+		MaxFee:        plan.MaxFee,
+		Tiers:         make([]dto.FeePlanTierResponse, 0, len(plan.Tiers)),
+		CreatedAt:     plan.CreatedAt,
+		UpdatedAt:     plan.UpdatedAt,
+	}
+	for _, tier := range plan.Tiers {
+		res.Tiers = append(res.Tiers, dto.FeePlanTierResponse{
+			MinVolume:     tier.MinVolume,
+			PercentageBps: tier.PercentageBps,
+			FixedFee:      tier.FixedFee,
+		})
+	}
+
+	return res
+}
+
+func toMerchantFeePlanResponse(assignment *models.MerchantFeePlan) *dto.MerchantFeePlanResponse {
+	res := &dto.MerchantFeePlanResponse{
+		MerchantID:    assignment.MerchantID,
+		PlanID:        assignment.PlanID,
+		EffectiveFrom: assignment.EffectiveFrom,
+		EffectiveTo:   assignment.EffectiveTo,
+		CreatedAt:     assignment.CreatedAt,
+		UpdatedAt:     assignment.UpdatedAt,
+	}
+	if assignment.Plan != nil {
+		res.Plan = toFeePlanResponse(assignment.Plan)
+	}
+
+	return res
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/banggibima/be-assignment/internal/models"
+)
+
+type JobEventRepository interface {
+	Publish(ctx context.Context, event models.JobEvent) error
+	Listen(ctx context.Context, handle func(models.JobEvent)) error
+}
+
+// JobEventService fans job events out to local subscribers. Events are
+// published through Postgres so subscribers on every instance receive them,
+// whichever instance runs the job.
+type JobEventService struct {
+	eventRepo   JobEventRepository
+	subscribers map[string]map[chan models.JobEvent]struct{}
+	mu          sync.Mutex
+}
+
+func NewJobEventService(eventRepo JobEventRepository) *JobEventService {
+	return &JobEventService{
+		eventRepo:   eventRepo,
+		subscribers: make(map[string]map[chan models.JobEvent]struct{}),
+	}
+}
+
+// Run listens for events until ctx is done, reconnecting after failures.
+func (s *JobEventService) Run(ctx context.Context) {
+	for {
+		err := s.eventRepo.Listen(ctx, s.dispatch)
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Printf("[JobEvents] listener stopped, reconnecting: %v\n", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (s *JobEventService) Publish(ctx context.Context, event models.JobEvent) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	if err := s.eventRepo.Publish(ctx, event); err != nil {
+		fmt.Printf("[JobEvents] failed to publish %s event for job %s: %v\n", event.Type, event.JobID, err)
+	}
+}
+
+// Subscribe returns a channel of events for jobID and a function that must
+// be called to unsubscribe. Slow subscribers miss events rather than block
+// the listener.
+func (s *JobEventService) Subscribe(jobID string) (<-chan models.JobEvent, func()) {
+	ch := make(chan models.JobEvent, 16)
+
+	s.mu.Lock()
+	if s.subscribers[jobID] == nil {
+		s.subscribers[jobID] = make(map[chan models.JobEvent]struct{})
+	}
+	s.subscribers[jobID][ch] = struct{}{}
+	s.mu.Unlock()
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.subscribers[jobID], ch)
+		if len(s.subscribers[jobID]) == 0 {
+			delete(s.subscribers, jobID)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+func (s *JobEventService) dispatch(event models.JobEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.subscribers[event.JobID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -1,34 +1,88 @@
 package services
 
 import (
+	"bytes"
 	"context"
-	"encoding/csv"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 	"runtime"
-	"strconv"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/banggibima/be-assignment/config"
 	"github.com/banggibima/be-assignment/internal/dto"
 	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/banggibima/be-assignment/pkg/storage"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var (
+	ErrInvalidJobQuery   = errors.New("INVALID_JOB_QUERY")
+	ErrInvalidJobRequest = errors.New("INVALID_JOB_REQUEST")
+	ErrJobNotFound       = errors.New("JOB_NOT_FOUND")
+	ErrReportNotFound    = errors.New("REPORT_NOT_FOUND")
+)
+
+// errJobNotOwned means the worker's guarded write touched no row: the job was
+// cancelled, or reaped and handed to another worker.
+var errJobNotOwned = errors.New("job is no longer owned by this worker")
+
+// errJobStopped means processing stopped and the job state was already
+// settled (cancelled, released or taken over), so there is nothing to fail.
+var errJobStopped = errors.New("job stopped")
+
 type SettlementRepository interface {
-	UpsertJob(ctx context.Context, tx pgx.Tx, runID, merchantID, date string, gross, fee, net, txnCount int) error
+	CreateRun(ctx context.Context, tx pgx.Tx, run *models.SettlementRun) error
+	AddRunRow(ctx context.Context, tx pgx.Tx, runID, merchantID, date, currency string, settlement models.SettlementAggregate) error
+	ReplaceFromRun(ctx context.Context, tx pgx.Tx, runID, from, to string, filter *models.TransactionFilter) error
+	Lock(ctx context.Context, tx pgx.Tx) error
+	ListCarryForward(ctx context.Context, tx pgx.Tx, date string) ([]models.Settlement, error)
+	HasLaterSettlements(ctx context.Context, tx pgx.Tx, runID, from, to string, filter *models.TransactionFilter) (bool, error)
+	ListJobRunRows(ctx context.Context, jobID string) ([]models.Settlement, error)
 }
 
 type JobRepository interface {
 	Create(ctx context.Context, tx pgx.Tx, job *models.Job) error
-	UpdateProgress(ctx context.Context, tx pgx.Tx, jobID string, processed, progress int) error
-	MarkDone(ctx context.Context, tx pgx.Tx, jobID, resultPath string) error
-	MarkCancelled(ctx context.Context, tx pgx.Tx, jobID string) error
+	UpdateProgress(ctx context.Context, tx pgx.Tx, jobID, workerID string, processed, progress int) (bool, error)
+	MarkDone(ctx context.Context, tx pgx.Tx, jobID, workerID, resultPath string, manifest *models.ReportManifest) (bool, error)
+	MarkCancelled(ctx context.Context, tx pgx.Tx, jobID, workerID string) error
 	GetByID(ctx context.Context, jobID string) (*models.Job, error)
+	ClaimNext(ctx context.Context, workerID string) (*models.Job, error)
+	CancelActive(ctx context.Context, jobID string) (bool, error)
+	Heartbeat(ctx context.Context, jobID, workerID string) (bool, error)
+	MarkFailed(ctx context.Context, jobID, workerID, reason string, retryable bool, policy models.RetryPolicy) (string, error)
+	ReapExpired(ctx context.Context, ttl time.Duration, policy models.RetryPolicy) ([]models.Job, error)
+	List(ctx context.Context, filter models.JobFilter) ([]models.Job, error)
+	SaveCheckpoint(ctx context.Context, jobID, workerID string, checkpoint *models.JobCheckpoint) (bool, error)
+	Release(ctx context.Context, jobID, workerID string) error
+	Resume(ctx context.Context, jobID string) (bool, error)
+	GetForUpdate(ctx context.Context, tx pgx.Tx, jobID string) (*models.Job, error)
+	ListPartitions(ctx context.Context, tx pgx.Tx, parentJobID string) ([]models.Job, error)
+	Detach(ctx context.Context, jobID, workerID string) (bool, error)
+	ResumePartitions(ctx context.Context, parentJobID string) (int, error)
+	RollupProgress(ctx context.Context, parentJobID string) (*models.Job, error)
+	MarkMerged(ctx context.Context, tx pgx.Tx, jobID, resultPath string, manifest *models.ReportManifest, checkpoint *models.JobCheckpoint) error
+	MarkParentDead(ctx context.Context, partitionJobID, reason string) (string, error)
+}
+
+type JobEventPublisher interface {
+	Publish(ctx context.Context, event models.JobEvent)
+}
+
+// ArtifactStorage keeps the files jobs produce, such as settlement reports,
+// where every replica can serve them.
+type ArtifactStorage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
 }
 
 type JobService struct {
@@ -36,9 +90,22 @@ type JobService struct {
 	jobRepo         JobRepository
 	transactionRepo TransactionRepository
 	settlementRepo  SettlementRepository
-	jobQueue        chan string
+	feePlanRepo     FeePlanRepository
+	ledger          LedgerPoster
+	artifacts       ArtifactStorage
+	events          JobEventPublisher
+	wakeup          chan struct{}
 	cancelSignals   map[string]chan struct{}
+	instanceID      string
 	workers         int
+	pollInterval    time.Duration
+	leaseTTL        time.Duration
+	heartbeat       time.Duration
+	reapInterval    time.Duration
+	retryPolicy     models.RetryPolicy
+	progressRows    int
+	progressEvery   time.Duration
+	partitionDays   int
 	mu              sync.Mutex
 }
 
@@ -47,16 +114,82 @@ func NewJobService(
 	jobRepo JobRepository,
 	transactionRepo TransactionRepository,
 	settlementRepo SettlementRepository,
+	feePlanRepo FeePlanRepository,
+	ledger LedgerPoster,
+	artifacts ArtifactStorage,
+	events JobEventPublisher,
+	cfg config.Job,
 ) *JobService {
-	numCPU := runtime.NumCPU()
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	pollInterval := cfg.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+	leaseTTL := cfg.LeaseTTL
+	if leaseTTL <= 0 {
+		leaseTTL = 30 * time.Second
+	}
+	heartbeat := cfg.HeartbeatInterval
+	if heartbeat <= 0 || heartbeat >= leaseTTL {
+		heartbeat = leaseTTL / 3
+	}
+	reapInterval := cfg.ReapInterval
+	if reapInterval <= 0 {
+		reapInterval = leaseTTL / 2
+	}
+	retryPolicy := models.RetryPolicy{
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   cfg.RetryBaseDelay,
+		MaxDelay:    cfg.RetryMaxDelay,
+	}
+	if retryPolicy.MaxAttempts <= 0 {
+		retryPolicy.MaxAttempts = 3
+	}
+	if retryPolicy.BaseDelay <= 0 {
+		retryPolicy.BaseDelay = 5 * time.Second
+	}
+	if retryPolicy.MaxDelay < retryPolicy.BaseDelay {
+		retryPolicy.MaxDelay = retryPolicy.BaseDelay
+	}
+
+	progressRows := cfg.ProgressFlushRows
+	if progressRows <= 0 {
+		progressRows = 1000
+	}
+	progressEvery := cfg.ProgressFlushInterval
+	if progressEvery <= 0 {
+		progressEvery = time.Second
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
 	return &JobService{
 		db:              db,
 		jobRepo:         jobRepo,
 		transactionRepo: transactionRepo,
 		settlementRepo:  settlementRepo,
-		jobQueue:        make(chan string, 10),
+		feePlanRepo:     feePlanRepo,
+		ledger:          ledger,
+		artifacts:       artifacts,
+		events:          events,
+		wakeup:          make(chan struct{}, workers),
 		cancelSignals:   make(map[string]chan struct{}),
-		workers:         numCPU,
+		instanceID:      fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		workers:         workers,
+		pollInterval:    pollInterval,
+		leaseTTL:        leaseTTL,
+		heartbeat:       heartbeat,
+		reapInterval:    reapInterval,
+		retryPolicy:     retryPolicy,
+		progressRows:    progressRows,
+		progressEvery:   progressEvery,
+		partitionDays:   cfg.PartitionDays,
 	}
 }
 
@@ -64,246 +197,1489 @@ func (s *JobService) StartWorkerPool(ctx context.Context) {
 	for i := 0; i < s.workers; i++ {
 		go s.worker(ctx, i)
 	}
-	fmt.Printf("[JobWorker] %d workers started\n", s.workers)
+	go s.reaper(ctx)
+	fmt.Printf("[JobWorker] %d workers started on %s\n", s.workers, s.instanceID)
 }
 
-func (s *JobService) worker(ctx context.Context, workerID int) {
-	for jobID := range s.jobQueue {
-		s.mu.Lock()
-		cancelChan := make(chan struct{})
-		s.cancelSignals[jobID] = cancelChan
-		s.mu.Unlock()
+// reaper periodically recovers jobs whose worker stopped heartbeating, most
+// likely because its process died. Every instance runs one; the UPDATEs are
+// idempotent so concurrent reapers do not conflict.
+func (s *JobService) reaper(ctx context.Context) {
+	ticker := time.NewTicker(s.reapInterval)
+	defer ticker.Stop()
 
-		fmt.Printf("[Worker-%d] Start processing job %s\n", workerID, jobID)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
-		job, err := s.jobRepo.GetByID(ctx, jobID)
+		jobs, err := s.jobRepo.ReapExpired(ctx, s.leaseTTL, s.retryPolicy)
 		if err != nil {
-			fmt.Printf("job %s not found: %v\n", jobID, err)
+			if ctx.Err() == nil {
+				fmt.Printf("[JobReaper] failed to reap expired jobs: %v\n", err)
+			}
 			continue
 		}
-		if job.From == "" || job.To == "" {
-			fmt.Printf("job %s has empty from/to: %q/%q\n", jobID, job.From, job.To)
-			s.jobRepo.MarkCancelled(ctx, nil, jobID)
-			continue
+		for _, job := range jobs {
+			fmt.Printf("[JobReaper] Job %s %s: %s\n", job.JobID, job.Status, *job.LastError)
+			s.publishStatus(job.JobID, job.Status, job.LastError)
+			if job.Status == "DEAD" {
+				s.failParent(job.JobID, *job.LastError)
+			}
 		}
+	}
+}
+
+func (s *JobService) notifyWorkers() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// worker claims QUEUED jobs from the jobs table until ctx is done. It sleeps
+// for pollInterval when the queue is empty, or less if CreateJob wakes it up.
+func (s *JobService) worker(ctx context.Context, workerID int) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
 
-		folder := "/tmp/settlements"
-		if err := os.MkdirAll(folder, 0o755); err != nil {
-			fmt.Printf("failed to create folder: %v\n", err)
-			s.jobRepo.MarkCancelled(ctx, nil, jobID)
+	workerName := fmt.Sprintf("%s/%d", s.instanceID, workerID)
+
+	for {
+		job, err := s.jobRepo.ClaimNext(ctx, workerName)
+		if err == nil {
+			s.process(ctx, workerID, workerName, job)
 			continue
 		}
+		if !errors.Is(err, repositories.ErrNotFound) && ctx.Err() == nil {
+			fmt.Printf("[Worker-%d] failed to claim job: %v\n", workerID, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wakeup:
+		case <-ticker.C:
+		}
+	}
+}
+
+// keepLease renews the lease on jobID until ctx is done and calls lost when
+// the lease can no longer be renewed, so the caller stops doing work that
+// another worker may already have taken over.
+func (s *JobService) keepLease(ctx context.Context, jobID, workerName string, lost func()) {
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
-		path := filepath.Join(folder, job.JobID+".csv")
-		file, err := os.Create(path)
+		owned, err := s.jobRepo.Heartbeat(ctx, jobID, workerName)
 		if err != nil {
-			fmt.Printf("failed to create CSV: %v\n", err)
-			s.jobRepo.MarkCancelled(ctx, nil, jobID)
+			if ctx.Err() == nil {
+				fmt.Printf("[%s] heartbeat for job %s failed: %v\n", workerName, jobID, err)
+			}
 			continue
 		}
-		writer := csv.NewWriter(file)
-		writer.Write([]string{"merchant_id", "date", "gross", "fee", "net", "txn_count"})
+		if !owned {
+			fmt.Printf("[%s] lost lease on job %s\n", workerName, jobID)
+			lost()
+			return
+		}
+	}
+}
 
-		settlementsMap := make(map[string]*models.Settlement)
+func (s *JobService) process(ctx context.Context, workerID int, workerName string, job *models.Job) {
+	jobID := job.JobID
 
-		total, _ := s.transactionRepo.CountByDateRange(ctx, job.From, job.To)
-		processed := 0
-		limit := 5000
-		offset := 0
+	if s.shouldPartition(job) {
+		s.partition(ctx, workerID, workerName, job)
+		return
+	}
 
-		for {
-			select {
-			case <-cancelChan:
-				fmt.Println("[Worker] Job cancelled")
-				s.jobRepo.MarkCancelled(ctx, nil, jobID)
-				file.Close()
-				return
-			default:
-			}
+	leaseCtx, stopLease := context.WithCancel(ctx)
+	defer stopLease()
 
-			rows, err := s.transactionRepo.FetchBatch(ctx, job.From, job.To, limit, offset)
-			if err != nil {
-				fmt.Printf("failed to fetch batch: %v\n", err)
-				s.jobRepo.MarkCancelled(ctx, nil, jobID)
-				file.Close()
-				return
-			}
+	leaseLost := make(chan struct{})
+	go s.keepLease(leaseCtx, jobID, workerName, func() { close(leaseLost) })
 
-			count := 0
-			for rows.Next() {
-				var txnID, orderID, merchantID, status string
-				var amount, fee int
-				var paidAt time.Time
+	s.mu.Lock()
+	cancelChan := make(chan struct{})
+	s.cancelSignals[jobID] = cancelChan
+	s.mu.Unlock()
 
-				if err := rows.Scan(&txnID, &orderID, &merchantID, &amount, &fee, &status, &paidAt, new(interface{}), new(interface{})); err != nil {
-					fmt.Printf("row scan error: %v\n", err)
-					continue
-				}
+	defer func() {
+		s.mu.Lock()
+		delete(s.cancelSignals, jobID)
+		s.mu.Unlock()
+	}()
 
-				if status != "PAID" {
-					continue
-				}
+	fmt.Printf("[Worker-%d] Start processing job %s\n", workerID, jobID)
 
-				date := paidAt.Format("2006-01-02")
-				key := merchantID + "|" + date
-				if settlement, ok := settlementsMap[key]; ok {
-					settlement.GrossAmount += amount
-					settlement.FeeAmount += fee
-					settlement.NetAmount += amount - fee
-					settlement.TxnCount++
-				} else {
-					settlementsMap[key] = &models.Settlement{
-						GrossAmount: amount,
-						FeeAmount:   fee,
-						NetAmount:   amount - fee,
-						TxnCount:    1,
-					}
-				}
+	if job.From == "" || job.To == "" {
+		s.fail(jobID, workerName, fmt.Errorf("job has empty from/to: %q/%q", job.From, job.To), false)
+		return
+	}
 
-				processed++
-				count++
+	checkpoint := job.Checkpoint
+	if checkpoint == nil {
+		checkpoint = &models.JobCheckpoint{}
+	}
+	rekeyed := make(map[string]*models.SettlementAggregate, len(checkpoint.Settlements))
+	mergeSettlements(rekeyed, checkpoint.Settlements)
+	checkpoint.Settlements = rekeyed
+	if checkpoint.CursorPaidAt != "" {
+		fmt.Printf("[Worker-%d] Resuming job %s after %s/%s (%d processed)\n", workerID, jobID, checkpoint.CursorPaidAt, checkpoint.CursorID, checkpoint.Processed)
+	}
 
-				progress := (processed * 100) / total
-				s.jobRepo.UpdateProgress(ctx, nil, jobID, processed, progress)
-			}
-			rows.Close()
-			if count < limit {
-				break
-			}
-			offset += limit
+	mismatches, err := s.transactionRepo.CountCurrencyMismatches(ctx, job.From, job.To, transactionFilter(job))
+	if err != nil {
+		s.fail(jobID, workerName, fmt.Errorf("failed to validate currencies: %w", err), true)
+		return
+	}
+	if mismatches > 0 {
+		s.fail(jobID, workerName, fmt.Errorf("%d transactions have a fee in another currency than their amount", mismatches), false)
+		return
+	}
+
+	total, err := s.transactionRepo.CountByDateRange(ctx, job.From, job.To, transactionFilter(job))
+	if err != nil {
+		s.fail(jobID, workerName, fmt.Errorf("failed to count transactions: %w", err), true)
+		return
+	}
+
+	s.publish(models.JobEvent{JobID: jobID, Type: "status", Status: "RUNNING", Processed: checkpoint.Processed, Total: total, Progress: percent(checkpoint.Processed, total)})
+
+	run := &jobRun{
+		job:        job,
+		workerName: workerName,
+		checkpoint: checkpoint,
+		total:      total,
+		cancel:     cancelChan,
+		leaseLost:  leaseLost,
+	}
+	run.reporter = NewProgressReporter(s.progressRows, s.progressEvery, checkpoint.Processed, func(processed int) error {
+		owned, err := s.jobRepo.UpdateProgress(ctx, nil, jobID, workerName, processed, percent(processed, total))
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return errJobNotOwned
 		}
+		s.publish(models.JobEvent{JobID: jobID, Type: "progress", Status: "RUNNING", Processed: processed, Total: total, Progress: percent(processed, total)})
+		if job.ParentJobID != nil {
+			s.rollupProgress(ctx, *job.ParentJobID)
+		}
+		return nil
+	})
 
-		for key, settlement := range settlementsMap {
-			parts := strings.Split(key, "|")
-			record := []string{
-				parts[0], parts[1],
-				strconv.Itoa(settlement.GrossAmount),
-				strconv.Itoa(settlement.FeeAmount),
-				strconv.Itoa(settlement.NetAmount),
-				strconv.Itoa(settlement.TxnCount),
-			}
-			writer.Write(record)
+	if job.Aggregation == "DATABASE" {
+		err = s.aggregateInDatabase(ctx, run)
+	} else {
+		err = s.aggregateStream(ctx, run)
+	}
+	if err != nil {
+		if !errors.Is(err, errJobStopped) {
+			s.fail(jobID, workerName, err, true)
 		}
-		writer.Flush()
-		file.Close()
+		return
+	}
 
-		tx, err := s.db.Begin(ctx)
-		if err == nil {
-			for key, settlement := range settlementsMap {
-				parts := strings.Split(key, "|")
-				merchantID := parts[0]
-				date := parts[1]
-				runID := uuid.New().String()
-				_ = s.settlementRepo.UpsertJob(ctx, tx, runID, merchantID, date, settlement.GrossAmount, settlement.FeeAmount, settlement.NetAmount, settlement.TxnCount)
+	select {
+	case <-leaseLost:
+		fmt.Println("[Worker] Job lease lost before saving results")
+		return
+	default:
+	}
+
+	if job.ParentJobID != nil {
+		if err := s.completePartition(ctx, workerName, job, checkpoint.Processed); err != nil {
+			if errors.Is(err, errJobNotOwned) {
+				fmt.Printf("[Worker] Job %s stopped: %v\n", jobID, err)
+				return
 			}
-			tx.Commit(ctx)
+			s.fail(jobID, workerName, fmt.Errorf("failed to complete partition: %w", err), !errors.Is(err, ErrLaterSettlementsExist))
+			return
 		}
+		fmt.Printf("[Worker-%d] Partition %s of job %s DONE\n", workerID, jobID, *job.ParentJobID)
+		return
+	}
+
+	settlements, err := s.applyFeePlans(ctx, job, checkpoint.Settlements)
+	if err != nil {
+		s.fail(jobID, workerName, fmt.Errorf("failed to apply fee plans: %w", err), true)
+		return
+	}
 
-		s.jobRepo.MarkDone(ctx, nil, jobID, path)
-		fmt.Printf("[Worker-%d] Job %s DONE, CSV path: %s\n", workerID, jobID, path)
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.fail(jobID, workerName, fmt.Errorf("failed to save settlements: %w", err), true)
+		return
+	}
+	defer tx.Rollback(ctx)
 
-		s.mu.Lock()
-		delete(s.cancelSignals, jobID)
-		s.mu.Unlock()
+	// The job completes in the transaction that saves its settlements, so
+	// they are never saved without the job being DONE. Its row stays locked
+	// until then, so the lease cannot be reaped and handed to another worker
+	// while the report is written; a worker that lost it already stops here.
+	current, err := s.jobRepo.GetForUpdate(ctx, tx, jobID)
+	if err != nil {
+		s.fail(jobID, workerName, fmt.Errorf("failed to lock job: %w", err), true)
+		return
+	}
+	if current.Status != "RUNNING" || current.WorkerID == nil || *current.WorkerID != workerName {
+		fmt.Printf("[Worker] Job %s stopped: %v\n", jobID, errJobNotOwned)
+		return
+	}
+
+	if err := s.saveSettlements(ctx, tx, job, settlements); err != nil {
+		s.fail(jobID, workerName, fmt.Errorf("failed to save settlements: %w", err), !errors.Is(err, ErrLaterSettlementsExist))
+		return
+	}
+	key, manifest, err := s.writeSettlementReport(ctx, job, settlements)
+	if err != nil {
+		s.fail(jobID, workerName, err, true)
+		return
+	}
+	owned, err := s.jobRepo.MarkDone(ctx, tx, jobID, workerName, key, manifest)
+	if err != nil {
+		s.fail(jobID, workerName, fmt.Errorf("failed to complete job: %w", err), true)
+		return
 	}
+	if !owned {
+		fmt.Printf("[Worker] Job %s stopped: %v\n", jobID, errJobNotOwned)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		s.fail(jobID, workerName, fmt.Errorf("failed to save settlements: %w", err), true)
+		return
+	}
+
+	fmt.Printf("[Worker-%d] Job %s DONE, report: %s\n", workerID, jobID, key)
+
+	url := downloadURL(jobID)
+	s.publish(models.JobEvent{JobID: jobID, Type: "done", Status: "DONE", Processed: checkpoint.Processed, Total: total, Progress: 100, DownloadURL: &url})
 }
 
-func (s *JobService) CreateJob(ctx context.Context, req dto.CreateSettlementJobRequest) (*dto.CreateSettlementJobResponse, error) {
-	if req.From == "" || req.To == "" {
-		return nil, fmt.Errorf("invalid job request: From and To must be set")
+// shouldPartition reports whether job is a settlement whose range is long
+// enough to be split into partitions. Jobs that already started as a whole
+// keep running as a whole so their checkpoint stays valid.
+func (s *JobService) shouldPartition(job *models.Job) bool {
+	if s.partitionDays <= 0 || job.JobType != "SETTLEMENT" || job.ParentJobID != nil {
+		return false
+	}
+	if job.Checkpoint != nil && job.Checkpoint.CursorPaidAt != "" {
+		return false
 	}
 
-	total, err := s.transactionRepo.CountByDateRange(ctx, req.From, req.To)
+	from, err := time.Parse("2006-01-02", job.From)
 	if err != nil {
-		fmt.Printf("failed to count total transactions: %v\n", err)
-		total = 0
+		return false
+	}
+	to, err := time.Parse("2006-01-02", job.To)
+	if err != nil {
+		return false
 	}
 
-	job := &models.Job{
-		ID:        uuid.New().String(),
-		JobID:     uuid.New().String(),
-		Status:    "QUEUED",
-		Processed: 0,
-		Total:     total,
-		Progress:  0,
-		From:      req.From,
-		To:        req.To,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	return int(to.Sub(from).Hours()/24)+1 > s.partitionDays
+}
+
+// partition splits job into partitions of partitionDays days that any worker,
+// on any instance, can claim. The parent stays RUNNING without a lease until
+// its last partition merges the results. When the parent was resumed, its
+// existing partitions are re-queued instead of being created again.
+func (s *JobService) partition(ctx context.Context, workerID int, workerName string, job *models.Job) {
+	jobID := job.JobID
+
+	partitions, err := s.jobRepo.ListPartitions(ctx, nil, jobID)
+	if err != nil {
+		s.fail(jobID, workerName, fmt.Errorf("failed to list partitions: %w", err), true)
+		return
+	}
+
+	if len(partitions) == 0 {
+		if err := s.createPartitions(ctx, job); err != nil {
+			s.fail(jobID, workerName, fmt.Errorf("failed to create partitions: %w", err), true)
+			return
+		}
+		fmt.Printf("[Worker-%d] Job %s split into partitions of %d days\n", workerID, jobID, s.partitionDays)
+	} else {
+		resumed, err := s.jobRepo.ResumePartitions(ctx, jobID)
+		if err != nil {
+			s.fail(jobID, workerName, fmt.Errorf("failed to resume partitions: %w", err), true)
+			return
+		}
+		fmt.Printf("[Worker-%d] Job %s resumed %d of %d partitions\n", workerID, jobID, resumed, len(partitions))
+	}
+
+	if _, err := s.jobRepo.Detach(ctx, jobID, workerName); err != nil {
+		fmt.Printf("[Worker-%d] failed to detach job %s: %v\n", workerID, jobID, err)
 	}
+	s.publish(models.JobEvent{JobID: jobID, Type: "status", Status: "RUNNING", Processed: job.Processed, Total: job.Total, Progress: job.Progress})
 
+	for i := 0; i < s.workers; i++ {
+		s.notifyWorkers()
+	}
+
+	// Every partition may already be DONE when a parent is resumed after a
+	// failed merge.
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return nil, err
+		fmt.Printf("[Worker-%d] failed to merge job %s: %v\n", workerID, jobID, err)
+		return
 	}
 	defer tx.Rollback(ctx)
 
-	if err := s.jobRepo.Create(ctx, tx, job); err != nil {
-		return nil, err
+	merged, err := s.mergePartitions(ctx, tx, jobID)
+	if err != nil {
+		fmt.Printf("[Worker-%d] failed to merge job %s: %v\n", workerID, jobID, err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		fmt.Printf("[Worker-%d] failed to merge job %s: %v\n", workerID, jobID, err)
+		return
+	}
+	if merged != nil {
+		s.publishMerged(merged)
+	}
+}
+
+func (s *JobService) createPartitions(ctx context.Context, job *models.Job) error {
+	from, err := time.Parse("2006-01-02", job.From)
+	if err != nil {
+		return err
+	}
+	to, err := time.Parse("2006-01-02", job.To)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for start := from; !start.After(to); start = start.AddDate(0, 0, s.partitionDays) {
+		end := start.AddDate(0, 0, s.partitionDays-1)
+		if end.After(to) {
+			end = to
+		}
+
+		total, err := s.transactionRepo.CountByDateRange(ctx, start.Format("2006-01-02"), end.Format("2006-01-02"), transactionFilter(job))
+		if err != nil {
+			return err
+		}
+
+		parentJobID := job.JobID
+		partition := &models.Job{
+			JobID:        uuid.New().String(),
+			JobType:      "SETTLEMENT_PARTITION",
+			Aggregation:  job.Aggregation,
+			Status:       "QUEUED",
+			Total:        total,
+			From:         start.Format("2006-01-02"),
+			To:           end.Format("2006-01-02"),
+			ParentJobID:  &parentJobID,
+			Filter:       job.Filter,
+			ReportFormat: job.ReportFormat,
+		}
+		if err := s.jobRepo.Create(ctx, tx, partition); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// completePartition marks a finished partition DONE. The partition that
+// finishes last merges every partition into the parent; the parent row lock
+// makes sure exactly one of them does.
+func (s *JobService) completePartition(ctx context.Context, workerName string, job *models.Job, processed int) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := s.jobRepo.GetForUpdate(ctx, tx, *job.ParentJobID); err != nil {
+		return err
+	}
+	owned, err := s.jobRepo.MarkDone(ctx, tx, job.JobID, workerName, "", nil)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return errJobNotOwned
+	}
+
+	merged, err := s.mergePartitions(ctx, tx, *job.ParentJobID)
+	if err != nil {
+		return err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	s.publish(models.JobEvent{JobID: job.JobID, Type: "status", Status: "DONE", Processed: processed, Total: job.Total, Progress: 100})
+	if merged != nil {
+		s.publishMerged(merged)
+	}
+
+	return nil
+}
+
+// mergePartitions combines the checkpoints of parentJobID's partitions, writes
+// the CSV and settlements, and completes the parent. It returns nil when the
+// parent is not RUNNING or some partition is not DONE yet.
+func (s *JobService) mergePartitions(ctx context.Context, tx pgx.Tx, parentJobID string) (*models.Job, error) {
+	parent, err := s.jobRepo.GetForUpdate(ctx, tx, parentJobID)
+	if err != nil {
 		return nil, err
 	}
+	if parent.Status != "RUNNING" {
+		return nil, nil
+	}
 
-	select {
-	case s.jobQueue <- job.JobID:
-		fmt.Println("[JobService] Job queued:", job.JobID)
-	default:
-		fmt.Println("[JobService] Job queue is full, retry later")
+	partitions, err := s.jobRepo.ListPartitions(ctx, tx, parentJobID)
+	if err != nil {
+		return nil, err
+	}
+	if len(partitions) == 0 {
+		return nil, nil
 	}
 
-	res := &dto.CreateSettlementJobResponse{
-		JobID:   job.JobID,
-		Status:  "QUEUED",
-		Message: "Job created and queued successfully",
+	merged := &models.JobCheckpoint{Settlements: make(map[string]*models.SettlementAggregate)}
+	for _, partition := range partitions {
+		if partition.Status != "DONE" {
+			return nil, nil
+		}
+		if partition.Checkpoint == nil {
+			continue
+		}
+		mergeSettlements(merged.Settlements, partition.Checkpoint.Settlements)
+		merged.Processed += partition.Checkpoint.Processed
 	}
 
-	return res, nil
+	settlements, err := s.applyFeePlans(ctx, parent, merged.Settlements)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply fee plans: %w", err)
+	}
+
+	if err := s.saveSettlements(ctx, tx, parent, settlements); err != nil {
+		return nil, fmt.Errorf("failed to save settlements: %w", err)
+	}
+	key, manifest, err := s.writeSettlementReport(ctx, parent, settlements)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.jobRepo.MarkMerged(ctx, tx, parentJobID, key, manifest, merged); err != nil {
+		return nil, err
+	}
+
+	parent.Processed = merged.Processed
+	parent.Checkpoint = merged
+	fmt.Printf("[JobService] Job %s DONE, merged %d partitions, report: %s\n", parentJobID, len(partitions), key)
+
+	return parent, nil
 }
 
-func (s *JobService) CancelJob(jobID string) (*dto.CancelJobResponse, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *JobService) publishMerged(parent *models.Job) {
+	url := downloadURL(parent.JobID)
+	s.publish(models.JobEvent{JobID: parent.JobID, Type: "done", Status: "DONE", Processed: parent.Processed, Total: parent.Total, Progress: 100, DownloadURL: &url})
+}
 
-	if cancel, ok := s.cancelSignals[jobID]; ok {
-		close(cancel)
-		delete(s.cancelSignals, jobID)
-		s.jobRepo.MarkCancelled(context.Background(), nil, jobID)
-		fmt.Println("[JobService] Job cancelled:", jobID)
-		return &dto.CancelJobResponse{
-			JobID:   jobID,
-			Status:  "CANCELLED",
-			Message: "Job cancelled successfully",
-		}, nil
+// rollupProgress refreshes the progress of a partitioned parent job from its
+// partitions.
+func (s *JobService) rollupProgress(ctx context.Context, parentJobID string) {
+	parent, err := s.jobRepo.RollupProgress(ctx, parentJobID)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrNotFound) {
+			fmt.Printf("failed to update progress for job %s: %v\n", parentJobID, err)
+		}
+		return
 	}
 
-	return &dto.CancelJobResponse{
-		JobID:   jobID,
-		Status:  "NOT_FOUND",
-		Message: "Job not found or already finished",
-	}, nil
+	s.publish(models.JobEvent{JobID: parentJobID, Type: "progress", Status: "RUNNING", Processed: parent.Processed, Total: parent.Total, Progress: parent.Progress})
 }
 
-func (s *JobService) GetJobStatus(ctx context.Context, jobID string) (*dto.JobStatusResponse, error) {
-	job, err := s.jobRepo.GetByID(ctx, jobID)
+// failParent moves the parent of a dead partition to DEAD, so the whole job
+// shows up in the dead-letter list and can be resumed as one.
+func (s *JobService) failParent(partitionJobID, reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	parentJobID, err := s.jobRepo.MarkParentDead(ctx, partitionJobID, "partition "+partitionJobID+": "+reason)
 	if err != nil {
-		return nil, err
+		if !errors.Is(err, repositories.ErrNotFound) {
+			fmt.Printf("failed to mark parent of partition %s as dead: %v\n", partitionJobID, err)
+		}
+		return
 	}
 
-	res := &dto.JobStatusResponse{
-		JobID:      job.JobID,
-		Status:     job.Status,
-		Processed:  job.Processed,
-		Total:      job.Total,
-		Progress:   job.Progress,
-		ResultPath: job.ResultPath,
+	reason = "partition " + partitionJobID + ": " + reason
+	s.publishStatus(parentJobID, "DEAD", &reason)
+}
+
+// jobRun is the state of one claimed job while its transactions are being
+// aggregated.
+type jobRun struct {
+	job        *models.Job
+	workerName string
+	checkpoint *models.JobCheckpoint
+	reporter   *ProgressReporter
+	total      int
+	cancel     <-chan struct{}
+	leaseLost  <-chan struct{}
+}
+
+// stopped reports whether the run must stop before its next chunk of work,
+// and settles the job state for the reason it stops.
+func (s *JobService) stopped(ctx context.Context, run *jobRun) bool {
+	jobID := run.job.JobID
+
+	select {
+	case <-run.cancel:
+		fmt.Println("[Worker] Job cancelled")
+		s.jobRepo.MarkCancelled(ctx, nil, jobID, run.workerName)
+		s.publishStatus(jobID, "CANCELLED", nil)
+		return true
+	case <-run.leaseLost:
+		fmt.Println("[Worker] Job lease lost")
+		return true
+	case <-ctx.Done():
+		s.release(jobID, run.workerName)
+		return true
+	default:
+		return false
 	}
+}
 
-	if job.Status == "DONE" && job.ResultPath != nil && *job.ResultPath != "" {
-		url := fmt.Sprintf("/tmp/settlements/%s.csv", job.JobID)
-		res.DownloadURL = &url
+// interrupted turns err into errJobStopped when it was caused by shutdown,
+// releasing the job so it resumes elsewhere.
+func (s *JobService) interrupted(ctx context.Context, run *jobRun, err error) error {
+	if ctx.Err() != nil {
+		s.release(run.job.JobID, run.workerName)
+		return errJobStopped
+	}
+	return err
+}
+
+// saveCheckpoint flushes progress and persists the run's checkpoint. It returns
+// errJobStopped when the worker no longer owns the job.
+func (s *JobService) saveCheckpoint(ctx context.Context, run *jobRun, processed int) error {
+	jobID := run.job.JobID
+
+	if err := run.reporter.Flush(); err != nil && !errors.Is(err, errJobNotOwned) {
+		fmt.Printf("failed to update progress for job %s: %v\n", jobID, err)
 	}
 
-	return res, nil
+	run.checkpoint.Processed = processed
+	owned, err := s.jobRepo.SaveCheckpoint(ctx, jobID, run.workerName, run.checkpoint)
+	if err != nil {
+		fmt.Printf("failed to save checkpoint for job %s: %v\n", jobID, err)
+		return nil
+	}
+	if !owned {
+		fmt.Printf("[Worker] Job %s stopped: %v\n", jobID, errJobNotOwned)
+		return errJobStopped
+	}
+
+	return nil
+}
+
+// aggregateStream reads the settlement lines of every transaction of the job
+// matching its filter into Go in keyset batches and sums them per merchant,
+// date and currency.
+func (s *JobService) aggregateStream(ctx context.Context, run *jobRun) error {
+	job := run.job
+	checkpoint := run.checkpoint
+	settlementsMap := checkpoint.Settlements
+	processed := checkpoint.Processed
+	filter := transactionFilter(job)
+	limit := 5000
+
+	for {
+		if s.stopped(ctx, run) {
+			return errJobStopped
+		}
+
+		after := models.SettlementLineCursor{OccurredAt: checkpoint.CursorPaidAt, TransactionID: checkpoint.CursorID, Kind: checkpoint.CursorKind}
+		lines, err := s.transactionRepo.FetchBatch(ctx, job.From, job.To, filter, after, limit)
+		if err != nil {
+			return s.interrupted(ctx, run, fmt.Errorf("failed to fetch batch: %w", err))
+		}
+
+		count := 0
+		for _, line := range lines {
+			count++
+			checkpoint.CursorPaidAt = line.OccurredAt.Format(time.RFC3339Nano)
+			checkpoint.CursorID = line.TransactionID
+			checkpoint.CursorKind = line.Kind
+
+			key := settlementKey(line.MerchantID, line.SettlementDate.Format("2006-01-02"), line.Currency)
+			addAggregate(settlementsMap, key, lineAggregate(line))
+
+			processed++
+
+			if err := run.reporter.Report(processed); err != nil {
+				if errors.Is(err, errJobNotOwned) {
+					fmt.Printf("[Worker] Job %s stopped: %v\n", job.JobID, err)
+					return errJobStopped
+				}
+				fmt.Printf("failed to update progress for job %s: %v\n", job.JobID, err)
+			}
+		}
+
+		// Only rows up to the cursor are folded into the saved aggregates, so a
+		// resumed run continues exactly after the last checkpointed row.
+		if err := s.saveCheckpoint(ctx, run, processed); err != nil {
+			return err
+		}
+
+		if count < limit {
+			return nil
+		}
+	}
+}
+
+// aggregateInDatabase lets Postgres sum settlement lines per merchant and
+// currency, one settlement day at a time, so only the aggregates travel to Go. The cursor
+// is the last completed day.
+func (s *JobService) aggregateInDatabase(ctx context.Context, run *jobRun) error {
+	job := run.job
+	checkpoint := run.checkpoint
+	processed := checkpoint.Processed
+
+	day, err := time.Parse("2006-01-02", job.From)
+	if err != nil {
+		return fmt.Errorf("invalid from date: %w", err)
+	}
+	end, err := time.Parse("2006-01-02", job.To)
+	if err != nil {
+		return fmt.Errorf("invalid to date: %w", err)
+	}
+	if checkpoint.CursorPaidAt != "" {
+		last, err := time.Parse("2006-01-02", checkpoint.CursorPaidAt)
+		if err != nil {
+			return fmt.Errorf("invalid checkpoint cursor: %w", err)
+		}
+		day = last.AddDate(0, 0, 1)
+	}
+
+	for ; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if s.stopped(ctx, run) {
+			return errJobStopped
+		}
+
+		date := day.Format("2006-01-02")
+		aggregates, err := s.transactionRepo.AggregateByDay(ctx, date, transactionFilter(job))
+		if err != nil {
+			return s.interrupted(ctx, run, fmt.Errorf("failed to aggregate %s: %w", date, err))
+		}
+
+		for _, aggregate := range aggregates {
+			addAggregate(checkpoint.Settlements, settlementKey(aggregate.MerchantID, date, aggregate.Currency), models.SettlementAggregate{
+				GrossAmount:      aggregate.GrossAmount,
+				FeeAmount:        aggregate.FeeAmount,
+				NetAmount:        aggregate.NetAmount,
+				TxnCount:         aggregate.TxnCount,
+				RefundAmount:     aggregate.RefundAmount,
+				ChargebackAmount: aggregate.ChargebackAmount,
+				ReversalCount:    aggregate.ReversalCount,
+			})
+			processed += aggregate.TxnCount + aggregate.ReversalCount
+		}
+
+		checkpoint.CursorPaidAt = date
+		if err := run.reporter.Report(processed); err != nil {
+			if errors.Is(err, errJobNotOwned) {
+				fmt.Printf("[Worker] Job %s stopped: %v\n", job.JobID, err)
+				return errJobStopped
+			}
+			fmt.Printf("failed to update progress for job %s: %v\n", job.JobID, err)
+		}
+		if err := s.saveCheckpoint(ctx, run, processed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addAggregate(settlementsMap map[string]*models.SettlementAggregate, key string, delta models.SettlementAggregate) {
+	settlement, ok := settlementsMap[key]
+	if !ok {
+		settlementsMap[key] = &delta
+		return
+	}
+
+	settlement.GrossAmount += delta.GrossAmount
+	settlement.FeeAmount += delta.FeeAmount
+	settlement.NetAmount += delta.NetAmount
+	settlement.TxnCount += delta.TxnCount
+	settlement.RefundAmount += delta.RefundAmount
+	settlement.ChargebackAmount += delta.ChargebackAmount
+	settlement.ReversalCount += delta.ReversalCount
+}
+
+// lineAggregate is what a single settlement line adds to its settlement. A
+// refund or chargeback lowers gross and net by its amount.
+func lineAggregate(line models.SettlementLine) models.SettlementAggregate {
+	aggregate := models.SettlementAggregate{
+		GrossAmount: line.Amount,
+		FeeAmount:   line.Fee,
+		NetAmount:   line.Amount - line.Fee,
+	}
+
+	switch line.Kind {
+	case models.SettlementLineRefund:
+		aggregate.RefundAmount = -line.Amount
+		aggregate.ReversalCount = 1
+	case models.SettlementLineChargeback:
+		aggregate.ChargebackAmount = -line.Amount
+		aggregate.ReversalCount = 1
+	default:
+		aggregate.TxnCount = 1
+	}
+
+	return aggregate
+}
+
+// settlementKey is the checkpoint key of a merchant's settlement on date in
+// currency.
+func settlementKey(merchantID, date, currency string) string {
+	return merchantID + "|" + date + "|" + currency
+}
+
+// parseSettlementKey splits a settlementKey. Keys of checkpoints saved before
+// settlements had a currency are in models.DefaultCurrency.
+func parseSettlementKey(key string) (merchantID, date, currency string) {
+	parts := strings.SplitN(key, "|", 3)
+	if len(parts) < 3 {
+		return parts[0], parts[1], models.DefaultCurrency
+	}
+
+	return parts[0], parts[1], parts[2]
+}
+
+// mergeSettlements adds every aggregate of src to dst, rekeying keys saved
+// without a currency.
+func mergeSettlements(dst, src map[string]*models.SettlementAggregate) {
+	for key, aggregate := range src {
+		merchantID, date, currency := parseSettlementKey(key)
+		addAggregate(dst, settlementKey(merchantID, date, currency), *aggregate)
+	}
+}
+
+// applyFeePlans returns a copy of settlementsMap with the fee of the plan
+// each merchant had on each day, charged on the day's payments before
+// refunds and chargebacks. The recorded fee and net are kept as they are; a
+// day whose plan fee differs from the recorded fee is flagged as a mismatch.
+// Days without a plan are left without a plan fee.
+func (s *JobService) applyFeePlans(ctx context.Context, job *models.Job, settlementsMap map[string]*models.SettlementAggregate) (map[string]*models.SettlementAggregate, error) {
+	var merchantIDs []string
+	if job.Filter != nil {
+		merchantIDs = job.Filter.MerchantIDs
+	}
+
+	assignments, err := s.feePlanRepo.ListEffective(ctx, merchantIDs, job.From, job.To)
+	if err != nil {
+		return nil, err
+	}
+
+	settlements := make(map[string]*models.SettlementAggregate, len(settlementsMap))
+	for key, aggregate := range settlementsMap {
+		settlement := *aggregate
+		settlement.PlanFeeAmount = nil
+		settlement.FeeMismatch = false
+
+		merchantID, date, _ := parseSettlementKey(key)
+		if plan := effectivePlan(assignments, merchantID, date); plan != nil {
+			payments := settlement.GrossAmount + settlement.RefundAmount + settlement.ChargebackAmount
+			fee := planFee(plan, payments, settlement.TxnCount)
+			settlement.PlanFeeAmount = &fee
+			settlement.FeeMismatch = fee != settlement.FeeAmount
+		}
+
+		settlements[key] = &settlement
+	}
+
+	return settlements, nil
+}
+
+// writeSettlementReport stores the settlements of job as its report in the
+// job's report format and returns the storage key and manifest of the report.
+func (s *JobService) writeSettlementReport(ctx context.Context, job *models.Job, settlementsMap map[string]*models.SettlementAggregate) (string, *models.ReportManifest, error) {
+	writer, err := NewSettlementReportWriter(job.ReportFormat)
+	if err != nil {
+		return "", nil, err
+	}
+
+	rows := make([]SettlementReportRow, 0, len(settlementsMap))
+	for key, settlement := range settlementsMap {
+		merchantID, date, currency := parseSettlementKey(key)
+		rows = append(rows, settlementReportRow(merchantID, date, currency, *settlement))
+	}
+	report := newSettlementReport(job, rows, time.Now())
+
+	var buf bytes.Buffer
+	manifest, err := renderSettlementReport(&buf, writer, report)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to write report: %w", err)
+	}
+
+	key := manifest.FileName
+	if err := s.artifacts.Put(ctx, key, &buf, manifest.Size, manifest.ContentType); err != nil {
+		return "", nil, fmt.Errorf("failed to store report: %w", err)
+	}
+
+	return key, manifest, nil
+}
+
+// saveSettlements carries deficits forward through settlementsMap, records
+// it as a new settlement run of job, posts the transactions it settles that
+// are not in the ledger yet and then the run itself to the ledger, and
+// replaces the settlements dated in the job's range with it inside tx.
+// Merchants and dates without settlement lines in the new run disappear, so
+// rerunning a range any number of times always leaves exactly the result of
+// the last run, while earlier runs stay available for rollback. Like a
+// rollback, it refuses with ErrLaterSettlementsExist when a merchant of the
+// run already has settlements after the range, since those carried in what
+// the range carried forward before. A report-only job saves nothing.
+func (s *JobService) saveSettlements(ctx context.Context, tx pgx.Tx, job *models.Job, settlementsMap map[string]*models.SettlementAggregate) error {
+	if reportOnly(job) {
+		return nil
+	}
+	if err := s.settlementRepo.Lock(ctx, tx); err != nil {
+		return err
+	}
+	if err := s.carryForward(ctx, tx, job, settlementsMap); err != nil {
+		return err
+	}
+
+	jobID := job.JobID
+	run := &models.SettlementRun{
+		ID:     uuid.New().String(),
+		JobID:  &jobID,
+		Kind:   "JOB",
+		Filter: job.Filter,
+		From:   job.From,
+		To:     job.To,
+	}
+	if err := s.settlementRepo.CreateRun(ctx, tx, run); err != nil {
+		return err
+	}
+
+	for key, settlement := range settlementsMap {
+		merchantID, date, currency := parseSettlementKey(key)
+		if err := s.settlementRepo.AddRunRow(ctx, tx, run.ID, merchantID, date, currency, *settlement); err != nil {
+			return err
+		}
+	}
+
+	later, err := s.settlementRepo.HasLaterSettlements(ctx, tx, run.ID, job.From, job.To, job.Filter)
+	if err != nil {
+		return err
+	}
+	if later {
+		return fmt.Errorf("%w: settlements after %s depend on %s..%s", ErrLaterSettlementsExist, job.To, job.From, job.To)
+	}
+
+	if err := s.ledger.PostTransactionsInRange(ctx, tx, job.From, job.To, transactionFilter(job)); err != nil {
+		return err
+	}
+	if err := s.ledger.PostSettlementRun(ctx, tx, run); err != nil {
+		return err
+	}
+
+	return s.settlementRepo.ReplaceFromRun(ctx, tx, run.ID, job.From, job.To, job.Filter)
+}
+
+// carryForward sets the payable amount of every settlement in settlementsMap.
+// A merchant's deficit in a currency is carried into its next settlement in
+// that currency, starting from what its latest settlement before the job's
+// range carried forward. Payable never goes below zero; what is left of a
+// negative balance becomes the carry forward.
+func (s *JobService) carryForward(ctx context.Context, tx pgx.Tx, job *models.Job, settlementsMap map[string]*models.SettlementAggregate) error {
+	previous, err := s.settlementRepo.ListCarryForward(ctx, tx, job.From)
+	if err != nil {
+		return err
+	}
+
+	carries := make(map[string]int, len(previous))
+	for _, settlement := range previous {
+		carries[settlement.MerchantID+"|"+settlement.Currency] = settlement.CarryForward
+	}
+
+	keys := make([]string, 0, len(settlementsMap))
+	for key := range settlementsMap {
+		keys = append(keys, key)
+	}
+	// Dates are formatted 2006-01-02, so they sort in calendar order.
+	sort.Slice(keys, func(i, j int) bool {
+		merchantI, dateI, currencyI := parseSettlementKey(keys[i])
+		merchantJ, dateJ, currencyJ := parseSettlementKey(keys[j])
+		if merchantI != merchantJ {
+			return merchantI < merchantJ
+		}
+		if currencyI != currencyJ {
+			return currencyI < currencyJ
+		}
+		return dateI < dateJ
+	})
+
+	for _, key := range keys {
+		merchantID, _, currency := parseSettlementKey(key)
+		settlement := settlementsMap[key]
+
+		settlement.CarriedIn = carries[merchantID+"|"+currency]
+		balance := settlement.NetAmount + settlement.CarriedIn
+		settlement.PayableAmount = max(balance, 0)
+		settlement.CarryForward = min(balance, 0)
+		carries[merchantID+"|"+currency] = settlement.CarryForward
+	}
+
+	return nil
+}
+
+// fail records err on the job and lets the retry policy decide whether it is
+// retried later or moved to the dead-letter state. Non-retryable errors go to
+// DEAD immediately.
+func (s *JobService) fail(jobID, workerName string, err error, retryable bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	status, markErr := s.jobRepo.MarkFailed(ctx, jobID, workerName, err.Error(), retryable, s.retryPolicy)
+	if markErr != nil {
+		fmt.Printf("[%s] failed to mark job %s as failed (%v): %v\n", workerName, jobID, err, markErr)
+		return
+	}
+	fmt.Printf("[%s] Job %s %s: %v\n", workerName, jobID, status, err)
+
+	reason := err.Error()
+	s.publishStatus(jobID, status, &reason)
+	if status == "DEAD" {
+		s.failParent(jobID, reason)
+	}
+}
+
+// release hands a job that was interrupted by shutdown back to the queue so
+// another worker resumes it from its last checkpoint.
+func (s *JobService) release(jobID, workerName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.jobRepo.Release(ctx, jobID, workerName); err != nil {
+		fmt.Printf("[%s] failed to release job %s: %v\n", workerName, jobID, err)
+		return
+	}
+	fmt.Printf("[%s] Job %s released for resume\n", workerName, jobID)
+	s.publishStatus(jobID, "QUEUED", nil)
+}
+
+// publish sends event with its own timeout, so events still go out while the
+// worker context is being cancelled.
+func (s *JobService) publish(event models.JobEvent) {
+	if s.events == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.events.Publish(ctx, event)
+}
+
+func (s *JobService) publishStatus(jobID, status string, reason *string) {
+	s.publish(models.JobEvent{JobID: jobID, Type: "status", Status: status, Error: reason})
+}
+
+// transactionFilter returns the transactions job settles. Jobs created
+// before filters existed settle every PAID transaction.
+func transactionFilter(job *models.Job) models.TransactionFilter {
+	if job.Filter == nil {
+		return models.TransactionFilter{Statuses: []string{"PAID"}}
+	}
+	return *job.Filter
+}
+
+func percent(processed, total int) int {
+	if total <= 0 {
+		return 0
+	}
+	return (processed * 100) / total
+}
+
+func downloadURL(jobID string) string {
+	return fmt.Sprintf("/downloads/%s", jobID)
+}
+
+func (s *JobService) CreateJob(ctx context.Context, req dto.CreateSettlementJobRequest) (*dto.CreateSettlementJobResponse, error) {
+	if req.From == "" || req.To == "" {
+		return nil, fmt.Errorf("%w: from and to must be set", ErrInvalidJobRequest)
+	}
+	for _, status := range req.Statuses {
+		if !slices.Contains(models.SettlementStatuses, status) {
+			return nil, fmt.Errorf("%w: unknown status %q, must be one of %v", ErrInvalidJobRequest, status, models.SettlementStatuses)
+		}
+	}
+
+	aggregation := req.Aggregation
+	if aggregation == "" {
+		aggregation = "STREAM"
+	}
+
+	reportFormat := req.ReportFormat
+	if reportFormat == "" {
+		reportFormat = models.ReportFormatCSV
+	}
+	if _, err := NewSettlementReportWriter(reportFormat); err != nil {
+		return nil, err
+	}
+
+	filter := &models.TransactionFilter{
+		MerchantIDs:        req.MerchantIDs,
+		ExcludeMerchantIDs: req.ExcludeMerchantIDs,
+		Statuses:           req.Statuses,
+	}
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = append([]string(nil), models.SettlementStatuses...)
+	}
+
+	total, err := s.transactionRepo.CountByDateRange(ctx, req.From, req.To, *filter)
+	if err != nil {
+		fmt.Printf("failed to count total transactions: %v\n", err)
+		total = 0
+	}
+
+	job := &models.Job{
+		ID:           uuid.New().String(),
+		JobID:        uuid.New().String(),
+		JobType:      "SETTLEMENT",
+		Aggregation:  aggregation,
+		Status:       "QUEUED",
+		Processed:    0,
+		Total:        total,
+		Progress:     0,
+		From:         req.From,
+		To:           req.To,
+		Filter:       filter,
+		ReportFormat: reportFormat,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Checked again when the job saves its settlements, for merchants that
+	// only get settlements in the range by this run.
+	if !reportOnly(job) {
+		later, err := s.settlementRepo.HasLaterSettlements(ctx, tx, "", req.From, req.To, filter)
+		if err != nil {
+			return nil, err
+		}
+		if later {
+			return nil, fmt.Errorf("%w: settlements after %s depend on %s..%s", ErrLaterSettlementsExist, req.To, req.From, req.To)
+		}
+	}
+
+	if err := s.jobRepo.Create(ctx, tx, job); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	s.notifyWorkers()
+	fmt.Println("[JobService] Job queued:", job.JobID)
+	s.publish(models.JobEvent{JobID: job.JobID, Type: "status", Status: "QUEUED", Total: total})
+
+	res := &dto.CreateSettlementJobResponse{
+		JobID:   job.JobID,
+		Status:  "QUEUED",
+		Message: "Job created and queued successfully",
+	}
+
+	return res, nil
+}
+
+// reportOnly reports whether job reads only some of the settlement
+// statuses. A settlement job replaces every settlement in its merchant scope,
+// so such a job only writes its report instead of overwriting the totals of
+// the statuses it did not read.
+func reportOnly(job *models.Job) bool {
+	if job.Filter == nil {
+		return false
+	}
+	for _, status := range models.SettlementStatuses {
+		if !slices.Contains(job.Filter.Statuses, status) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *JobService) CancelJob(jobID string) (*dto.CancelJobResponse, error) {
+	// The signal is taken out of the map under the lock, so only one caller
+	// closes it, and the lock is not held during the database round trip.
+	s.mu.Lock()
+	cancel, ok := s.cancelSignals[jobID]
+	delete(s.cancelSignals, jobID)
+	s.mu.Unlock()
+
+	if ok {
+		close(cancel)
+	}
+
+	// A job running here stops at its next chunk of work. A job still queued
+	// or running on another instance stops when its worker notices the status
+	// change on its next guarded write. Finished jobs keep their status.
+	cancelled, err := s.jobRepo.CancelActive(context.Background(), jobID)
+	if err != nil {
+		return nil, err
+	}
+	if cancelled {
+		fmt.Println("[JobService] Job cancelled:", jobID)
+		s.publishStatus(jobID, "CANCELLED", nil)
+		return &dto.CancelJobResponse{
+			JobID:   jobID,
+			Status:  "CANCELLED",
+			Message: "Job cancelled successfully",
+		}, nil
+	}
+
+	return &dto.CancelJobResponse{
+		JobID:   jobID,
+		Status:  "NOT_FOUND",
+		Message: "Job not found or already finished",
+	}, nil
+}
+
+// ResumeJob puts a CANCELLED or FAILED job back on the queue. The job keeps
+// its checkpoint, so processing continues where it stopped.
+func (s *JobService) ResumeJob(ctx context.Context, jobID string) (*dto.ResumeJobResponse, error) {
+	resumed, err := s.jobRepo.Resume(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if !resumed {
+		return &dto.ResumeJobResponse{
+			JobID:   jobID,
+			Status:  "NOT_FOUND",
+			Message: "Job not found or not resumable",
+		}, nil
+	}
+
+	s.notifyWorkers()
+	fmt.Println("[JobService] Job resumed:", jobID)
+	s.publishStatus(jobID, "QUEUED", nil)
+
+	return &dto.ResumeJobResponse{
+		JobID:   jobID,
+		Status:  "QUEUED",
+		Message: "Job queued to resume from its last checkpoint",
+	}, nil
+}
+
+// ListJobs searches jobs with the filters in req and returns one page plus
+// the cursor of the next page, if any.
+func (s *JobService) ListJobs(ctx context.Context, req dto.ListJobsRequest) (*dto.ListJobsResponse, error) {
+	filter := models.JobFilter{
+		Statuses: req.Status,
+		JobType:  req.JobType,
+		From:     req.From,
+		To:       req.To,
+		Limit:    req.Limit,
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 20
+	}
+
+	switch strings.ToLower(req.Sort) {
+	case "", "desc":
+		filter.Desc = true
+	case "asc":
+		filter.Desc = false
+	default:
+		return nil, fmt.Errorf("%w: sort must be asc or desc", ErrInvalidJobQuery)
+	}
+
+	for _, date := range []string{req.From, req.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, fmt.Errorf("%w: from/to must be YYYY-MM-DD", ErrInvalidJobQuery)
+		}
+	}
+
+	if req.CreatedFrom != "" {
+		t, err := parseTimeParam(req.CreatedFrom)
+		if err != nil {
+			return nil, fmt.Errorf("%w: created_from: %v", ErrInvalidJobQuery, err)
+		}
+		filter.CreatedFrom = &t
+	}
+	if req.CreatedTo != "" {
+		t, err := parseTimeParam(req.CreatedTo)
+		if err != nil {
+			return nil, fmt.Errorf("%w: created_to: %v", ErrInvalidJobQuery, err)
+		}
+		// The bound is exclusive, so a date-only value moves to the next day
+		// and includes the whole day it names.
+		if _, err := time.Parse("2006-01-02", req.CreatedTo); err == nil {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.CreatedTo = &t
+	}
+
+	if req.Cursor != "" {
+		cursor, err := decodeJobCursor(req.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidJobQuery)
+		}
+		filter.After = cursor
+	}
+
+	jobs, err := s.jobRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &dto.ListJobsResponse{Data: []dto.JobStatusResponse{}}
+	if len(jobs) > filter.Limit {
+		jobs = jobs[:filter.Limit]
+		last := jobs[len(jobs)-1]
+		next := encodeJobCursor(models.JobCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		res.NextCursor = &next
+	}
+
+	for i := range jobs {
+		res.Data = append(res.Data, *toJobStatusResponse(&jobs[i]))
+	}
+
+	return res, nil
+}
+
+// ListDeadLetter returns the jobs that exhausted their retries, most recent
+// first, so operators can inspect them and resume them with ResumeJob.
+func (s *JobService) ListDeadLetter(ctx context.Context, limit int) ([]dto.JobStatusResponse, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+
+	jobs, err := s.jobRepo.List(ctx, models.JobFilter{Statuses: []string{"DEAD"}, Desc: true, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+
+	res := make([]dto.JobStatusResponse, 0, len(jobs))
+	for i := range jobs {
+		res = append(res, *toJobStatusResponse(&jobs[i]))
+	}
+
+	return res, nil
+}
+
+// parseTimeParam accepts either an RFC 3339 timestamp or a plain date.
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func encodeJobCursor(cursor models.JobCursor) string {
+	raw := cursor.CreatedAt.Format(time.RFC3339Nano) + "|" + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeJobCursor(value string) (*models.JobCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JobCursor{CreatedAt: t, ID: id}, nil
+}
+
+func (s *JobService) GetJobStatus(ctx context.Context, jobID string) (*dto.JobStatusResponse, error) {
+	job, err := s.jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toJobStatusResponse(job), nil
+}
+
+// SettlementReportFile is a settlement report ready to be sent. Body must be
+// closed. Manifest is nil for reports written before manifests were kept.
+type SettlementReportFile struct {
+	Manifest    *models.ReportManifest
+	FileName    string
+	ContentType string
+	Body        io.ReadCloser
+}
+
+// OpenReport opens the settlement report of a DONE job in format, or in the
+// format the job wrote it in when format is empty. A report in another format
+// is rendered from the settlement run the job recorded, with the same header
+// as the report the job wrote.
+func (s *JobService) OpenReport(ctx context.Context, jobID, format string) (*SettlementReportFile, error) {
+	job, err := s.jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	if job.Status != "DONE" || job.ResultPath == nil || *job.ResultPath == "" {
+		return nil, ErrReportNotFound
+	}
+
+	if format == "" {
+		format = job.ReportFormat
+	}
+	writer, err := NewSettlementReportWriter(format)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == job.ReportFormat {
+		file, err := s.artifacts.Open(ctx, *job.ResultPath)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return nil, ErrReportNotFound
+			}
+			return nil, err
+		}
+		return &SettlementReportFile{
+			Manifest:    job.Manifest,
+			FileName:    job.JobID + writer.Extension(),
+			ContentType: writer.ContentType(),
+			Body:        file,
+		}, nil
+	}
+
+	settlements, err := s.settlementRepo.ListJobRunRows(ctx, job.JobID)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]SettlementReportRow, 0, len(settlements))
+	for _, settlement := range settlements {
+		rows = append(rows, settlementReportRow(settlement.MerchantID, settlement.Date.Format("2006-01-02"), settlement.Currency, models.SettlementAggregate{
+			GrossAmount:      settlement.GrossAmount,
+			FeeAmount:        settlement.FeeAmount,
+			NetAmount:        settlement.NetAmount,
+			TxnCount:         settlement.TxnCount,
+			RefundAmount:     settlement.RefundAmount,
+			ChargebackAmount: settlement.ChargebackAmount,
+			ReversalCount:    settlement.ReversalCount,
+			PlanFeeAmount:    settlement.PlanFeeAmount,
+			FeeMismatch:      settlement.FeeMismatch,
+			CarriedIn:        settlement.CarriedIn,
+			PayableAmount:    settlement.PayableAmount,
+			CarryForward:     settlement.CarryForward,
+		}))
+	}
+
+	generatedAt := job.UpdatedAt
+	if job.Manifest != nil {
+		generatedAt = job.Manifest.GeneratedAt
+	}
+
+	var buf bytes.Buffer
+	manifest, err := renderSettlementReport(&buf, writer, newSettlementReport(job, rows, generatedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	return &SettlementReportFile{
+		Manifest:    manifest,
+		FileName:    manifest.FileName,
+		ContentType: manifest.ContentType,
+		Body:        io.NopCloser(&buf),
+	}, nil
+}
+
+func toJobStatusResponse(job *models.Job) *dto.JobStatusResponse {
+	res := &dto.JobStatusResponse{
+		JobID:        job.JobID,
+		JobType:      job.JobType,
+		ParentJobID:  job.ParentJobID,
+		Aggregation:  job.Aggregation,
+		ReportFormat: job.ReportFormat,
+		Status:       job.Status,
+		From:         job.From,
+		To:           job.To,
+		Processed:    job.Processed,
+		Total:        job.Total,
+		Progress:     job.Progress,
+		ResultPath:   job.ResultPath,
+		Attempts:     job.Attempts,
+		LastError:    job.LastError,
+		CreatedAt:    job.CreatedAt,
+		UpdatedAt:    job.UpdatedAt,
+	}
+
+	if job.Filter != nil {
+		res.MerchantIDs = job.Filter.MerchantIDs
+		res.ExcludeMerchantIDs = job.Filter.ExcludeMerchantIDs
+		res.Statuses = job.Filter.Statuses
+	}
+	res.ReportOnly = reportOnly(job)
+
+	if job.Status == "FAILED" {
+		res.NextRetryAt = job.RunAfter
+	}
+
+	if job.Status == "DONE" && job.ResultPath != nil && *job.ResultPath != "" {
+		url := downloadURL(job.JobID)
+		res.DownloadURL = &url
+	}
+
+	if job.Manifest != nil {
+		res.Manifest = toReportManifestResponse(job.Manifest)
+	}
+
+	return res
+}
+
+func toReportManifestResponse(manifest *models.ReportManifest) *dto.ReportManifestResponse {
+	return &dto.ReportManifestResponse{
+		FileName:    manifest.FileName,
+		Format:      manifest.Format,
+		ContentType: manifest.ContentType,
+		Size:        manifest.Size,
+		SHA256:      manifest.SHA256,
+		Rows:        manifest.Rows,
+		GeneratedAt: manifest.GeneratedAt,
+	}
 }
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidLedgerRequest = errors.New("INVALID_LEDGER_REQUEST")
+	ErrUnbalancedJournal    = errors.New("UNBALANCED_JOURNAL")
+)
+
+type LedgerRepository interface {
+	PostJournal(ctx context.Context, tx pgx.Tx, journal *models.LedgerJournal) (bool, error)
+	PostTransactionJournals(ctx context.Context, tx pgx.Tx, transactionIDs []string) (int, error)
+	PostTransactionJournalsInRange(ctx context.Context, tx pgx.Tx, from, to string, scope models.TransactionFilter) (int, error)
+	SettlementRunDeltas(ctx context.Context, tx pgx.Tx, runID, from, to string, filter *models.TransactionFilter) ([]models.Settlement, error)
+	Balances(ctx context.Context, accountIDs []string, currency string) ([]models.LedgerBalance, error)
+	ListJournals(ctx context.Context, filter models.LedgerJournalFilter) ([]models.LedgerJournal, error)
+	ListImbalances(ctx context.Context) ([]models.LedgerImbalance, error)
+}
+
+// LedgerPoster posts the journals of payment, settlement and payout events
+// inside the transaction that records them, so the ledger never disagrees
+// with the tables it mirrors.
+type LedgerPoster interface {
+	PostTransactions(ctx context.Context, tx pgx.Tx, transactionIDs []string) error
+	PostTransactionsInRange(ctx context.Context, tx pgx.Tx, from, to string, scope models.TransactionFilter) error
+	PostSettlementRun(ctx context.Context, tx pgx.Tx, run *models.SettlementRun) error
+	PostPayout(ctx context.Context, tx pgx.Tx, payout *models.Payout) error
+}
+
+// LedgerService keeps a double-entry ledger of the money moving through the
+// platform. A paid transaction debits cash and credits the merchant's pending
+// account when it is recorded, its fee moves from pending to fee revenue, and
+// refunds and chargebacks take back from pending. Settlement jobs post the
+// transactions they settle that are not in the ledger yet, such as reversals
+// made after the transaction was recorded, and their runs move the settled
+// net amount from pending to payable. Sent payouts pay payable out of cash.
+type LedgerService struct {
+	ledgerRepo LedgerRepository
+}
+
+func NewLedgerService(ledgerRepo LedgerRepository) *LedgerService {
+	return &LedgerService{
+		ledgerRepo: ledgerRepo,
+	}
+}
+
+// PostTransactions posts the payment, fee and reversal journals of the
+// transactions with transactionIDs inside tx, or of every transaction when
+// transactionIDs is nil. Transactions posted before are skipped.
+func (s *LedgerService) PostTransactions(ctx context.Context, tx pgx.Tx, transactionIDs []string) error {
+	posted, err := s.ledgerRepo.PostTransactionJournals(ctx, tx, transactionIDs)
+	if err != nil {
+		return err
+	}
+	if posted > 0 {
+		fmt.Printf("[LedgerService] Posted %d transaction journals\n", posted)
+	}
+
+	return nil
+}
+
+// PostTransactionsInRange posts the payment, fee and reversal journals of
+// the transactions of the merchants in scope that settle in [from, to] inside
+// tx. It catches up on transactions and reversals recorded outside the
+// service before their settlement run is posted.
+func (s *LedgerService) PostTransactionsInRange(ctx context.Context, tx pgx.Tx, from, to string, scope models.TransactionFilter) error {
+	posted, err := s.ledgerRepo.PostTransactionJournalsInRange(ctx, tx, from, to, scope)
+	if err != nil {
+		return err
+	}
+	if posted > 0 {
+		fmt.Printf("[LedgerService] Posted %d transaction journals for %s..%s\n", posted, from, to)
+	}
+
+	return nil
+}
+
+// PostSettlementRun moves, per merchant and currency, the difference between
+// the net amount of run and the settlements it replaces from the merchant's
+// pending account to its payable account. It must run before the run's rows
+// replace the settlements, while holding the settlements lock.
+func (s *LedgerService) PostSettlementRun(ctx context.Context, tx pgx.Tx, run *models.SettlementRun) error {
+	deltas, err := s.ledgerRepo.SettlementRunDeltas(ctx, tx, run.ID, run.From, run.To, run.Filter)
+	if err != nil {
+		return err
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	journal := &models.LedgerJournal{
+		Kind:      "SETTLEMENT",
+		SourceID:  run.ID,
+		Reference: run.ID,
+		PostedAt:  time.Now().UTC(),
+	}
+	for _, delta := range deltas {
+		journal.Entries = append(journal.Entries,
+			models.LedgerEntry{AccountID: models.MerchantPendingAccount(delta.MerchantID), Currency: delta.Currency, Amount: delta.NetAmount},
+			models.LedgerEntry{AccountID: models.MerchantPayableAccount(delta.MerchantID), Currency: delta.Currency, Amount: -delta.NetAmount},
+		)
+	}
+
+	return s.post(ctx, tx, journal)
+}
+
+// PostPayout posts a SENT payout as paid from the merchant's payable account
+// out of cash, and a FAILED one that was sent as the reverse. Other statuses
+// move no money.
+func (s *LedgerService) PostPayout(ctx context.Context, tx pgx.Tx, payout *models.Payout) error {
+	var journal *models.LedgerJournal
+	switch {
+	case payout.Status == "SENT" && payout.SentAt != nil:
+		journal = &models.LedgerJournal{Kind: "PAYOUT", PostedAt: *payout.SentAt}
+		journal.Entries = []models.LedgerEntry{
+			{AccountID: models.MerchantPayableAccount(payout.MerchantID), Currency: payout.Currency, Amount: payout.Amount},
+			{AccountID: models.LedgerAccountCash, Currency: payout.Currency, Amount: -payout.Amount},
+		}
+	case payout.Status == "FAILED" && payout.SentAt != nil && payout.FailedAt != nil:
+		journal = &models.LedgerJournal{Kind: "PAYOUT_REVERSAL", PostedAt: *payout.FailedAt}
+		journal.Entries = []models.LedgerEntry{
+			{AccountID: models.LedgerAccountCash, Currency: payout.Currency, Amount: payout.Amount},
+			{AccountID: models.MerchantPayableAccount(payout.MerchantID), Currency: payout.Currency, Amount: -payout.Amount},
+		}
+	default:
+		return nil
+	}
+	if payout.Amount == 0 {
+		return nil
+	}
+
+	journal.SourceID = payout.ID
+	journal.Reference = payout.ID
+
+	return s.post(ctx, tx, journal)
+}
+
+// post checks that journal balances in every currency and records it. A
+// journal already posted under the same kind and reference is left as is.
+func (s *LedgerService) post(ctx context.Context, tx pgx.Tx, journal *models.LedgerJournal) error {
+	totals := map[string]int{}
+	for _, entry := range journal.Entries {
+		totals[entry.Currency] += entry.Amount
+	}
+	for currency, total := range totals {
+		if total != 0 {
+			return fmt.Errorf("%w: %s journal %s is off by %d %s", ErrUnbalancedJournal, journal.Kind, journal.Reference, total, currency)
+		}
+	}
+
+	journal.ID = uuid.New().String()
+	posted, err := s.ledgerRepo.PostJournal(ctx, tx, journal)
+	if err != nil {
+		return err
+	}
+	if posted {
+		fmt.Printf("[LedgerService] Posted %s journal %s\n", journal.Kind, journal.Reference)
+	}
+
+	return nil
+}
+
+// GetBalances returns the debits, credits and balance of the accounts matching
+// req per currency. A merchant_id selects the merchant's pending and payable
+// accounts. Balances are on the account's normal side: debit minus credit for
+// assets, credit minus debit for liabilities and revenue.
+func (s *LedgerService) GetBalances(ctx context.Context, req dto.LedgerBalancesRequest) ([]dto.LedgerBalanceResponse, error) {
+	accountIDs := []string{}
+	if req.AccountID != "" {
+		accountIDs = append(accountIDs, req.AccountID)
+	}
+	if req.MerchantID != "" {
+		accountIDs = append(accountIDs, models.MerchantPendingAccount(req.MerchantID), models.MerchantPayableAccount(req.MerchantID))
+	}
+
+	balances, err := s.ledgerRepo.Balances(ctx, accountIDs, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.LedgerBalanceResponse, 0, len(balances))
+	for _, b := range balances {
+		accountType := models.LedgerAccountType(b.AccountID)
+		balance := b.Credit - b.Debit
+		if accountType == "ASSET" {
+			balance = -balance
+		}
+		res = append(res, dto.LedgerBalanceResponse{
+			AccountID: b.AccountID,
+			Type:      accountType,
+			Currency:  b.Currency,
+			Debit:     b.Debit,
+			Credit:    b.Credit,
+			Balance:   balance,
+		})
+	}
+
+	return res, nil
+}
+
+// ListJournals returns one page of journals matching req and the cursor of
+// the next page.
+func (s *LedgerService) ListJournals(ctx context.Context, req dto.ListLedgerJournalsRequest) (*dto.ListLedgerJournalsResponse, error) {
+	filter := models.LedgerJournalFilter{
+		Kind:      req.Kind,
+		SourceID:  req.SourceID,
+		AccountID: req.AccountID,
+		Limit:     req.Limit,
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	if req.Cursor != "" {
+		cursor, err := decodeLedgerJournalCursor(req.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidLedgerRequest)
+		}
+		filter.After = cursor
+	}
+
+	journals, err := s.ledgerRepo.ListJournals(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &dto.ListLedgerJournalsResponse{Data: []dto.LedgerJournalResponse{}}
+	if len(journals) > filter.Limit {
+		journals = journals[:filter.Limit]
+		last := journals[len(journals)-1]
+		next := encodeLedgerJournalCursor(models.LedgerJournalCursor{PostedAt: last.PostedAt.Format(time.RFC3339Nano), ID: last.ID})
+		res.NextCursor = &next
+	}
+
+	for _, journal := range journals {
+		item := dto.LedgerJournalResponse{
+			ID:        journal.ID,
+			Kind:      journal.Kind,
+			SourceID:  journal.SourceID,
+			Reference: journal.Reference,
+			PostedAt:  journal.PostedAt,
+			Entries:   []dto.LedgerEntryResponse{},
+			CreatedAt: journal.CreatedAt,
+		}
+		for _, entry := range journal.Entries {
+			line := dto.LedgerEntryResponse{AccountID: entry.AccountID, Currency: entry.Currency}
+			if entry.Amount > 0 {
+				line.Debit = entry.Amount
+			} else {
+				line.Credit = -entry.Amount
+			}
+			item.Entries = append(item.Entries, line)
+		}
+		res.Data = append(res.Data, item)
+	}
+
+	return res, nil
+}
+
+// CheckInvariants reports every journal whose entries do not sum to zero per
+// currency. The ledger is balanced when there is none.
+func (s *LedgerService) CheckInvariants(ctx context.Context) (*dto.LedgerInvariantsResponse, error) {
+	imbalances, err := s.ledgerRepo.ListImbalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &dto.LedgerInvariantsResponse{
+		Balanced:   len(imbalances) == 0,
+		Imbalances: make([]dto.LedgerImbalanceResponse, 0, len(imbalances)),
+	}
+	for _, imbalance := range imbalances {
+		res.Imbalances = append(res.Imbalances, dto.LedgerImbalanceResponse{
+			JournalID: imbalance.JournalID,
+			Currency:  imbalance.Currency,
+			Total:     imbalance.Total,
+		})
+	}
+
+	return res, nil
+}
+
+func encodeLedgerJournalCursor(cursor models.LedgerJournalCursor) string {
+	raw := cursor.PostedAt + "|" + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeLedgerJournalCursor(value string) (*models.LedgerJournalCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	postedAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	if _, err := time.Parse(time.RFC3339Nano, postedAt); err != nil {
+		return nil, err
+	}
+
+	return &models.LedgerJournalCursor{PostedAt: postedAt, ID: id}, nil
+}
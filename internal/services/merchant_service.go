@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/banggibima/be-assignment/internal/repositories"
+)
+
+var ErrInvalidMerchantRequest = errors.New("INVALID_MERCHANT_REQUEST")
+
+type MerchantSettlementConfigRepository interface {
+	GetByMerchantID(ctx context.Context, merchantID string) (*models.MerchantSettlementConfig, error)
+	Upsert(ctx context.Context, config *models.MerchantSettlementConfig) error
+	Delete(ctx context.Context, merchantID string) (bool, error)
+}
+
+// MerchantService manages per-merchant settings. A merchant's settlement
+// config decides which settlement day each of its payments belongs to;
+// merchants without one use defaultDay.
+type MerchantService struct {
+	configRepo MerchantSettlementConfigRepository
+	defaultDay models.SettlementDay
+}
+
+func NewMerchantService(configRepo MerchantSettlementConfigRepository, defaultDay models.SettlementDay) *MerchantService {
+	return &MerchantService{
+		configRepo: configRepo,
+		defaultDay: defaultDay,
+	}
+}
+
+// GetSettlementConfig returns the settlement day of merchantID, which is the
+// default one when the merchant has no config of its own.
+func (s *MerchantService) GetSettlementConfig(ctx context.Context, merchantID string) (*dto.MerchantSettlementConfigResponse, error) {
+	config, err := s.configRepo.GetByMerchantID(ctx, merchantID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return &dto.MerchantSettlementConfigResponse{
+				MerchantID: merchantID,
+				TimeZone:   s.defaultDay.TimeZone,
+				CutoffHour: s.defaultDay.CutoffHour,
+				Default:    true,
+			}, nil
+		}
+		return nil, err
+	}
+
+	return toMerchantSettlementConfigResponse(config), nil
+}
+
+// UpdateSettlementConfig sets the time zone and cut-off hour of merchantID.
+// Settlements already saved keep their days until their range is settled
+// again.
+func (s *MerchantService) UpdateSettlementConfig(ctx context.Context, merchantID string, req dto.UpdateMerchantSettlementConfigRequest) (*dto.MerchantSettlementConfigResponse, error) {
+	if _, err := time.LoadLocation(req.TimeZone); err != nil || req.TimeZone == "Local" {
+		return nil, fmt.Errorf("%w: unknown time_zone %q", ErrInvalidMerchantRequest, req.TimeZone)
+	}
+	if req.CutoffHour == nil || *req.CutoffHour < 0 || *req.CutoffHour > 23 {
+		return nil, fmt.Errorf("%w: cutoff_hour must be between 0 and 23", ErrInvalidMerchantRequest)
+	}
+
+	config := &models.MerchantSettlementConfig{
+		MerchantID: merchantID,
+		TimeZone:   req.TimeZone,
+		CutoffHour: *req.CutoffHour,
+	}
+	if err := s.configRepo.Upsert(ctx, config); err != nil {
+		return nil, err
+	}
+
+	return toMerchantSettlementConfigResponse(config), nil
+}
+
+// ResetSettlementConfig removes the config of merchantID so it uses the
+// default settlement day again.
+func (s *MerchantService) ResetSettlementConfig(ctx context.Context, merchantID string) (*dto.MerchantSettlementConfigResponse, error) {
+	if _, err := s.configRepo.Delete(ctx, merchantID); err != nil {
+		return nil, err
+	}
+
+	return s.GetSettlementConfig(ctx, merchantID)
+}
+
+func toMerchantSettlementConfigResponse(config *models.MerchantSettlementConfig) *dto.MerchantSettlementConfigResponse {
+	return &dto.MerchantSettlementConfigResponse{
+		MerchantID: config.MerchantID,
+		TimeZone:   config.TimeZone,
+		CutoffHour: config.CutoffHour,
+		UpdatedAt:  &config.UpdatedAt,
+	}
+}
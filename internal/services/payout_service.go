@@ -0,0 +1,345 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/banggibima/be-assignment/config"
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrPayoutNotFound          = errors.New("PAYOUT_NOT_FOUND")
+	ErrInvalidPayoutRequest    = errors.New("INVALID_PAYOUT_REQUEST")
+	ErrInvalidPayoutTransition = errors.New("INVALID_PAYOUT_TRANSITION")
+)
+
+type PayoutRepository interface {
+	Lock(ctx context.Context, tx pgx.Tx) error
+	ListUnpaidSettlements(ctx context.Context, tx pgx.Tx, date string, merchantIDs []string) ([]models.Settlement, error)
+	GetActive(ctx context.Context, tx pgx.Tx, merchantID, currency, payoutDate string) (*models.Payout, error)
+	Create(ctx context.Context, tx pgx.Tx, payout *models.Payout) error
+	AddItems(ctx context.Context, tx pgx.Tx, payoutID string, items []models.PayoutItem) (*models.Payout, error)
+	GetByID(ctx context.Context, id string) (*models.Payout, error)
+	ListItems(ctx context.Context, payoutID string) ([]models.PayoutItem, error)
+	List(ctx context.Context, filter models.PayoutFilter) ([]models.Payout, error)
+	UpdateStatus(ctx context.Context, tx pgx.Tx, id, from, to string, reference, failureReason *string) (*models.Payout, error)
+}
+
+// payoutTransitions maps each status a payout can be moved to onto the
+// statuses it may be in.
+var payoutTransitions = map[string][]string{
+	"SENT":      {"PENDING"},
+	"CONFIRMED": {"SENT"},
+	"FAILED":    {"PENDING", "SENT"},
+}
+
+// PayoutService rolls the payable amounts of settlements into payouts and
+// tracks them until the merchant's bank confirms them.
+type PayoutService struct {
+	db         *pgxpool.Pool
+	payoutRepo PayoutRepository
+	ledger     LedgerPoster
+	minAmount  int
+}
+
+func NewPayoutService(db *pgxpool.Pool, payoutRepo PayoutRepository, ledger LedgerPoster, cfg config.Payout) *PayoutService {
+	return &PayoutService{
+		db:         db,
+		payoutRepo: payoutRepo,
+		ledger:     ledger,
+		minAmount:  cfg.MinAmount,
+	}
+}
+
+// GeneratePayouts batches every settlement dated before the payout date that
+// is not paid out yet into one payout per merchant and currency on that date.
+// Settlements join the merchant's PENDING payout of the date when there is
+// one, and wait for a later payout date when that payout was already sent.
+// Otherwise a payout is only created once they add up to the minimum amount,
+// and smaller balances wait as well. Generating the same date again only
+// picks up settlements saved since. A settlement whose payable amount changed
+// after it was paid out, for example by a rerun, is paid out again for the
+// difference, which is deducted when it shrank. It returns the payouts
+// created or topped up.
+func (s *PayoutService) GeneratePayouts(ctx context.Context, req dto.GeneratePayoutsRequest) ([]dto.PayoutResponse, error) {
+	payoutDate := req.PayoutDate
+	if payoutDate == "" {
+		payoutDate = time.Now().UTC().Format("2006-01-02")
+	}
+	day, err := time.Parse("2006-01-02", payoutDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: payout_date must be YYYY-MM-DD", ErrInvalidPayoutRequest)
+	}
+
+	minAmount := s.minAmount
+	if req.MinAmount != nil {
+		minAmount = *req.MinAmount
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.payoutRepo.Lock(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	settlements, err := s.payoutRepo.ListUnpaidSettlements(ctx, tx, payoutDate, req.MerchantIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Settlements come ordered by merchant and currency, so each batch is a
+	// run of consecutive settlements.
+	batches := [][]models.PayoutItem{}
+	for i, settlement := range settlements {
+		item := models.PayoutItem{
+			MerchantID: settlement.MerchantID,
+			Date:       settlement.Date,
+			Currency:   settlement.Currency,
+			Amount:     settlement.PayableAmount,
+		}
+		if i == 0 || settlement.MerchantID != settlements[i-1].MerchantID || settlement.Currency != settlements[i-1].Currency {
+			batches = append(batches, []models.PayoutItem{item})
+			continue
+		}
+		batches[len(batches)-1] = append(batches[len(batches)-1], item)
+	}
+
+	res := []dto.PayoutResponse{}
+	for _, items := range batches {
+		merchantID, currency := items[0].MerchantID, items[0].Currency
+
+		payout, err := s.payoutRepo.GetActive(ctx, tx, merchantID, currency, payoutDate)
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			return nil, err
+		}
+		if payout != nil && payout.Status != "PENDING" {
+			continue
+		}
+
+		total := 0
+		for _, item := range items {
+			total += item.Amount
+		}
+		// A deduction larger than the payout waits for later settlements.
+		if payout != nil && payout.Amount+total < 0 {
+			continue
+		}
+		if payout == nil {
+			if total <= 0 || total < minAmount {
+				continue
+			}
+
+			payout = &models.Payout{
+				ID:         uuid.New().String(),
+				MerchantID: merchantID,
+				Currency:   currency,
+				PayoutDate: day,
+			}
+			if err := s.payoutRepo.Create(ctx, tx, payout); err != nil {
+				return nil, err
+			}
+		}
+
+		payout, err = s.payoutRepo.AddItems(ctx, tx, payout.ID, items)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *toPayoutResponse(payout))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("[PayoutService] Generated %d payouts for %s\n", len(res), payoutDate)
+
+	return res, nil
+}
+
+// ListPayouts returns one page of payouts matching req and the cursor of the
+// next page.
+func (s *PayoutService) ListPayouts(ctx context.Context, req dto.ListPayoutsRequest) (*dto.ListPayoutsResponse, error) {
+	filter := models.PayoutFilter{
+		MerchantID: req.MerchantID,
+		Currency:   req.Currency,
+		Status:     req.Status,
+		From:       req.From,
+		To:         req.To,
+		Limit:      req.Limit,
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	for _, date := range []string{req.From, req.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, fmt.Errorf("%w: from/to must be YYYY-MM-DD", ErrInvalidPayoutRequest)
+		}
+	}
+
+	if req.Cursor != "" {
+		cursor, err := decodePayoutCursor(req.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidPayoutRequest)
+		}
+		filter.After = cursor
+	}
+
+	payouts, err := s.payoutRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &dto.ListPayoutsResponse{Data: []dto.PayoutResponse{}}
+	if len(payouts) > filter.Limit {
+		payouts = payouts[:filter.Limit]
+		last := payouts[len(payouts)-1]
+		next := encodePayoutCursor(models.PayoutCursor{PayoutDate: last.PayoutDate.Format("2006-01-02"), ID: last.ID})
+		res.NextCursor = &next
+	}
+
+	for i := range payouts {
+		res.Data = append(res.Data, *toPayoutResponse(&payouts[i]))
+	}
+
+	return res, nil
+}
+
+// GetPayout returns a payout with the settlements it pays out.
+func (s *PayoutService) GetPayout(ctx context.Context, id string) (*dto.PayoutResponse, error) {
+	payout, err := s.payoutRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, ErrPayoutNotFound
+		}
+		return nil, err
+	}
+
+	payout.Items, err = s.payoutRepo.ListItems(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return toPayoutResponse(payout), nil
+}
+
+// UpdatePayoutStatus moves a payout one step along PENDING -> SENT ->
+// CONFIRMED, or to FAILED before or after it is sent. A failed payout needs a
+// reason, and its settlements are picked up again by the next payout
+// generation. Sending a payout and failing it afterwards are posted to the
+// ledger; a payout failed before it was sent never moved money, so it posts
+// nothing.
+func (s *PayoutService) UpdatePayoutStatus(ctx context.Context, id string, req dto.UpdatePayoutStatusRequest) (*dto.PayoutResponse, error) {
+	allowed, ok := payoutTransitions[req.Status]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidPayoutRequest, req.Status)
+	}
+	if req.Status == "FAILED" && (req.FailureReason == nil || *req.FailureReason == "") {
+		return nil, fmt.Errorf("%w: failure_reason is required when a payout fails", ErrInvalidPayoutRequest)
+	}
+
+	payout, err := s.payoutRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, ErrPayoutNotFound
+		}
+		return nil, err
+	}
+	from := payout.Status
+	if !slices.Contains(allowed, from) {
+		return nil, fmt.Errorf("%w: %s payout cannot become %s", ErrInvalidPayoutTransition, payout.Status, req.Status)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	updated, err := s.payoutRepo.UpdateStatus(ctx, tx, id, from, req.Status, req.Reference, req.FailureReason)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, fmt.Errorf("%w: payout changed status concurrently", ErrInvalidPayoutTransition)
+		}
+		return nil, err
+	}
+	if err := s.ledger.PostPayout(ctx, tx, updated); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("[PayoutService] Payout %s %s -> %s\n", id, from, req.Status)
+
+	return toPayoutResponse(updated), nil
+}
+
+func encodePayoutCursor(cursor models.PayoutCursor) string {
+	raw := cursor.PayoutDate + "|" + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodePayoutCursor(value string) (*models.PayoutCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	date, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return nil, err
+	}
+
+	return &models.PayoutCursor{PayoutDate: date, ID: id}, nil
+}
+
+func toPayoutResponse(payout *models.Payout) *dto.PayoutResponse {
+	res := &dto.PayoutResponse{
+		ID:              payout.ID,
+		MerchantID:      payout.MerchantID,
+		Currency:        payout.Currency,
+		PayoutDate:      payout.PayoutDate.Format("2006-01-02"),
+		Amount:          payout.Amount,
+		SettlementCount: payout.SettlementCount,
+		Status:          payout.Status,
+		Reference:       payout.Reference,
+		FailureReason:   payout.FailureReason,
+		SentAt:          payout.SentAt,
+		ConfirmedAt:     payout.ConfirmedAt,
+		FailedAt:        payout.FailedAt,
+		CreatedAt:       payout.CreatedAt,
+		UpdatedAt:       payout.UpdatedAt,
+	}
+	for _, item := range payout.Items {
+		res.Items = append(res.Items, dto.PayoutItemResponse{
+			Date:   item.Date.Format("2006-01-02"),
+			Amount: item.Amount,
+		})
+	}
+
+	return res
+}
@@ -0,0 +1,48 @@
+package services
+
+import "time"
+
+// ProgressReporter coalesces progress updates so a job writes its progress
+// once every rows processed rows or once per interval, whichever comes first,
+// instead of once per row.
+type ProgressReporter struct {
+	flush     func(processed int) error
+	rows      int
+	interval  time.Duration
+	pending   int
+	flushed   int
+	flushedAt time.Time
+}
+
+func NewProgressReporter(rows int, interval time.Duration, start int, flush func(processed int) error) *ProgressReporter {
+	return &ProgressReporter{
+		flush:     flush,
+		rows:      rows,
+		interval:  interval,
+		pending:   start,
+		flushed:   start,
+		flushedAt: time.Now(),
+	}
+}
+
+// Report records the current processed count and flushes it when due.
+func (p *ProgressReporter) Report(processed int) error {
+	p.pending = processed
+	if p.pending-p.flushed < p.rows && time.Since(p.flushedAt) < p.interval {
+		return nil
+	}
+	return p.Flush()
+}
+
+// Flush writes the latest processed count if it has not been written yet.
+func (p *ProgressReporter) Flush() error {
+	if p.pending == p.flushed {
+		return nil
+	}
+	if err := p.flush(p.pending); err != nil {
+		return err
+	}
+	p.flushed = p.pending
+	p.flushedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/banggibima/be-assignment/config"
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrReconciliationNotFound = errors.New("RECONCILIATION_NOT_FOUND")
+	ErrInvalidBankStatement   = errors.New("INVALID_BANK_STATEMENT")
+)
+
+type ReconciliationRepository interface {
+	ListCandidates(ctx context.Context, from, to string, currencies []string) ([]models.ReconciliationCandidate, error)
+	Create(ctx context.Context, tx pgx.Tx, reconciliation *models.Reconciliation) error
+	GetByID(ctx context.Context, id string) (*models.Reconciliation, error)
+	ListItems(ctx context.Context, reconciliationID, status string) ([]models.ReconciliationItem, error)
+}
+
+// ReconciliationService matches the lines of the platform's bank statements
+// against the payouts it sent and the settlements it expects to receive, to
+// prove the money actually moved.
+type ReconciliationService struct {
+	db                 *pgxpool.Pool
+	reconciliationRepo ReconciliationRepository
+	amountTolerance    int
+	dateToleranceDays  int
+}
+
+func NewReconciliationService(db *pgxpool.Pool, reconciliationRepo ReconciliationRepository, cfg config.Reconciliation) *ReconciliationService {
+	return &ReconciliationService{
+		db:                 db,
+		reconciliationRepo: reconciliationRepo,
+		amountTolerance:    cfg.AmountTolerance,
+		dateToleranceDays:  cfg.DateToleranceDays,
+	}
+}
+
+// Reconcile parses the bank statement in content, matches its lines and
+// saves the report. Debits are matched against sent payouts and credits
+// against settlement net amounts in the same currency, first by reference
+// and then by amount and value date within the tolerances. A line matched by
+// reference outside the tolerances is PARTIAL. Payouts and settlements dated
+// within the statement that no line matches are reported UNMATCHED next to
+// the unmatched lines.
+func (s *ReconciliationService) Reconcile(ctx context.Context, fileName string, content []byte, req dto.ReconcileRequest) (*dto.ReconciliationResponse, error) {
+	format := req.Format
+	if format == "" {
+		format = detectStatementFormat(fileName, content)
+	}
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+
+	amountTolerance := s.amountTolerance
+	if req.AmountTolerance != nil {
+		amountTolerance = *req.AmountTolerance
+	}
+	dateToleranceDays := s.dateToleranceDays
+	if req.DateToleranceDays != nil {
+		dateToleranceDays = *req.DateToleranceDays
+	}
+
+	lines, err := parseStatement(format, content, currency)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBankStatement, err)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: statement has no lines", ErrInvalidBankStatement)
+	}
+
+	from, to := lines[0].ValueDate, lines[0].ValueDate
+	currencySet := map[string]bool{}
+	currencies := []string{}
+	for _, line := range lines {
+		if line.ValueDate.Before(from) {
+			from = line.ValueDate
+		}
+		if line.ValueDate.After(to) {
+			to = line.ValueDate
+		}
+		if !currencySet[line.Currency] {
+			currencySet[line.Currency] = true
+			currencies = append(currencies, line.Currency)
+		}
+	}
+
+	tolerance := time.Duration(dateToleranceDays) * 24 * time.Hour
+	candidates, err := s.reconciliationRepo.ListCandidates(ctx, from.Add(-tolerance).Format("2006-01-02"), to.Add(tolerance).Format("2006-01-02"), currencies)
+	if err != nil {
+		return nil, err
+	}
+
+	reconciliation := &models.Reconciliation{
+		ID:                uuid.New().String(),
+		Format:            format,
+		FileName:          fileName,
+		StatementFrom:     from,
+		StatementTo:       to,
+		AmountTolerance:   amountTolerance,
+		DateToleranceDays: dateToleranceDays,
+		LineCount:         len(lines),
+		Items:             matchStatement(lines, candidates, amountTolerance, dateToleranceDays, from, to),
+	}
+	for _, item := range reconciliation.Items {
+		switch item.Status {
+		case models.ReconciliationMatched:
+			reconciliation.MatchedCount++
+		case models.ReconciliationPartial:
+			reconciliation.PartialCount++
+		default:
+			reconciliation.UnmatchedCount++
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.reconciliationRepo.Create(ctx, tx, reconciliation); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("[ReconciliationService] Reconciled %d lines of %s: %d matched, %d partial, %d unmatched\n",
+		len(lines), fileName, reconciliation.MatchedCount, reconciliation.PartialCount, reconciliation.UnmatchedCount)
+
+	return toReconciliationResponse(reconciliation), nil
+}
+
+// GetReconciliation returns a saved reconciliation report, narrowed to the
+// items of one status when req has one.
+func (s *ReconciliationService) GetReconciliation(ctx context.Context, id string, req dto.GetReconciliationRequest) (*dto.ReconciliationResponse, error) {
+	reconciliation, err := s.reconciliationRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, ErrReconciliationNotFound
+		}
+		return nil, err
+	}
+
+	reconciliation.Items, err = s.reconciliationRepo.ListItems(ctx, id, req.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	return toReconciliationResponse(reconciliation), nil
+}
+
+// matchStatement matches lines against candidates and returns the report
+// items: one per line in statement order, followed by the candidates dated in
+// [from, to] that no line matched. Candidates dated outside it are only there
+// to be matched within the date tolerance and are not reported missing.
+func matchStatement(lines []models.BankStatementLine, candidates []models.ReconciliationCandidate, amountTolerance, dateToleranceDays int, from, to time.Time) []models.ReconciliationItem {
+	used := make([]bool, len(candidates))
+	matches := make([]int, len(lines))
+	byReference := make([]bool, len(lines))
+	for i := range matches {
+		matches[i] = -1
+	}
+
+	sameSide := func(line models.BankStatementLine, c models.ReconciliationCandidate) bool {
+		return line.Currency == c.Currency && line.Amount != 0 && (line.Amount > 0) == (c.Amount > 0)
+	}
+	// closer reports whether candidate a is a better match for line than b:
+	// nearer in value date, then in amount.
+	closer := func(line models.BankStatementLine, a, b int) bool {
+		if b < 0 {
+			return true
+		}
+		dayA, dayB := daysBetween(line.ValueDate, candidates[a].Date), daysBetween(line.ValueDate, candidates[b].Date)
+		if dayA != dayB {
+			return dayA < dayB
+		}
+		return abs(line.Amount-candidates[a].Amount) < abs(line.Amount-candidates[b].Amount)
+	}
+
+	// References are matched first, so a line matched by amount alone cannot
+	// take the payout or settlement another line names.
+	for i, line := range lines {
+		best := -1
+		for j, c := range candidates {
+			if used[j] || !sameSide(line, c) || !referencesCandidate(line, c) {
+				continue
+			}
+			if closer(line, j, best) {
+				best = j
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+			matches[i] = best
+			byReference[i] = true
+		}
+	}
+
+	for i, line := range lines {
+		if matches[i] >= 0 {
+			continue
+		}
+		best := -1
+		for j, c := range candidates {
+			if used[j] || !sameSide(line, c) {
+				continue
+			}
+			if abs(line.Amount-c.Amount) > amountTolerance || daysBetween(line.ValueDate, c.Date) > dateToleranceDays {
+				continue
+			}
+			if closer(line, j, best) {
+				best = j
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+			matches[i] = best
+		}
+	}
+
+	items := make([]models.ReconciliationItem, 0, len(lines))
+	for i, line := range lines {
+		item := models.ReconciliationItem{
+			Status:    models.ReconciliationUnmatched,
+			LineNo:    &line.LineNo,
+			ValueDate: &line.ValueDate,
+			Amount:    &line.Amount,
+			Currency:  line.Currency,
+			Reason:    "no payout or settlement matches",
+		}
+		if line.Reference != "" {
+			item.Reference = &line.Reference
+		}
+		if line.Description != "" {
+			item.Description = &line.Description
+		}
+
+		if matches[i] >= 0 {
+			c := candidates[matches[i]]
+			item.MatchKind = &c.Kind
+			item.MatchID = &c.ID
+			item.MerchantID = &c.MerchantID
+			item.ExpectedAmount = &c.Amount
+			item.ExpectedDate = &c.Date
+			item.Difference = line.Amount - c.Amount
+
+			reasons := []string{}
+			if abs(item.Difference) > amountTolerance {
+				reasons = append(reasons, fmt.Sprintf("amount differs by %d", item.Difference))
+			}
+			if days := daysBetween(line.ValueDate, c.Date); days > dateToleranceDays {
+				reasons = append(reasons, fmt.Sprintf("value date is %d days from %s", days, c.Date.Format("2006-01-02")))
+			}
+
+			switch {
+			case len(reasons) > 0:
+				item.Status = models.ReconciliationPartial
+				item.Reason = "matched by reference, but " + strings.Join(reasons, " and ")
+			case byReference[i]:
+				item.Status = models.ReconciliationMatched
+				item.Reason = "matched by reference"
+			default:
+				item.Status = models.ReconciliationMatched
+				item.Reason = "matched by amount and value date"
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	missing := []models.ReconciliationCandidate{}
+	for j, c := range candidates {
+		if !used[j] && !c.Date.Before(from) && !c.Date.After(to) {
+			missing = append(missing, c)
+		}
+	}
+	sort.SliceStable(missing, func(a, b int) bool {
+		return missing[a].Date.Before(missing[b].Date)
+	})
+	for _, c := range missing {
+		items = append(items, models.ReconciliationItem{
+			Status:         models.ReconciliationUnmatched,
+			Currency:       c.Currency,
+			MatchKind:      &c.Kind,
+			MatchID:        &c.ID,
+			MerchantID:     &c.MerchantID,
+			ExpectedAmount: &c.Amount,
+			ExpectedDate:   &c.Date,
+			Difference:     -c.Amount,
+			Reason:         "not on the statement",
+		})
+	}
+
+	for i := range items {
+		items[i].Position = i + 1
+	}
+
+	return items
+}
+
+// referencesCandidate reports whether line names c by its ID or, for a
+// payout, the bank reference it was sent with.
+func referencesCandidate(line models.BankStatementLine, c models.ReconciliationCandidate) bool {
+	for _, reference := range []string{c.ID, c.Reference} {
+		if reference == "" {
+			continue
+		}
+		if strings.EqualFold(line.Reference, reference) || strings.Contains(line.Description, reference) {
+			return true
+		}
+	}
+	return false
+}
+
+func daysBetween(a, b time.Time) int {
+	return abs(int(a.Sub(b).Hours() / 24))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func toReconciliationResponse(reconciliation *models.Reconciliation) *dto.ReconciliationResponse {
+	res := &dto.ReconciliationResponse{
+		ID:                reconciliation.ID,
+		Format:            reconciliation.Format,
+		FileName:          reconciliation.FileName,
+		StatementFrom:     reconciliation.StatementFrom.Format("2006-01-02"),
+		StatementTo:       reconciliation.StatementTo.Format("2006-01-02"),
+		AmountTolerance:   reconciliation.AmountTolerance,
+		DateToleranceDays: reconciliation.DateToleranceDays,
+		LineCount:         reconciliation.LineCount,
+		MatchedCount:      reconciliation.MatchedCount,
+		PartialCount:      reconciliation.PartialCount,
+		UnmatchedCount:    reconciliation.UnmatchedCount,
+		Items:             make([]dto.ReconciliationItemResponse, 0, len(reconciliation.Items)),
+		CreatedAt:         reconciliation.CreatedAt,
+	}
+
+	formatDate := func(t *time.Time) *string {
+		if t == nil {
+			return nil
+		}
+		date := t.Format("2006-01-02")
+		return &date
+	}
+	for _, item := range reconciliation.Items {
+		res.Items = append(res.Items, dto.ReconciliationItemResponse{
+			Status:         item.Status,
+			LineNo:         item.LineNo,
+			ValueDate:      formatDate(item.ValueDate),
+			Amount:         item.Amount,
+			Currency:       item.Currency,
+			Reference:      item.Reference,
+			Description:    item.Description,
+			MatchKind:      item.MatchKind,
+			MatchID:        item.MatchID,
+			MerchantID:     item.MerchantID,
+			ExpectedAmount: item.ExpectedAmount,
+			ExpectedDate:   formatDate(item.ExpectedDate),
+			Difference:     item.Difference,
+			Reason:         item.Reason,
+		})
+	}
+
+	return res
+}
@@ -0,0 +1,446 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/parquet-go/parquet-go"
+	"github.com/xuri/excelize/v2"
+)
+
+var ErrUnsupportedReportFormat = errors.New("UNSUPPORTED_REPORT_FORMAT")
+
+// SettlementReportRow is one settlement of a job's report. Amounts are in the
+// row's currency.
+type SettlementReportRow struct {
+	MerchantID    string `json:"merchant_id" parquet:"merchant_id"`
+	Date          string `json:"date" parquet:"date"`
+	Currency      string `json:"currency" parquet:"currency"`
+	Gross         int    `json:"gross" parquet:"gross"`
+	Fee           int    `json:"fee" parquet:"fee"`
+	Net           int    `json:"net" parquet:"net"`
+	TxnCount      int    `json:"txn_count" parquet:"txn_count"`
+	PlanFee       *int   `json:"plan_fee" parquet:"plan_fee,optional"`
+	FeeMismatch   bool   `json:"fee_mismatch" parquet:"fee_mismatch"`
+	Refund        int    `json:"refund" parquet:"refund"`
+	Chargeback    int    `json:"chargeback" parquet:"chargeback"`
+	ReversalCount int    `json:"reversal_count" parquet:"reversal_count"`
+	CarriedIn     int    `json:"carried_in" parquet:"carried_in"`
+	Payable       int    `json:"payable" parquet:"payable"`
+	CarryForward  int    `json:"carry_forward" parquet:"carry_forward"`
+}
+
+// settlementReportColumns name the columns of the tabular report formats in
+// the order of SettlementReportRow.
+var settlementReportColumns = []string{"merchant_id", "date", "currency", "gross", "fee", "net", "txn_count", "plan_fee", "fee_mismatch", "refund", "chargeback", "reversal_count", "carried_in", "payable", "carry_forward"}
+
+// values returns the cells of row in the order of settlementReportColumns. A
+// missing plan fee is nil.
+func (row SettlementReportRow) values() []any {
+	var planFee any
+	if row.PlanFee != nil {
+		planFee = *row.PlanFee
+	}
+
+	return []any{row.MerchantID, row.Date, row.Currency, row.Gross, row.Fee, row.Net, row.TxnCount, planFee, row.FeeMismatch,
+		row.Refund, row.Chargeback, row.ReversalCount, row.CarriedIn, row.Payable, row.CarryForward}
+}
+
+func settlementReportRow(merchantID, date, currency string, settlement models.SettlementAggregate) SettlementReportRow {
+	return SettlementReportRow{
+		MerchantID:    merchantID,
+		Date:          date,
+		Currency:      currency,
+		Gross:         settlement.GrossAmount,
+		Fee:           settlement.FeeAmount,
+		Net:           settlement.NetAmount,
+		TxnCount:      settlement.TxnCount,
+		PlanFee:       settlement.PlanFeeAmount,
+		FeeMismatch:   settlement.FeeMismatch,
+		Refund:        settlement.RefundAmount,
+		Chargeback:    settlement.ChargebackAmount,
+		ReversalCount: settlement.ReversalCount,
+		CarriedIn:     settlement.CarriedIn,
+		Payable:       settlement.PayableAmount,
+		CarryForward:  settlement.CarryForward,
+	}
+}
+
+// SettlementReportTotal sums the rows of a report in one currency.
+type SettlementReportTotal struct {
+	Currency      string `json:"currency"`
+	Settlements   int    `json:"settlements"`
+	Gross         int    `json:"gross"`
+	Fee           int    `json:"fee"`
+	Net           int    `json:"net"`
+	TxnCount      int    `json:"txn_count"`
+	Refund        int    `json:"refund"`
+	Chargeback    int    `json:"chargeback"`
+	ReversalCount int    `json:"reversal_count"`
+	CarriedIn     int    `json:"carried_in"`
+	Payable       int    `json:"payable"`
+	CarryForward  int    `json:"carry_forward"`
+}
+
+// values returns the cells of the trailer row of total in the order of
+// settlementReportColumns.
+func (total SettlementReportTotal) values() []any {
+	return []any{"TOTAL", nil, total.Currency, total.Gross, total.Fee, total.Net, total.TxnCount, nil, nil,
+		total.Refund, total.Chargeback, total.ReversalCount, total.CarriedIn, total.Payable, total.CarryForward}
+}
+
+// SettlementReport is the result of a settlement job: a header naming the
+// job, its range and when the report was generated, the rows sorted by
+// merchant, date and currency, and a total per currency.
+type SettlementReport struct {
+	JobID       string                  `json:"job_id"`
+	From        string                  `json:"from"`
+	To          string                  `json:"to"`
+	GeneratedAt time.Time               `json:"generated_at"`
+	Rows        []SettlementReportRow   `json:"rows"`
+	Totals      []SettlementReportTotal `json:"totals"`
+}
+
+// newSettlementReport sorts rows and sums them into the report of job.
+func newSettlementReport(job *models.Job, rows []SettlementReportRow, generatedAt time.Time) *SettlementReport {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].MerchantID != rows[j].MerchantID {
+			return rows[i].MerchantID < rows[j].MerchantID
+		}
+		if rows[i].Date != rows[j].Date {
+			return rows[i].Date < rows[j].Date
+		}
+		return rows[i].Currency < rows[j].Currency
+	})
+
+	totals := map[string]*SettlementReportTotal{}
+	for _, row := range rows {
+		total, ok := totals[row.Currency]
+		if !ok {
+			total = &SettlementReportTotal{Currency: row.Currency}
+			totals[row.Currency] = total
+		}
+		total.Settlements++
+		total.Gross += row.Gross
+		total.Fee += row.Fee
+		total.Net += row.Net
+		total.TxnCount += row.TxnCount
+		total.Refund += row.Refund
+		total.Chargeback += row.Chargeback
+		total.ReversalCount += row.ReversalCount
+		total.CarriedIn += row.CarriedIn
+		total.Payable += row.Payable
+		total.CarryForward += row.CarryForward
+	}
+
+	report := &SettlementReport{
+		JobID:       job.JobID,
+		From:        job.From,
+		To:          job.To,
+		GeneratedAt: generatedAt.UTC().Truncate(time.Second),
+		Rows:        rows,
+		Totals:      make([]SettlementReportTotal, 0, len(totals)),
+	}
+	if report.Rows == nil {
+		report.Rows = []SettlementReportRow{}
+	}
+	for _, total := range totals {
+		report.Totals = append(report.Totals, *total)
+	}
+	sort.Slice(report.Totals, func(i, j int) bool { return report.Totals[i].Currency < report.Totals[j].Currency })
+
+	return report
+}
+
+// headerValues returns the header block of report as name and value pairs.
+func (report *SettlementReport) headerValues() [][2]string {
+	return [][2]string{
+		{"job_id", report.JobID},
+		{"from", report.From},
+		{"to", report.To},
+		{"generated_at", report.GeneratedAt.Format(time.RFC3339)},
+	}
+}
+
+// SettlementReportWriter writes a settlement report in one file format.
+type SettlementReportWriter interface {
+	Format() string
+	ContentType() string
+	Extension() string
+	Write(w io.Writer, report *SettlementReport) error
+}
+
+// settlementReportWriters are the report writers by format, CSV first.
+var settlementReportWriters = []SettlementReportWriter{
+	csvReportWriter{},
+	jsonReportWriter{},
+	ndjsonReportWriter{},
+	xlsxReportWriter{},
+	parquetReportWriter{},
+}
+
+// NewSettlementReportWriter returns the writer of format.
+func NewSettlementReportWriter(format string) (SettlementReportWriter, error) {
+	for _, writer := range settlementReportWriters {
+		if writer.Format() == format {
+			return writer, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedReportFormat, format)
+}
+
+// ReportContentTypes returns the content type of every report format, with
+// that of format first.
+func ReportContentTypes(format string) []string {
+	contentTypes := []string{}
+	for _, writer := range settlementReportWriters {
+		if writer.Format() == format {
+			contentTypes = append([]string{writer.ContentType()}, contentTypes...)
+			continue
+		}
+		contentTypes = append(contentTypes, writer.ContentType())
+	}
+
+	return contentTypes
+}
+
+// ReportFormatOf returns the report format written as contentType, or an
+// empty string when there is none.
+func ReportFormatOf(contentType string) string {
+	for _, writer := range settlementReportWriters {
+		if writer.ContentType() == contentType {
+			return writer.Format()
+		}
+	}
+
+	return ""
+}
+
+type csvReportWriter struct{}
+
+func (csvReportWriter) Format() string      { return models.ReportFormatCSV }
+func (csvReportWriter) ContentType() string { return "text/csv" }
+func (csvReportWriter) Extension() string   { return ".csv" }
+
+func (csvReportWriter) Write(w io.Writer, report *SettlementReport) error {
+	writer := csv.NewWriter(w)
+	for _, field := range report.headerValues() {
+		writer.Write(field[:])
+	}
+	writer.Write(nil)
+	writer.Write(settlementReportColumns)
+
+	record := func(values []any) []string {
+		cells := make([]string, 0, len(values))
+		for _, value := range values {
+			switch v := value.(type) {
+			case nil:
+				cells = append(cells, "")
+			case int:
+				cells = append(cells, strconv.Itoa(v))
+			case bool:
+				cells = append(cells, strconv.FormatBool(v))
+			default:
+				cells = append(cells, fmt.Sprint(v))
+			}
+		}
+		return cells
+	}
+	for _, row := range report.Rows {
+		writer.Write(record(row.values()))
+	}
+	for _, total := range report.Totals {
+		writer.Write(record(total.values()))
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// jsonReportWriter writes the report as one JSON object.
+type jsonReportWriter struct{}
+
+func (jsonReportWriter) Format() string      { return models.ReportFormatJSON }
+func (jsonReportWriter) ContentType() string { return "application/json" }
+func (jsonReportWriter) Extension() string   { return ".json" }
+
+func (jsonReportWriter) Write(w io.Writer, report *SettlementReport) error {
+	return json.NewEncoder(w).Encode(report)
+}
+
+// ndjsonReportWriter writes the header, every row and every total as a JSON
+// object on its own line, told apart by their record field.
+type ndjsonReportWriter struct{}
+
+func (ndjsonReportWriter) Format() string      { return models.ReportFormatNDJSON }
+func (ndjsonReportWriter) ContentType() string { return "application/x-ndjson" }
+func (ndjsonReportWriter) Extension() string   { return ".ndjson" }
+
+func (ndjsonReportWriter) Write(w io.Writer, report *SettlementReport) error {
+	encoder := json.NewEncoder(w)
+
+	header := struct {
+		Record      string    `json:"record"`
+		JobID       string    `json:"job_id"`
+		From        string    `json:"from"`
+		To          string    `json:"to"`
+		GeneratedAt time.Time `json:"generated_at"`
+	}{"header", report.JobID, report.From, report.To, report.GeneratedAt}
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+
+	for _, row := range report.Rows {
+		record := struct {
+			Record string `json:"record"`
+			SettlementReportRow
+		}{"row", row}
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	for _, total := range report.Totals {
+		record := struct {
+			Record string `json:"record"`
+			SettlementReportTotal
+		}{"total", total}
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// xlsxReportWriter writes the report to the Settlements sheet of a
+// workbook: the header block, a blank row, the table with amounts as numbers
+// and the totals below it.
+type xlsxReportWriter struct{}
+
+func (xlsxReportWriter) Format() string { return models.ReportFormatXLSX }
+func (xlsxReportWriter) ContentType() string {
+	return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+}
+func (xlsxReportWriter) Extension() string { return ".xlsx" }
+
+func (xlsxReportWriter) Write(w io.Writer, report *SettlementReport) error {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	const sheet = "Settlements"
+	if err := file.SetSheetName("Sheet1", sheet); err != nil {
+		return err
+	}
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+
+	line := 0
+	setRow := func(values []any) error {
+		line++
+		cell, err := excelize.CoordinatesToCellName(1, line)
+		if err != nil {
+			return err
+		}
+		return stream.SetRow(cell, values)
+	}
+
+	for _, field := range report.headerValues() {
+		if err := setRow([]any{field[0], field[1]}); err != nil {
+			return err
+		}
+	}
+	line++
+
+	header := make([]any, 0, len(settlementReportColumns))
+	for _, column := range settlementReportColumns {
+		header = append(header, column)
+	}
+	if err := setRow(header); err != nil {
+		return err
+	}
+	for _, row := range report.Rows {
+		if err := setRow(row.values()); err != nil {
+			return err
+		}
+	}
+	for _, total := range report.Totals {
+		if err := setRow(total.values()); err != nil {
+			return err
+		}
+	}
+	if err := stream.Flush(); err != nil {
+		return err
+	}
+
+	return file.Write(w)
+}
+
+// parquetReportWriter writes the rows as a Parquet file with the columns of
+// SettlementReportRow; plan_fee is optional. The header block and the totals,
+// as JSON, are key-value metadata of the file.
+type parquetReportWriter struct{}
+
+func (parquetReportWriter) Format() string      { return models.ReportFormatParquet }
+func (parquetReportWriter) ContentType() string { return "application/vnd.apache.parquet" }
+func (parquetReportWriter) Extension() string   { return ".parquet" }
+
+func (parquetReportWriter) Write(w io.Writer, report *SettlementReport) error {
+	totals, err := json.Marshal(report.Totals)
+	if err != nil {
+		return err
+	}
+
+	options := []parquet.WriterOption{parquet.KeyValueMetadata("totals", string(totals))}
+	for _, field := range report.headerValues() {
+		options = append(options, parquet.KeyValueMetadata(field[0], field[1]))
+	}
+
+	writer := parquet.NewGenericWriter[SettlementReportRow](w, options...)
+	if _, err := writer.Write(report.Rows); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// renderSettlementReport writes report with writer to w and returns the
+// manifest of what it wrote.
+func renderSettlementReport(w io.Writer, writer SettlementReportWriter, report *SettlementReport) (*models.ReportManifest, error) {
+	hash := sha256.New()
+	size := &byteCounter{}
+	if err := writer.Write(io.MultiWriter(w, hash, size), report); err != nil {
+		return nil, err
+	}
+
+	return &models.ReportManifest{
+		FileName:    report.JobID + writer.Extension(),
+		Format:      writer.Format(),
+		ContentType: writer.ContentType(),
+		Size:        size.n,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		Rows:        len(report.Rows),
+		GeneratedAt: report.GeneratedAt,
+	}, nil
+}
+
+// byteCounter counts the bytes written to it.
+type byteCounter struct {
+	n int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrSettlementRunNotFound       = errors.New("SETTLEMENT_RUN_NOT_FOUND")
+	ErrInvalidSettlementRunRequest = errors.New("INVALID_SETTLEMENT_RUN_REQUEST")
+	ErrLaterSettlementsExist       = errors.New("LATER_SETTLEMENTS_EXIST")
+)
+
+type SettlementRunRepository interface {
+	CreateRun(ctx context.Context, tx pgx.Tx, run *models.SettlementRun) error
+	GetRun(ctx context.Context, runID string) (*models.SettlementRun, error)
+	CopyRunRows(ctx context.Context, tx pgx.Tx, sourceRunID, runID, from, to string) error
+	ReplaceFromRun(ctx context.Context, tx pgx.Tx, runID, from, to string, filter *models.TransactionFilter) error
+	HasLaterSettlements(ctx context.Context, tx pgx.Tx, runID, from, to string, filter *models.TransactionFilter) (bool, error)
+	Lock(ctx context.Context, tx pgx.Tx) error
+	ListRunVersions(ctx context.Context, merchantID, date, currency string) ([]models.SettlementRunVersion, error)
+}
+
+// SettlementRunService exposes the history of settlement runs. Every job
+// that saves settlements records a run with the rows it produced, so any
+// earlier run can be restored.
+type SettlementRunService struct {
+	db             *pgxpool.Pool
+	settlementRepo SettlementRunRepository
+	ledger         LedgerPoster
+}
+
+func NewSettlementRunService(db *pgxpool.Pool, settlementRepo SettlementRunRepository, ledger LedgerPoster) *SettlementRunService {
+	return &SettlementRunService{
+		db:             db,
+		settlementRepo: settlementRepo,
+		ledger:         ledger,
+	}
+}
+
+// ListRuns returns the runs that covered merchantID on date, newest first.
+func (s *SettlementRunService) ListRuns(ctx context.Context, req dto.ListSettlementRunsRequest) ([]dto.SettlementRunVersionResponse, error) {
+	if _, err := time.Parse("2006-01-02", req.Date); err != nil {
+		return nil, fmt.Errorf("%w: date must be YYYY-MM-DD", ErrInvalidSettlementRunRequest)
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+
+	versions, err := s.settlementRepo.ListRunVersions(ctx, req.MerchantID, req.Date, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.SettlementRunVersionResponse, 0, len(versions))
+	for _, v := range versions {
+		item := dto.SettlementRunVersionResponse{
+			SettlementRunResponse: *toSettlementRunResponse(&v.Run),
+			Current:               v.Current,
+		}
+		if v.Settlement != nil {
+			item.Settlement = toSettlementResponse(v.Settlement)
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// Rollback restores the settlements of runID for [req.From, req.To], which
+// defaults to the run's whole range. Only the merchants the run settled are
+// touched. The restore is recorded as a new ROLLBACK run, so it can itself be
+// rolled back, and posted to the ledger like any other run. It is refused
+// while any of those merchants has a settlement after the range in the same
+// currency, as that settlement carried in a deficit the restored rows would
+// no longer match.
+func (s *SettlementRunService) Rollback(ctx context.Context, runID string, req dto.RollbackSettlementRunRequest) (*dto.SettlementRunResponse, error) {
+	source, err := s.settlementRepo.GetRun(ctx, runID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, ErrSettlementRunNotFound
+		}
+		return nil, err
+	}
+
+	from, to := req.From, req.To
+	if from == "" {
+		from = source.From
+	}
+	if to == "" {
+		to = source.To
+	}
+	for _, date := range []string{from, to} {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, fmt.Errorf("%w: from/to must be YYYY-MM-DD", ErrInvalidSettlementRunRequest)
+		}
+	}
+	if from > to || from < source.From || to > source.To {
+		return nil, fmt.Errorf("%w: %s..%s is not within run range %s..%s", ErrInvalidSettlementRunRequest, from, to, source.From, source.To)
+	}
+
+	sourceRunID := source.ID
+	run := &models.SettlementRun{
+		ID:          uuid.New().String(),
+		Kind:        "ROLLBACK",
+		SourceRunID: &sourceRunID,
+		Filter:      source.Filter,
+		From:        from,
+		To:          to,
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.settlementRepo.Lock(ctx, tx); err != nil {
+		return nil, err
+	}
+	later, err := s.settlementRepo.HasLaterSettlements(ctx, tx, source.ID, from, to, run.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if later {
+		return nil, fmt.Errorf("%w: settlements after %s depend on %s..%s", ErrLaterSettlementsExist, to, from, to)
+	}
+	if err := s.settlementRepo.CreateRun(ctx, tx, run); err != nil {
+		return nil, err
+	}
+	if err := s.settlementRepo.CopyRunRows(ctx, tx, source.ID, run.ID, from, to); err != nil {
+		return nil, err
+	}
+	if err := s.ledger.PostSettlementRun(ctx, tx, run); err != nil {
+		return nil, err
+	}
+	if err := s.settlementRepo.ReplaceFromRun(ctx, tx, run.ID, from, to, run.Filter); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("[SettlementRunService] Rolled %s..%s back to run %s\n", from, to, source.ID)
+
+	return toSettlementRunResponse(run), nil
+}
+
+func toSettlementRunResponse(run *models.SettlementRun) *dto.SettlementRunResponse {
+	res := &dto.SettlementRunResponse{
+		RunID:       run.ID,
+		JobID:       run.JobID,
+		Kind:        run.Kind,
+		SourceRunID: run.SourceRunID,
+		From:        run.From,
+		To:          run.To,
+		CreatedAt:   run.CreatedAt,
+	}
+
+	if run.Filter != nil {
+		res.MerchantIDs = run.Filter.MerchantIDs
+		res.ExcludeMerchantIDs = run.Filter.ExcludeMerchantIDs
+		res.Statuses = run.Filter.Statuses
+	}
+
+	return res
+}
+
+func toSettlementResponse(settlement *models.Settlement) *dto.SettlementResponse {
+	return &dto.SettlementResponse{
+		MerchantID:       settlement.MerchantID,
+		Date:             settlement.Date.Format("2006-01-02"),
+		Currency:         settlement.Currency,
+		GrossAmount:      settlement.GrossAmount,
+		FeeAmount:        settlement.FeeAmount,
+		NetAmount:        settlement.NetAmount,
+		TxnCount:         settlement.TxnCount,
+		PlanFeeAmount:    settlement.PlanFeeAmount,
+		FeeMismatch:      settlement.FeeMismatch,
+		RefundAmount:     settlement.RefundAmount,
+		ChargebackAmount: settlement.ChargebackAmount,
+		ReversalCount:    settlement.ReversalCount,
+		CarriedIn:        settlement.CarriedIn,
+		PayableAmount:    settlement.PayableAmount,
+		CarryForward:     settlement.CarryForward,
+		RunID:            settlement.UniqueRunID,
+	}
+}
@@ -2,29 +2,51 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/banggibima/be-assignment/internal/repositories"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type TransactionRepository interface {
-	FetchBatch(ctx context.Context, from, to string, limit, offset int) (pgx.Rows, error)
-	CountByDateRange(ctx context.Context, from, to string) (int, error)
+	FetchBatch(ctx context.Context, from, to string, filter models.TransactionFilter, after models.SettlementLineCursor, limit int) ([]models.SettlementLine, error)
+	AggregateByDay(ctx context.Context, date string, filter models.TransactionFilter) ([]models.Settlement, error)
+	CountByDateRange(ctx context.Context, from, to string, filter models.TransactionFilter) (int, error)
+	CountCurrencyMismatches(ctx context.Context, from, to string, filter models.TransactionFilter) (int, error)
+}
+
+var (
+	ErrSettlementNotFound     = errors.New("SETTLEMENT_NOT_FOUND")
+	ErrInvalidSettlementQuery = errors.New("INVALID_SETTLEMENT_QUERY")
+)
+
+type SettlementQueryRepository interface {
+	List(ctx context.Context, filter models.SettlementFilter) ([]models.Settlement, error)
+	Totals(ctx context.Context, filter models.SettlementFilter) ([]models.SettlementTotals, error)
+	GetByMerchantAndDate(ctx context.Context, merchantID, date, currency string) (*models.Settlement, error)
 }
 
 type SettlementService struct {
 	db              *pgxpool.Pool
 	transactionRepo TransactionRepository
+	settlementRepo  SettlementQueryRepository
 }
 
 func NewSettlementService(
 	db *pgxpool.Pool,
 	transactionRepo TransactionRepository,
+	settlementRepo SettlementQueryRepository,
 ) *SettlementService {
 	return &SettlementService{
 		db:              db,
 		transactionRepo: transactionRepo,
+		settlementRepo:  settlementRepo,
 	}
 }
 
@@ -34,12 +56,12 @@ func (s *SettlementService) ProcessSettlementWithCancel(
 	cancel <-chan struct{},
 	onProgress func(processed, total int),
 ) error {
-	total, err := s.transactionRepo.CountByDateRange(ctx, from, to)
+	total, err := s.transactionRepo.CountByDateRange(ctx, from, to, models.TransactionFilter{})
 	if err != nil {
 		return err
 	}
 
-	offset := 0
+	var after models.SettlementLineCursor
 	limit := 5000
 	processed := 0
 
@@ -51,13 +73,15 @@ func (s *SettlementService) ProcessSettlementWithCancel(
 		default:
 		}
 
-		rows, err := s.transactionRepo.FetchBatch(ctx, from, to, limit, offset)
+		lines, err := s.transactionRepo.FetchBatch(ctx, from, to, models.TransactionFilter{}, after, limit)
 		if err != nil {
 			return err
 		}
 
 		count := 0
-		for rows.Next() {
+		for _, line := range lines {
+			after = models.SettlementLineCursor{OccurredAt: line.OccurredAt.Format(time.RFC3339Nano), TransactionID: line.TransactionID, Kind: line.Kind}
+
 			count++
 			processed++
 
@@ -65,13 +89,139 @@ func (s *SettlementService) ProcessSettlementWithCancel(
 				onProgress(processed, total)
 			}
 		}
-		rows.Close()
 
 		if count < limit {
 			break
 		}
-		offset += limit
 	}
 
 	return nil
 }
+
+// ListSettlements returns one page of settlements matching req, the cursor of
+// the next page, and the totals over every matching settlement.
+func (s *SettlementService) ListSettlements(ctx context.Context, req dto.ListSettlementsRequest) (*dto.ListSettlementsResponse, error) {
+	filter := models.SettlementFilter{
+		MerchantID:  req.MerchantID,
+		Currency:    req.Currency,
+		From:        req.From,
+		To:          req.To,
+		RunID:       req.RunID,
+		FeeMismatch: req.FeeMismatch,
+		Limit:       req.Limit,
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	for _, date := range []string{req.From, req.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, fmt.Errorf("%w: from/to must be YYYY-MM-DD", ErrInvalidSettlementQuery)
+		}
+	}
+
+	if req.Cursor != "" {
+		cursor, err := decodeSettlementCursor(req.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidSettlementQuery)
+		}
+		filter.After = cursor
+	}
+
+	settlements, err := s.settlementRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := s.settlementRepo.Totals(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &dto.ListSettlementsResponse{
+		Data:   []dto.SettlementResponse{},
+		Totals: make([]dto.SettlementTotalsResponse, 0, len(totals)),
+	}
+	for _, t := range totals {
+		res.Totals = append(res.Totals, dto.SettlementTotalsResponse{
+			Currency:         t.Currency,
+			GrossAmount:      t.GrossAmount,
+			FeeAmount:        t.FeeAmount,
+			NetAmount:        t.NetAmount,
+			TxnCount:         t.TxnCount,
+			RefundAmount:     t.RefundAmount,
+			ChargebackAmount: t.ChargebackAmount,
+			PayableAmount:    t.PayableAmount,
+			PlanFeeAmount:    t.PlanFeeAmount,
+			FeeMismatches:    t.FeeMismatches,
+		})
+	}
+	if len(settlements) > filter.Limit {
+		settlements = settlements[:filter.Limit]
+		last := settlements[len(settlements)-1]
+		next := encodeSettlementCursor(models.SettlementCursor{Date: last.Date.Format("2006-01-02"), MerchantID: last.MerchantID, Currency: last.Currency})
+		res.NextCursor = &next
+	}
+
+	for i := range settlements {
+		item := toSettlementResponse(&settlements[i])
+		item.GeneratedAt = &settlements[i].GeneratedAt
+		res.Data = append(res.Data, *item)
+	}
+
+	return res, nil
+}
+
+// GetSettlement returns the settlement of merchantID on date in currency,
+// which defaults to models.DefaultCurrency.
+func (s *SettlementService) GetSettlement(ctx context.Context, merchantID, date, currency string) (*dto.SettlementResponse, error) {
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return nil, fmt.Errorf("%w: date must be YYYY-MM-DD", ErrInvalidSettlementQuery)
+	}
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+
+	settlement, err := s.settlementRepo.GetByMerchantAndDate(ctx, merchantID, date, currency)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, ErrSettlementNotFound
+		}
+		return nil, err
+	}
+
+	res := toSettlementResponse(settlement)
+	res.GeneratedAt = &settlement.GeneratedAt
+
+	return res, nil
+}
+
+func encodeSettlementCursor(cursor models.SettlementCursor) string {
+	raw := cursor.Date + "|" + cursor.Currency + "|" + cursor.MerchantID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSettlementCursor(value string) (*models.SettlementCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	date, rest, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	currency, merchantID, ok := strings.Cut(rest, "|")
+	if !ok || currency == "" || merchantID == "" {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return nil, err
+	}
+
+	return &models.SettlementCursor{Date: date, MerchantID: merchantID, Currency: currency}, nil
+}
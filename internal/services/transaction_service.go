@@ -0,0 +1,477 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrTransactionNotFound = errors.New("TRANSACTION_NOT_FOUND")
+	ErrInvalidTransaction  = errors.New("INVALID_TRANSACTION")
+	ErrOrderNotFound       = errors.New("ORDER_NOT_FOUND")
+	ErrInvalidImport       = errors.New("INVALID_TRANSACTION_IMPORT")
+)
+
+type TransactionIngestRepository interface {
+	GetByID(ctx context.Context, id string) (*models.Transaction, error)
+	ListUnknownOrders(ctx context.Context, orderIDs []string) ([]string, error)
+	Insert(ctx context.Context, tx pgx.Tx, transactions []models.Transaction) ([]string, error)
+}
+
+// importBatchSize is how many valid rows a bulk import checks and inserts
+// at a time.
+const importBatchSize = 500
+
+// TransactionService records payments and posts them to the ledger.
+// Transactions are identified by the ID the caller gives them: recording an
+// ID again keeps the first version, so retried requests and re-uploaded files
+// do not record or post a payment twice.
+type TransactionService struct {
+	db              *pgxpool.Pool
+	transactionRepo TransactionIngestRepository
+	ledger          LedgerPoster
+}
+
+func NewTransactionService(db *pgxpool.Pool, transactionRepo TransactionIngestRepository, ledger LedgerPoster) *TransactionService {
+	return &TransactionService{
+		db:              db,
+		transactionRepo: transactionRepo,
+		ledger:          ledger,
+	}
+}
+
+// CreateTransaction records one transaction. It reports whether it was
+// recorded now; when its ID was recorded before, the stored transaction is
+// returned unchanged.
+func (s *TransactionService) CreateTransaction(ctx context.Context, req dto.CreateTransactionRequest) (*dto.TransactionResponse, bool, error) {
+	transaction, err := newTransaction(req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	unknown, err := s.transactionRepo.ListUnknownOrders(ctx, []string{transaction.OrderID})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(unknown) > 0 {
+		return nil, false, ErrOrderNotFound
+	}
+
+	inserted, err := s.insert(ctx, []models.Transaction{*transaction})
+	if err != nil {
+		return nil, false, err
+	}
+
+	stored, err := s.transactionRepo.GetByID(ctx, transaction.ID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	created := len(inserted) > 0
+	if created {
+		fmt.Printf("[TransactionService] Recorded transaction %s\n", transaction.ID)
+	}
+
+	return toTransactionResponse(stored), created, nil
+}
+
+func (s *TransactionService) GetTransaction(ctx context.Context, id string) (*dto.TransactionResponse, error) {
+	transaction, err := s.transactionRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, ErrTransactionNotFound
+		}
+		return nil, err
+	}
+
+	return toTransactionResponse(transaction), nil
+}
+
+// importRow is a valid row of a bulk import waiting to be inserted.
+type importRow struct {
+	row         int
+	transaction models.Transaction
+}
+
+// ImportTransactions records the transactions in body, one JSON object per
+// line for NDJSON or one row under a header for CSV. Every row is validated
+// on its own: invalid rows and rows of unknown orders are reported with their
+// line and skipped, while the others are recorded in batches. Rows whose ID
+// is recorded already, or appears earlier in body, count as duplicates.
+func (s *TransactionService) ImportTransactions(ctx context.Context, format string, body io.Reader) (*dto.ImportTransactionsResponse, error) {
+	res := &dto.ImportTransactionsResponse{Errors: []dto.TransactionImportError{}}
+	seen := map[string]bool{}
+	batch := []importRow{}
+
+	reject := func(row int, id string, err error) {
+		res.Failed++
+		res.Errors = append(res.Errors, dto.TransactionImportError{Row: row, ID: id, Error: err.Error()})
+	}
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		orderIDs := make([]string, 0, len(batch))
+		for _, r := range batch {
+			orderIDs = append(orderIDs, r.transaction.OrderID)
+		}
+		unknown, err := s.transactionRepo.ListUnknownOrders(ctx, orderIDs)
+		if err != nil {
+			return err
+		}
+
+		transactions := make([]models.Transaction, 0, len(batch))
+		for _, r := range batch {
+			if slices.Contains(unknown, r.transaction.OrderID) {
+				reject(r.row, r.transaction.ID, fmt.Errorf("%w: %s", ErrOrderNotFound, r.transaction.OrderID))
+				// A later row with the same ID may still be recorded.
+				delete(seen, r.transaction.ID)
+				continue
+			}
+			transactions = append(transactions, r.transaction)
+		}
+
+		inserted, err := s.insert(ctx, transactions)
+		if err != nil {
+			return err
+		}
+		res.Created += len(inserted)
+		res.Duplicates += len(transactions) - len(inserted)
+
+		batch = batch[:0]
+		return nil
+	}
+
+	handle := func(row int, req dto.CreateTransactionRequest, err error) error {
+		res.Total++
+		if err != nil {
+			reject(row, req.ID, fmt.Errorf("%w: %v", ErrInvalidTransaction, err))
+			return nil
+		}
+
+		transaction, err := newTransaction(req)
+		if err != nil {
+			reject(row, req.ID, err)
+			return nil
+		}
+		if seen[transaction.ID] {
+			res.Duplicates++
+			return nil
+		}
+		seen[transaction.ID] = true
+
+		batch = append(batch, importRow{row: row, transaction: *transaction})
+		if len(batch) >= importBatchSize {
+			return flush()
+		}
+		return nil
+	}
+
+	var err error
+	switch format {
+	case "NDJSON":
+		err = readNDJSONTransactions(body, handle)
+	case "CSV":
+		err = readCSVTransactions(body, handle)
+	default:
+		err = fmt.Errorf("%w: unknown format %q", ErrInvalidImport, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("[TransactionService] Imported %d rows: %d created, %d duplicates, %d failed\n", res.Total, res.Created, res.Duplicates, res.Failed)
+
+	return res, nil
+}
+
+// insert records transactions and posts the payment, fee and reversal
+// journals of the ones recorded now in the same database transaction. It
+// returns their IDs; transactions recorded before are left out.
+func (s *TransactionService) insert(ctx context.Context, transactions []models.Transaction) ([]string, error) {
+	if len(transactions) == 0 {
+		return nil, nil
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	inserted, err := s.transactionRepo.Insert(ctx, tx, transactions)
+	if err != nil {
+		return nil, err
+	}
+	if len(inserted) > 0 {
+		if err := s.ledger.PostTransactions(ctx, tx, inserted); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return inserted, nil
+}
+
+// readNDJSONTransactions calls handle with every non-blank line of body
+// decoded as a transaction, or with the reason it could not be.
+func readNDJSONTransactions(body io.Reader, handle func(row int, req dto.CreateTransactionRequest, err error) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var req dto.CreateTransactionRequest
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&req)
+		if err == nil && decoder.More() {
+			err = fmt.Errorf("line holds more than one JSON value")
+		}
+		if err := handle(line, req, err); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	return nil
+}
+
+// transactionCSVColumns are the columns a CSV import may have, named like the
+// JSON fields of dto.CreateTransactionRequest.
+var transactionCSVColumns = []string{"id", "order_id", "merchant_id", "amount", "fee", "currency", "fee_currency", "status", "paid_at", "reversal_amount", "reversed_at"}
+
+// readCSVTransactions calls handle with every row of body under its header.
+// Timestamps are RFC 3339 and empty cells leave their field unset.
+func readCSVTransactions(body io.Reader, handle func(row int, req dto.CreateTransactionRequest, err error) error) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: file is empty", ErrInvalidImport)
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(transactionCSVColumns, name) {
+			return fmt.Errorf("%w: unknown column %q", ErrInvalidImport, name)
+		}
+		columns[name] = i
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err := handle(parseErr.StartLine, dto.CreateTransactionRequest{}, parseErr.Err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		req, err := parseCSVTransaction(record, columns)
+		if err := handle(line, req, err); err != nil {
+			return err
+		}
+	}
+}
+
+func parseCSVTransaction(record []string, columns map[string]int) (dto.CreateTransactionRequest, error) {
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	number := func(name string) (int, error) {
+		value := field(name)
+		if value == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("%s must be a whole number", name)
+		}
+		return n, nil
+	}
+
+	req := dto.CreateTransactionRequest{
+		ID:          field("id"),
+		OrderID:     field("order_id"),
+		MerchantID:  field("merchant_id"),
+		Currency:    field("currency"),
+		FeeCurrency: field("fee_currency"),
+		Status:      field("status"),
+	}
+
+	var err error
+	if req.Amount, err = number("amount"); err != nil {
+		return req, err
+	}
+	if req.Fee, err = number("fee"); err != nil {
+		return req, err
+	}
+	if req.ReversalAmount, err = number("reversal_amount"); err != nil {
+		return req, err
+	}
+	if value := field("paid_at"); value != "" {
+		if req.PaidAt, err = time.Parse(time.RFC3339, value); err != nil {
+			return req, fmt.Errorf("paid_at must be an RFC 3339 timestamp")
+		}
+	}
+	if value := field("reversed_at"); value != "" {
+		reversedAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return req, fmt.Errorf("reversed_at must be an RFC 3339 timestamp")
+		}
+		req.ReversedAt = &reversedAt
+	}
+
+	return req, nil
+}
+
+// newTransaction validates req and returns the transaction it records.
+// Currencies default to IDR and the fee currency to the currency. REFUNDED
+// and CHARGEBACK reverse the whole amount unless told otherwise.
+func newTransaction(req dto.CreateTransactionRequest) (*models.Transaction, error) {
+	t := &models.Transaction{
+		ID:             strings.TrimSpace(req.ID),
+		OrderID:        strings.TrimSpace(req.OrderID),
+		MerchantID:     strings.TrimSpace(req.MerchantID),
+		Amount:         req.Amount,
+		Fee:            req.Fee,
+		Currency:       strings.ToUpper(strings.TrimSpace(req.Currency)),
+		FeeCurrency:    strings.ToUpper(strings.TrimSpace(req.FeeCurrency)),
+		Status:         strings.ToUpper(strings.TrimSpace(req.Status)),
+		PaidAt:         req.PaidAt.UTC(),
+		ReversalAmount: req.ReversalAmount,
+	}
+	if req.ReversedAt != nil {
+		reversedAt := req.ReversedAt.UTC()
+		t.ReversedAt = &reversedAt
+	}
+	if t.Currency == "" {
+		t.Currency = models.DefaultCurrency
+	}
+	if t.FeeCurrency == "" {
+		t.FeeCurrency = t.Currency
+	}
+	if (t.Status == "REFUNDED" || t.Status == models.TransactionStatusChargeback) && t.ReversalAmount == 0 {
+		t.ReversalAmount = t.Amount
+	}
+
+	problems := []string{}
+	for field, value := range map[string]string{"id": t.ID, "order_id": t.OrderID, "merchant_id": t.MerchantID} {
+		if value == "" {
+			problems = append(problems, field+" is required")
+		}
+	}
+	if t.Amount <= 0 {
+		problems = append(problems, "amount must be positive")
+	}
+	if t.Fee < 0 || t.Fee > t.Amount {
+		problems = append(problems, "fee must be between 0 and amount")
+	}
+	if len(t.Currency) != 3 || strings.Trim(t.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		problems = append(problems, "currency must be a three letter code")
+	}
+	if t.FeeCurrency != t.Currency {
+		problems = append(problems, "fee_currency must equal currency")
+	}
+	if !slices.Contains(models.TransactionStatuses, t.Status) {
+		problems = append(problems, "status must be one of "+strings.Join(models.TransactionStatuses, ", "))
+	}
+	if req.PaidAt.IsZero() {
+		problems = append(problems, "paid_at is required")
+	}
+
+	switch {
+	case slices.Contains(models.ReversalStatuses, t.Status):
+		if t.Status == "PARTIALLY_REFUNDED" && (t.ReversalAmount <= 0 || t.ReversalAmount >= t.Amount) {
+			problems = append(problems, "reversal_amount of a PARTIALLY_REFUNDED transaction must be between 0 and amount")
+		}
+		if t.Status != "PARTIALLY_REFUNDED" && t.ReversalAmount != t.Amount {
+			problems = append(problems, "reversal_amount of a "+t.Status+" transaction must equal amount")
+		}
+		if t.ReversedAt == nil {
+			problems = append(problems, "reversed_at is required for "+t.Status)
+		} else if t.ReversedAt.Before(t.PaidAt) {
+			problems = append(problems, "reversed_at must not be before paid_at")
+		}
+	case t.ReversalAmount != 0 || t.ReversedAt != nil:
+		problems = append(problems, "reversal_amount and reversed_at are only allowed for "+strings.Join(models.ReversalStatuses, ", "))
+	}
+
+	if len(problems) > 0 {
+		// Map iteration order is random; keep messages stable.
+		slices.Sort(problems)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTransaction, strings.Join(problems, "; "))
+	}
+
+	return t, nil
+}
+
+func toTransactionResponse(t *models.Transaction) *dto.TransactionResponse {
+	return &dto.TransactionResponse{
+		ID:             t.ID,
+		OrderID:        t.OrderID,
+		MerchantID:     t.MerchantID,
+		Amount:         t.Amount,
+		Fee:            t.Fee,
+		Currency:       t.Currency,
+		FeeCurrency:    t.FeeCurrency,
+		Status:         t.Status,
+		PaidAt:         t.PaidAt,
+		ReversalAmount: t.ReversalAmount,
+		ReversedAt:     t.ReversedAt,
+		CreatedAt:      t.CreatedAt,
+		UpdatedAt:      t.UpdatedAt,
+	}
+}
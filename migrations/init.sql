@@ -61,4 +61,298 @@ CREATE TABLE IF NOT EXISTS jobs (
   to_date DATE NOT NULL,
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
\ No newline at end of file
+);
+
+-- Index untuk worker yang mengambil job QUEUED paling lama (FOR UPDATE SKIP LOCKED)
+CREATE INDEX IF NOT EXISTS idx_jobs_status_created_at ON jobs (status, created_at);
+
+-- Menambahkan lease worker pada jobs agar job RUNNING yang ditinggal worker mati bisa dipulihkan
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS worker_id TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMP;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+
+-- Index unik untuk job_id, karena setiap update yang dijaga lease mencari job berdasarkan job_id
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_job_id ON jobs (job_id);
+
+-- Menyimpan checkpoint (cursor paid_at/id dan agregat sementara) agar job bisa dilanjutkan
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS checkpoint JSONB;
+
+-- Menyimpan detail kegagalan job dan jadwal retry berikutnya (status FAILED / DEAD)
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS run_after TIMESTAMP;
+
+-- Menambahkan tipe job dan index untuk pencarian job (GET /jobs)
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS job_type TEXT NOT NULL DEFAULT 'SETTLEMENT';
+CREATE INDEX IF NOT EXISTS idx_jobs_created_at_id ON jobs (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_jobs_job_type_created_at ON jobs (job_type, created_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_from_date_to_date ON jobs (from_date, to_date);
+
+-- Mode agregasi settlement: STREAM (dijumlahkan di Go) atau DATABASE (GROUP BY di Postgres)
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS aggregation TEXT NOT NULL DEFAULT 'STREAM';
+
+-- Index untuk keyset pagination transaksi berdasarkan (paid_at, id)
+CREATE INDEX IF NOT EXISTS idx_transactions_paid_at_id ON transactions (paid_at, id);
+
+-- Menambahkan parent_job_id agar satu settlement job bisa dipecah menjadi beberapa partisi yang diproses paralel
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS parent_job_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_jobs_parent_job_id ON jobs (parent_job_id);
+
+-- Membuat tabel settlement_runs untuk mencatat setiap run settlement (dari job atau rollback)
+CREATE TABLE IF NOT EXISTS settlement_runs (
+  id TEXT PRIMARY KEY,
+  job_id TEXT,
+  kind TEXT NOT NULL,
+  source_run_id TEXT REFERENCES settlement_runs(id),
+  from_date DATE NOT NULL,
+  to_date DATE NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_settlement_runs_from_date_to_date ON settlement_runs (from_date, to_date);
+
+-- Membuat tabel settlement_run_rows untuk menyimpan hasil setiap run, termasuk versi yang sudah diganti
+CREATE TABLE IF NOT EXISTS settlement_run_rows (
+  run_id TEXT NOT NULL REFERENCES settlement_runs(id) ON DELETE CASCADE,
+  merchant_id TEXT NOT NULL,
+  date DATE NOT NULL,
+  gross_amount INTEGER NOT NULL,
+  fee_amount INTEGER NOT NULL,
+  net_amount INTEGER NOT NULL,
+  txn_count INTEGER NOT NULL,
+  PRIMARY KEY (run_id, merchant_id, date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_settlement_run_rows_merchant_id_date ON settlement_run_rows (merchant_id, date);
+
+-- Index untuk query settlement (GET /settlements) berdasarkan tanggal dan run
+CREATE INDEX IF NOT EXISTS idx_settlements_date_merchant_id ON settlements (date, merchant_id);
+CREATE INDEX IF NOT EXISTS idx_settlements_unique_run_id ON settlements (unique_run_id);
+
+-- Menambahkan filter transaksi (merchant, pengecualian merchant, status) pada job dan run settlement
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS filter JSONB;
+ALTER TABLE settlement_runs ADD COLUMN IF NOT EXISTS filter JSONB;
+
+-- Membuat tabel fee_plans untuk skema fee merchant (persentase + fixed, tier volume, batas minimum/maksimum)
+CREATE TABLE IF NOT EXISTS fee_plans (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  percentage_bps INTEGER NOT NULL DEFAULT 0,
+  fixed_fee INTEGER NOT NULL DEFAULT 0,
+  min_fee INTEGER,
+  max_fee INTEGER,
+  tiers JSONB NOT NULL DEFAULT '[]',
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Membuat tabel merchant_fee_plans untuk menghubungkan merchant dengan fee plan pada rentang tanggal berlaku
+CREATE TABLE IF NOT EXISTS merchant_fee_plans (
+  merchant_id TEXT NOT NULL,
+  plan_id TEXT NOT NULL REFERENCES fee_plans(id),
+  effective_from DATE NOT NULL,
+  effective_to DATE,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (merchant_id, effective_from)
+);
+
+-- Menambahkan fee hasil perhitungan fee plan dan penanda selisih dengan fee yang tercatat
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS plan_fee_amount INTEGER;
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS fee_mismatch BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE settlement_run_rows ADD COLUMN IF NOT EXISTS plan_fee_amount INTEGER;
+ALTER TABLE settlement_run_rows ADD COLUMN IF NOT EXISTS fee_mismatch BOOLEAN NOT NULL DEFAULT false;
+
+-- Mengubah paid_at menjadi timestamp with time zone; data lama yang berupa tanggal dianggap tengah malam UTC
+DO $$
+BEGIN
+  IF EXISTS (
+    SELECT 1 FROM information_schema.columns
+    WHERE table_name = 'transactions' AND column_name = 'paid_at' AND data_type = 'date'
+  ) THEN
+    ALTER TABLE transactions ALTER COLUMN paid_at TYPE TIMESTAMPTZ USING paid_at::timestamp AT TIME ZONE 'UTC';
+  END IF;
+END $$;
+
+-- Membuat tabel merchant_settlement_configs untuk time zone dan jam cut-off hari settlement per merchant
+CREATE TABLE IF NOT EXISTS merchant_settlement_configs (
+  merchant_id TEXT PRIMARY KEY,
+  time_zone TEXT NOT NULL,
+  cutoff_hour INTEGER NOT NULL CHECK (cutoff_hour BETWEEN 0 AND 23),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Menambahkan mata uang pada transaksi; fee harus dalam mata uang yang sama dengan amount
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'IDR';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee_currency TEXT NOT NULL DEFAULT 'IDR';
+
+-- NOT VALID agar data lama yang tidak konsisten tidak menggagalkan migrasi; settlement job menolak transaksi seperti itu
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'transactions_fee_currency_check') THEN
+    ALTER TABLE transactions ADD CONSTRAINT transactions_fee_currency_check CHECK (fee_currency = currency) NOT VALID;
+  END IF;
+END $$;
+
+-- Settlement dan baris run dikelompokkan per (merchant, tanggal, mata uang)
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'IDR';
+ALTER TABLE settlements DROP CONSTRAINT IF EXISTS settlements_merchant_id_date_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_settlements_merchant_id_date_currency ON settlements (merchant_id, date, currency);
+
+ALTER TABLE settlement_run_rows ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'IDR';
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM information_schema.key_column_usage
+    WHERE table_name = 'settlement_run_rows' AND constraint_name = 'settlement_run_rows_pkey' AND column_name = 'currency'
+  ) THEN
+    ALTER TABLE settlement_run_rows DROP CONSTRAINT IF EXISTS settlement_run_rows_pkey;
+    ALTER TABLE settlement_run_rows ADD PRIMARY KEY (run_id, merchant_id, date, currency);
+  END IF;
+END $$;
+
+-- Menambahkan nilai dan waktu refund/chargeback pada transaksi; REFUNDED dan CHARGEBACK membalik seluruh amount
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reversal_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reversed_at TIMESTAMPTZ;
+
+-- Transaksi lama yang sudah berstatus refund/chargeback dianggap dibalik saat terakhir diubah
+UPDATE transactions
+SET reversed_at = updated_at AT TIME ZONE 'UTC',
+    reversal_amount = CASE WHEN status IN ('REFUNDED', 'CHARGEBACK') THEN amount ELSE reversal_amount END
+WHERE status IN ('REFUNDED', 'PARTIALLY_REFUNDED', 'CHARGEBACK') AND reversed_at IS NULL;
+
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'transactions_reversal_amount_check') THEN
+    ALTER TABLE transactions ADD CONSTRAINT transactions_reversal_amount_check CHECK (reversal_amount BETWEEN 0 AND amount) NOT VALID;
+  END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_reversed_at_id ON transactions (reversed_at, id) WHERE reversed_at IS NOT NULL;
+
+-- Settlement mencatat refund, chargeback, defisit yang dibawa dari settlement sebelumnya, nilai yang dibayarkan dan defisit yang dibawa ke settlement berikutnya
+DO $$
+DECLARE
+  t TEXT;
+BEGIN
+  FOREACH t IN ARRAY ARRAY['settlements', 'settlement_run_rows'] LOOP
+    IF NOT EXISTS (
+      SELECT 1 FROM information_schema.columns WHERE table_name = t AND column_name = 'payable_amount'
+    ) THEN
+      EXECUTE format('ALTER TABLE %I ADD COLUMN refund_amount INTEGER NOT NULL DEFAULT 0', t);
+      EXECUTE format('ALTER TABLE %I ADD COLUMN chargeback_amount INTEGER NOT NULL DEFAULT 0', t);
+      EXECUTE format('ALTER TABLE %I ADD COLUMN reversal_count INTEGER NOT NULL DEFAULT 0', t);
+      EXECUTE format('ALTER TABLE %I ADD COLUMN carried_in INTEGER NOT NULL DEFAULT 0', t);
+      EXECUTE format('ALTER TABLE %I ADD COLUMN payable_amount INTEGER NOT NULL DEFAULT 0', t);
+      EXECUTE format('ALTER TABLE %I ADD COLUMN carry_forward INTEGER NOT NULL DEFAULT 0', t);
+      EXECUTE format('UPDATE %I SET payable_amount = GREATEST(net_amount, 0), carry_forward = LEAST(net_amount, 0)', t);
+    END IF;
+  END LOOP;
+END $$;
+
+-- Membuat tabel payouts untuk instruksi pembayaran settlement ke merchant per mata uang dan tanggal payout
+CREATE TABLE IF NOT EXISTS payouts (
+  id TEXT PRIMARY KEY,
+  merchant_id TEXT NOT NULL,
+  currency TEXT NOT NULL,
+  payout_date DATE NOT NULL,
+  amount INTEGER NOT NULL,
+  settlement_count INTEGER NOT NULL,
+  status TEXT NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'SENT', 'CONFIRMED', 'FAILED')),
+  reference TEXT,
+  failure_reason TEXT,
+  sent_at TIMESTAMP,
+  confirmed_at TIMESTAMP,
+  failed_at TIMESTAMP,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Satu payout aktif per merchant, mata uang dan tanggal payout; payout FAILED tidak dihitung
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payouts_merchant_id_currency_payout_date ON payouts (merchant_id, currency, payout_date) WHERE status <> 'FAILED';
+CREATE INDEX IF NOT EXISTS idx_payouts_payout_date_id ON payouts (payout_date, id);
+
+-- Membuat tabel payout_items untuk settlement yang dibayarkan oleh sebuah payout
+CREATE TABLE IF NOT EXISTS payout_items (
+  payout_id TEXT NOT NULL REFERENCES payouts(id) ON DELETE CASCADE,
+  merchant_id TEXT NOT NULL,
+  date DATE NOT NULL,
+  currency TEXT NOT NULL,
+  amount INTEGER NOT NULL,
+  PRIMARY KEY (payout_id, merchant_id, date, currency)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payout_items_merchant_id_date_currency ON payout_items (merchant_id, date, currency);
+
+-- Membuat tabel ledger_journals untuk jurnal double-entry; satu jurnal per jenis dan referensi agar posting ulang tidak tercatat dua kali
+CREATE TABLE IF NOT EXISTS ledger_journals (
+  id TEXT PRIMARY KEY,
+  kind TEXT NOT NULL CHECK (kind IN ('PAYMENT', 'FEE', 'REFUND', 'CHARGEBACK', 'SETTLEMENT', 'PAYOUT', 'PAYOUT_REVERSAL')),
+  source_id TEXT NOT NULL,
+  reference TEXT NOT NULL,
+  posted_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (kind, reference)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_journals_source_id ON ledger_journals (source_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_journals_posted_at_id ON ledger_journals (posted_at, id);
+
+-- Membuat tabel ledger_entries; amount positif adalah debit dan negatif adalah kredit, total per jurnal dan mata uang harus nol
+CREATE TABLE IF NOT EXISTS ledger_entries (
+  journal_id TEXT NOT NULL REFERENCES ledger_journals(id) ON DELETE CASCADE,
+  account_id TEXT NOT NULL,
+  currency TEXT NOT NULL,
+  amount INTEGER NOT NULL CHECK (amount <> 0),
+  PRIMARY KEY (journal_id, account_id, currency)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account_id_currency ON ledger_entries (account_id, currency);
+
+-- Membuat tabel bank_reconciliations untuk hasil rekonsiliasi rekening koran terhadap payout dan settlement
+CREATE TABLE IF NOT EXISTS bank_reconciliations (
+  id TEXT PRIMARY KEY,
+  format TEXT NOT NULL CHECK (format IN ('CSV', 'MT940')),
+  file_name TEXT NOT NULL,
+  statement_from DATE NOT NULL,
+  statement_to DATE NOT NULL,
+  amount_tolerance INTEGER NOT NULL,
+  date_tolerance_days INTEGER NOT NULL,
+  line_count INTEGER NOT NULL,
+  matched_count INTEGER NOT NULL,
+  partial_count INTEGER NOT NULL,
+  unmatched_count INTEGER NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Membuat tabel bank_reconciliation_items; item tanpa line_no adalah payout atau settlement yang tidak ada di rekening koran
+CREATE TABLE IF NOT EXISTS bank_reconciliation_items (
+  reconciliation_id TEXT NOT NULL REFERENCES bank_reconciliations(id) ON DELETE CASCADE,
+  position INTEGER NOT NULL,
+  status TEXT NOT NULL CHECK (status IN ('MATCHED', 'PARTIAL', 'UNMATCHED')),
+  line_no INTEGER,
+  value_date DATE,
+  amount INTEGER,
+  currency TEXT NOT NULL,
+  reference TEXT,
+  description TEXT,
+  match_kind TEXT CHECK (match_kind IN ('PAYOUT', 'SETTLEMENT')),
+  match_id TEXT,
+  merchant_id TEXT,
+  expected_amount INTEGER,
+  expected_date DATE,
+  difference INTEGER NOT NULL DEFAULT 0,
+  reason TEXT NOT NULL,
+  PRIMARY KEY (reconciliation_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bank_reconciliation_items_match_id ON bank_reconciliation_items (match_id);
+
+-- Menambahkan format laporan settlement yang ditulis job (CSV, JSON, NDJSON, XLSX, PARQUET)
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS report_format TEXT NOT NULL DEFAULT 'CSV';
+
+-- Menambahkan manifest laporan settlement (nama file, ukuran, SHA-256) pada job
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS manifest JSONB;
+
+-- Mengubah result_path job lama dari path absolut menjadi key artifact storage
+UPDATE jobs SET result_path = regexp_replace(result_path, '^/tmp/settlements/', '') WHERE result_path LIKE '/tmp/settlements/%';
@@ -37,7 +37,7 @@ BEGIN
       (10000 + floor(random() * 50000)),
       500,
       'PAID',
-      date '2025-01-01' + (floor(random() * 30)) * interval '1 day',
+      timestamptz '2025-01-01 00:00:00+00' + (floor(random() * 30 * 86400)) * interval '1 second',
       NOW(),
       NOW()
     );
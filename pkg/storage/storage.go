@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/banggibima/be-assignment/config"
+)
+
+// ErrNotFound means no object is stored under the key.
+var ErrNotFound = errors.New("object not found")
+
+// Storage keeps job artifacts under slash-separated keys such as
+// "<job_id>.csv". Put replaces the object stored under key.
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// Init returns the storage backend cfg selects.
+func Init(ctx context.Context, cfg config.Storage) (Storage, error) {
+	switch cfg.Backend {
+	case "local":
+		return NewLocal(cfg.LocalDir)
+	case "s3":
+		return NewS3(ctx, cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/banggibima/be-assignment/internal/services"
+)
+
+func TestSettlementAppliesMerchantFeePlan(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const (
+		date      = "2030-10-01"
+		matching  = "merchant-feeplan-matching"
+		tiered    = "merchant-feeplan-tiered"
+		unplanned = "merchant-feeplan-none"
+	)
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE date = $1::date`, date)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date = $1::date AND to_date = $1::date`, date)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM merchant_fee_plans WHERE merchant_id LIKE 'merchant-feeplan-%'`)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM fee_plans WHERE name LIKE 'test-feeplan-%'`)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	feePlanService := services.NewFeePlanService(repositories.NewDatabaseFeePlanRepository(pool))
+
+	// 1% + 5 per transaksi: 2 x 1000 -> 20 + 10 = 30, sama dengan fee yang tercatat
+	flat, err := feePlanService.CreatePlan(ctx, dto.CreateFeePlanRequest{Name: "test-feeplan-flat", PercentageBps: 100, FixedFee: 5})
+	if err != nil {
+		t.Fatalf("failed to create plan: %v", err)
+	}
+	// Volume 10000 mencapai tier 0.5% tanpa fixed fee -> 50, lalu dibatasi max_fee 40
+	maxFee := 40
+	tieredPlan, err := feePlanService.CreatePlan(ctx, dto.CreateFeePlanRequest{
+		Name:          "test-feeplan-tiered",
+		PercentageBps: 200,
+		MaxFee:        &maxFee,
+		Tiers:         []dto.FeePlanTierRequest{{MinVolume: 5000, PercentageBps: 50}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create plan: %v", err)
+	}
+
+	assign := func(merchantID, planID, from string, to *string) {
+		t.Helper()
+		if _, err := feePlanService.AssignPlan(ctx, merchantID, dto.AssignFeePlanRequest{PlanID: planID, EffectiveFrom: from, EffectiveTo: to}); err != nil {
+			t.Fatalf("failed to assign plan: %v", err)
+		}
+	}
+	assign(matching, flat.ID, "2030-09-01", nil)
+	// Plan yang sudah berakhir sebelum tanggal settlement tidak boleh dipakai
+	ended := "2030-09-30"
+	assign(tiered, flat.ID, "2030-09-01", &ended)
+	assign(tiered, tieredPlan.ID, "2030-10-01", nil)
+
+	seedTransactions(t, pool, "feeplan-matching", matching, date, 2, 1000, 15)
+	seedTransactions(t, pool, "feeplan-tiered", tiered, date, 2, 5000, 10)
+	seedTransactions(t, pool, "feeplan-none", unplanned, date, 1, 1000, 10)
+
+	runSettlementJob(t, pool, startJobService(t, pool), dto.CreateSettlementJobRequest{From: date, To: date})
+
+	rows := settlementRows(t, pool, date, date)
+
+	got := rows[matching+"|"+date+"|IDR"]
+	if got.PlanFeeAmount == nil || *got.PlanFeeAmount != 30 || got.FeeMismatch {
+		t.Fatalf("matching settlement = %+v, want plan fee 30 without mismatch", got)
+	}
+
+	got = rows[tiered+"|"+date+"|IDR"]
+	if got.PlanFeeAmount == nil || *got.PlanFeeAmount != 40 || !got.FeeMismatch {
+		t.Fatalf("tiered settlement = %+v, want plan fee 40 with mismatch", got)
+	}
+	if got.FeeAmount != 20 || got.NetAmount != 9980 {
+		t.Fatalf("tiered settlement = %+v, want the recorded fee and net kept", got)
+	}
+
+	got = rows[unplanned+"|"+date+"|IDR"]
+	if got.PlanFeeAmount != nil || got.FeeMismatch {
+		t.Fatalf("unplanned settlement = %+v, want no plan fee", got)
+	}
+}
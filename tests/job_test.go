@@ -0,0 +1,630 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/banggibima/be-assignment/config"
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/handlers"
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/banggibima/be-assignment/internal/services"
+	"github.com/banggibima/be-assignment/pkg/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestCompletionRequiresLease(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+	jobRepo := repositories.NewDatabaseJobRepository(pool)
+
+	jobID := seedRunningJob(t, pool, "lease-owner", 1)
+
+	// worker lain yang sudah kehilangan lease tidak boleh menyelesaikan job
+	owned, err := jobRepo.MarkDone(ctx, nil, jobID, "lease-stale", "reports/stale.csv", nil)
+	if err != nil {
+		t.Fatalf("failed to mark done: %v", err)
+	}
+	if owned {
+		t.Fatalf("expected MarkDone by a worker without the lease to be rejected")
+	}
+	job, err := jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		t.Fatalf("failed to get job: %v", err)
+	}
+	if job.Status != "RUNNING" || job.ResultPath != nil {
+		t.Fatalf("expected job to stay RUNNING without a report, got %s %v", job.Status, job.ResultPath)
+	}
+
+	owned, err = jobRepo.MarkDone(ctx, nil, jobID, "lease-owner", "reports/owner.csv", nil)
+	if err != nil {
+		t.Fatalf("failed to mark done: %v", err)
+	}
+	if !owned {
+		t.Fatalf("expected MarkDone by the lease owner to succeed")
+	}
+
+	// pembatalan yang terlambat tidak boleh mengubah job yang sudah DONE
+	if err := jobRepo.MarkCancelled(ctx, nil, jobID, "lease-owner"); err != nil {
+		t.Fatalf("failed to mark cancelled: %v", err)
+	}
+	job, err = jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		t.Fatalf("failed to get job: %v", err)
+	}
+	if job.Status != "DONE" {
+		t.Fatalf("expected job to stay DONE, got %s", job.Status)
+	}
+}
+
+// waitForJob polls jobID until it reaches one of statuses and returns it.
+func waitForJob(t *testing.T, pool *pgxpool.Pool, jobID string, statuses ...string) *models.Job {
+	t.Helper()
+	jobRepo := repositories.NewDatabaseJobRepository(pool)
+
+	deadline := time.Now().Add(time.Minute)
+	for time.Now().Before(deadline) {
+		job, err := jobRepo.GetByID(context.Background(), jobID)
+		if err != nil {
+			t.Fatalf("failed to get job %s: %v", jobID, err)
+		}
+		if slices.Contains(statuses, job.Status) {
+			return job
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatalf("job %s did not reach %v in time", jobID, statuses)
+	return nil
+}
+
+// deleteJobsAfterTest removes jobIDs once the test ends, so jobs it leaves
+// behind are never claimed by the workers of later tests.
+func deleteJobsAfterTest(t *testing.T, pool *pgxpool.Pool, jobIDs ...string) {
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM jobs WHERE job_id = ANY($1)`, jobIDs)
+	})
+}
+
+func TestClaimRunsEachJobOnceAcrossServices(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	cfg := config.Job{Workers: 3, PollInterval: 50 * time.Millisecond}
+	first := newJobService(pool, cfg, settlementArtifacts(t), nil)
+	second := newJobService(pool, cfg, settlementArtifacts(t), nil)
+
+	// Job diantrekan dulu lalu diperebutkan oleh worker kedua service sekaligus
+	jobIDs := []string{}
+	for range 8 {
+		res, err := first.CreateJob(ctx, dto.CreateSettlementJobRequest{From: "2041-01-01", To: "2041-01-01", MerchantIDs: []string{"merchant-claim-none"}})
+		if err != nil {
+			t.Fatalf("failed to create job: %v", err)
+		}
+		jobIDs = append(jobIDs, res.JobID)
+	}
+	deleteJobsAfterTest(t, pool, jobIDs...)
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE job_id = ANY($1)`, jobIDs)
+	})
+
+	startWorkers(t, first)
+	startWorkers(t, second)
+
+	for _, jobID := range jobIDs {
+		job := waitForJob(t, pool, jobID, "DONE", "FAILED", "DEAD")
+		if job.Status != "DONE" || job.Attempts != 1 {
+			t.Fatalf("job %s = %s after %d attempts, want DONE after one claim", jobID, job.Status, job.Attempts)
+		}
+
+		var runs int
+		if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM settlement_runs WHERE job_id = $1`, jobID).Scan(&runs); err != nil {
+			t.Fatalf("failed to count runs: %v", err)
+		}
+		if runs != 1 {
+			t.Fatalf("job %s saved %d settlement runs, want 1", jobID, runs)
+		}
+	}
+}
+
+// abandonJob makes jobID look like it was claimed by workerID, which then
+// died an hour ago after saving checkpoint.
+func abandonJob(t *testing.T, pool *pgxpool.Pool, jobID, workerID string, checkpoint *models.JobCheckpoint) {
+	t.Helper()
+
+	_, err := pool.Exec(context.Background(), `
+		UPDATE jobs SET status = 'RUNNING', worker_id = $2, heartbeat_at = NOW() - INTERVAL '1 hour', attempts = 1, checkpoint = $3
+		WHERE job_id = $1
+	`, jobID, workerID, checkpoint)
+	if err != nil {
+		t.Fatalf("failed to abandon job: %v", err)
+	}
+}
+
+func TestReaperRetriesExpiredLease(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	jobService := newJobService(pool, config.Job{
+		Workers:        1,
+		PollInterval:   50 * time.Millisecond,
+		LeaseTTL:       5 * time.Second,
+		ReapInterval:   100 * time.Millisecond,
+		RetryBaseDelay: 100 * time.Millisecond,
+	}, settlementArtifacts(t), nil)
+
+	res, err := jobService.CreateJob(ctx, dto.CreateSettlementJobRequest{From: "2041-01-02", To: "2041-01-02", MerchantIDs: []string{"merchant-lease-none"}})
+	if err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+	deleteJobsAfterTest(t, pool, res.JobID)
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE job_id = $1`, res.JobID)
+	})
+
+	// Worker yang mati tidak memperbarui heartbeat, sedangkan worker yang hidup masih dalam lease
+	abandonJob(t, pool, res.JobID, "dead-worker", nil)
+	liveJobID := seedRunningJob(t, pool, "live-worker", 1)
+
+	startWorkers(t, jobService)
+
+	job := waitForJob(t, pool, res.JobID, "DONE", "DEAD")
+	if job.Status != "DONE" || job.Attempts != 2 {
+		t.Fatalf("job = %s after %d attempts, want DONE on the second attempt", job.Status, job.Attempts)
+	}
+	if job.LastError == nil || *job.LastError != "lease expired on dead-worker" {
+		t.Fatalf("last error = %v, want the expired lease of dead-worker", job.LastError)
+	}
+
+	live, err := repositories.NewDatabaseJobRepository(pool).GetByID(ctx, liveJobID)
+	if err != nil {
+		t.Fatalf("failed to get job: %v", err)
+	}
+	if live.Status != "RUNNING" || live.WorkerID == nil || *live.WorkerID != "live-worker" || live.Attempts != 0 {
+		t.Fatalf("live job = %s on %v after %d attempts, want it left to live-worker", live.Status, live.WorkerID, live.Attempts)
+	}
+}
+
+// seedJob inserts a settlement job for req with status, so running workers
+// only pick it up once the test moves it on. It is removed when the test ends.
+func seedJob(t *testing.T, pool *pgxpool.Pool, req dto.CreateSettlementJobRequest, status string) string {
+	t.Helper()
+
+	aggregation := req.Aggregation
+	if aggregation == "" {
+		aggregation = "STREAM"
+	}
+	job := &models.Job{
+		JobID:        uuid.New().String(),
+		JobType:      "SETTLEMENT",
+		Aggregation:  aggregation,
+		Status:       status,
+		From:         req.From,
+		To:           req.To,
+		Filter:       &models.TransactionFilter{MerchantIDs: req.MerchantIDs, Statuses: models.SettlementStatuses},
+		ReportFormat: models.ReportFormatCSV,
+	}
+	if err := repositories.NewDatabaseJobRepository(pool).Create(context.Background(), nil, job); err != nil {
+		t.Fatalf("failed to seed job: %v", err)
+	}
+	deleteJobsAfterTest(t, pool, job.JobID)
+
+	return job.JobID
+}
+
+// newJobRouter serves the job endpoints of jobService. events may be nil
+// when the test does not stream job events.
+func newJobRouter(jobService *services.JobService, events *services.JobEventService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handlers.NewJobHandler(jobService, events).Register(router)
+
+	return router
+}
+
+func TestJobResumesFromCheckpoint(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const (
+		merchantID = "merchant-resume"
+		day1       = "2041-01-03"
+		day2       = "2041-01-04"
+	)
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE merchant_id = $1`, merchantID)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date = $1::date AND to_date = $2::date`, day1, day2)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	seedTransactions(t, pool, "resume-1", merchantID, day1, 3, 1000, 10)
+	seedTransactions(t, pool, "resume-2", merchantID, day2, 3, 1000, 10)
+
+	jobService := newJobService(pool, config.Job{
+		Workers:        1,
+		PollInterval:   50 * time.Millisecond,
+		ReapInterval:   100 * time.Millisecond,
+		RetryBaseDelay: 100 * time.Millisecond,
+	}, settlementArtifacts(t), nil)
+	router := newJobRouter(jobService, nil)
+	startWorkers(t, jobService)
+
+	// Checkpoint buatan untuk hari pertama: jika hari pertama dihitung ulang,
+	// jumlah transaksinya kembali menjadi 3
+	checkpoint := &models.JobCheckpoint{
+		CursorPaidAt: day1,
+		Processed:    7,
+		Settlements: map[string]*models.SettlementAggregate{
+			merchantID + "|" + day1 + "|IDR": {GrossAmount: 7000, FeeAmount: 70, NetAmount: 6930, TxnCount: 7},
+		},
+	}
+	req := dto.CreateSettlementJobRequest{From: day1, To: day2, Aggregation: "DATABASE", MerchantIDs: []string{merchantID}}
+
+	assertResumed := func(t *testing.T, jobID string) {
+		t.Helper()
+
+		job := waitForJob(t, pool, jobID, "DONE", "DEAD")
+		if job.Status != "DONE" || job.Checkpoint == nil {
+			t.Fatalf("job = %s with checkpoint %+v, want DONE", job.Status, job.Checkpoint)
+		}
+		first := job.Checkpoint.Settlements[merchantID+"|"+day1+"|IDR"]
+		second := job.Checkpoint.Settlements[merchantID+"|"+day2+"|IDR"]
+		if first == nil || first.TxnCount != 7 || second == nil || second.TxnCount != 3 || job.Checkpoint.Processed != 10 {
+			t.Fatalf("checkpoint = %+v, want day 1 kept from the checkpoint and day 2 aggregated", job.Checkpoint)
+		}
+		if got := settlementRows(t, pool, day1, day1)[merchantID+"|"+day1+"|IDR"]; got.TxnCount != 7 {
+			t.Fatalf("settlement of day 1 = %+v, want the checkpointed 7 transactions", got)
+		}
+	}
+
+	t.Run("after the worker died", func(t *testing.T) {
+		jobID := seedJob(t, pool, req, "CANCELLED")
+		abandonJob(t, pool, jobID, "killed-worker", checkpoint)
+		assertResumed(t, jobID)
+	})
+
+	t.Run("after it was cancelled", func(t *testing.T) {
+		jobID := seedJob(t, pool, req, "CANCELLED")
+		if _, err := pool.Exec(ctx, `UPDATE jobs SET checkpoint = $2 WHERE job_id = $1`, jobID, checkpoint); err != nil {
+			t.Fatalf("failed to save checkpoint: %v", err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/jobs/"+jobID+"/resume", nil))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("resume = %d %s, want 202", w.Code, w.Body.String())
+		}
+		assertResumed(t, jobID)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/jobs/"+jobID+"/resume", nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("resume of a DONE job = %d %s, want 404", w.Code, w.Body.String())
+		}
+	})
+}
+
+// failingArtifacts is an ArtifactStorage that cannot store anything, so
+// every settlement job fails while writing its report.
+type failingArtifacts struct{}
+
+func (failingArtifacts) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	return errors.New("storage unavailable")
+}
+
+func (failingArtifacts) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return nil, storage.ErrNotFound
+}
+
+func TestFailedJobBacksOffAndMovesToDead(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const baseDelay = 500 * time.Millisecond
+
+	req := dto.CreateSettlementJobRequest{From: "2041-01-05", To: "2041-01-05", MerchantIDs: []string{"merchant-retry-none"}}
+	jobID := seedJob(t, pool, req, "QUEUED")
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE job_id = $1`, jobID)
+	})
+
+	failing := newJobService(pool, config.Job{
+		Workers:        1,
+		PollInterval:   20 * time.Millisecond,
+		MaxAttempts:    3,
+		RetryBaseDelay: baseDelay,
+		RetryMaxDelay:  10 * time.Second,
+	}, failingArtifacts{}, nil)
+	failingCtx, stopFailing := context.WithCancel(context.Background())
+	t.Cleanup(stopFailing)
+	failing.StartWorkerPool(failingCtx)
+
+	// Setiap percobaan yang gagal dijadwalkan ulang dengan jeda base * 2^(attempts-1)
+	jobRepo := repositories.NewDatabaseJobRepository(pool)
+	retried := map[int]bool{}
+	var job *models.Job
+	deadline := time.Now().Add(time.Minute)
+	for {
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s did not move to DEAD in time", jobID)
+		}
+
+		var err error
+		job, err = jobRepo.GetByID(ctx, jobID)
+		if err != nil {
+			t.Fatalf("failed to get job: %v", err)
+		}
+		if job.Status == "DEAD" {
+			break
+		}
+		if job.Status == "FAILED" && !retried[job.Attempts] {
+			want := baseDelay << (job.Attempts - 1)
+			if job.RunAfter == nil || job.RunAfter.Sub(job.UpdatedAt) != want {
+				t.Fatalf("attempt %d retries at %v after %v, want a delay of %v", job.Attempts, job.RunAfter, job.UpdatedAt, want)
+			}
+			retried[job.Attempts] = true
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if !retried[1] || !retried[2] || job.Attempts != 3 {
+		t.Fatalf("job moved to DEAD after %d attempts with retries %v, want 2 retries and 3 attempts", job.Attempts, retried)
+	}
+	if job.LastError == nil || !strings.Contains(*job.LastError, "storage unavailable") {
+		t.Fatalf("last error = %v, want the storage failure", job.LastError)
+	}
+
+	dead, err := failing.ListDeadLetter(ctx, 100)
+	if err != nil {
+		t.Fatalf("failed to list dead letter: %v", err)
+	}
+	if !slices.ContainsFunc(dead, func(j dto.JobStatusResponse) bool { return j.JobID == jobID }) {
+		t.Fatalf("dead letter = %+v, want job %s", dead, jobID)
+	}
+
+	// Setelah storage pulih, job DEAD dijalankan ulang lewat endpoint resume
+	stopFailing()
+	jobService := newJobService(pool, config.Job{Workers: 1, PollInterval: 50 * time.Millisecond}, settlementArtifacts(t), nil)
+	startWorkers(t, jobService)
+
+	w := httptest.NewRecorder()
+	newJobRouter(jobService, nil).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/jobs/"+jobID+"/resume", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("resume = %d %s, want 202", w.Code, w.Body.String())
+	}
+
+	job = waitForJob(t, pool, jobID, "DONE", "DEAD")
+	if job.Status != "DONE" || job.Attempts != 1 {
+		t.Fatalf("resumed job = %s after %d attempts, want DONE on its first attempt", job.Status, job.Attempts)
+	}
+}
+
+func TestListJobsPaginatesAndValidatesFilters(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	// Status DONE dan CANCELLED tidak pernah diklaim worker, dan job_type khusus memisahkannya dari job lain
+	const jobType = "TEST_LIST"
+	jobRepo := repositories.NewDatabaseJobRepository(pool)
+	statuses := []string{"DONE", "CANCELLED", "DONE", "CANCELLED", "DONE"}
+	ids := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		job := &models.Job{
+			JobID:        uuid.New().String(),
+			JobType:      jobType,
+			Aggregation:  "STREAM",
+			Status:       status,
+			From:         "2041-02-01",
+			To:           "2041-02-01",
+			Filter:       &models.TransactionFilter{Statuses: models.SettlementStatuses},
+			ReportFormat: models.ReportFormatCSV,
+		}
+		if err := jobRepo.Create(ctx, nil, job); err != nil {
+			t.Fatalf("failed to seed job: %v", err)
+		}
+		deleteJobsAfterTest(t, pool, job.JobID)
+		ids = append(ids, job.JobID)
+	}
+
+	router := newJobRouter(newJobService(pool, config.Job{}, settlementArtifacts(t), nil), nil)
+	list := func(query string) (int, dto.ListJobsResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs?"+query, nil))
+
+		var res dto.ListJobsResponse
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatalf("failed to decode %s: %v", w.Body.String(), err)
+			}
+		}
+		return w.Code, res
+	}
+	jobIDs := func(res dto.ListJobsResponse) []string {
+		got := []string{}
+		for _, job := range res.Data {
+			got = append(got, job.JobID)
+		}
+		return got
+	}
+
+	// Cursor next_cursor mengikuti halaman berikutnya sampai habis
+	var got []string
+	query := "job_type=" + jobType + "&sort=asc&limit=2"
+	for page := 1; ; page++ {
+		code, res := list(query)
+		if code != http.StatusOK {
+			t.Fatalf("page %d = %d, want 200", page, code)
+		}
+		if len(res.Data) > 2 {
+			t.Fatalf("page %d has %d jobs, want at most 2", page, len(res.Data))
+		}
+		got = append(got, jobIDs(res)...)
+		if res.NextCursor == nil {
+			break
+		}
+		query = "job_type=" + jobType + "&sort=asc&limit=2&cursor=" + url.QueryEscape(*res.NextCursor)
+	}
+	if !slices.Equal(got, ids) {
+		t.Fatalf("paged jobs = %v, want %v", got, ids)
+	}
+
+	code, res := list("job_type=" + jobType + "&limit=2")
+	if code != http.StatusOK || !slices.Equal(jobIDs(res), []string{ids[4], ids[3]}) || res.NextCursor == nil {
+		t.Fatalf("newest jobs = %d %v, want %v with a next cursor", code, jobIDs(res), []string{ids[4], ids[3]})
+	}
+
+	code, res = list("job_type=" + jobType + "&sort=asc&status=CANCELLED")
+	if code != http.StatusOK || !slices.Equal(jobIDs(res), []string{ids[1], ids[3]}) {
+		t.Fatalf("cancelled jobs = %d %v, want %v", code, jobIDs(res), []string{ids[1], ids[3]})
+	}
+
+	// created_to berupa tanggal saja mencakup seluruh hari itu
+	var createdOn string
+	if err := pool.QueryRow(ctx, `SELECT created_at::date::text FROM jobs WHERE job_id = $1`, ids[0]).Scan(&createdOn); err != nil {
+		t.Fatalf("failed to get created date: %v", err)
+	}
+	code, res = list("job_type=" + jobType + "&sort=asc&created_from=" + createdOn + "&created_to=" + createdOn)
+	if code != http.StatusOK || !slices.Equal(jobIDs(res), ids) {
+		t.Fatalf("jobs created on %s = %d %v, want %v", createdOn, code, jobIDs(res), ids)
+	}
+
+	code, res = list("job_type=" + jobType + "&from=2041-03-01")
+	if code != http.StatusOK || len(res.Data) != 0 {
+		t.Fatalf("jobs from a later date = %d %v, want none", code, jobIDs(res))
+	}
+
+	for _, query := range []string{
+		"sort=sideways",
+		"from=2041-02-30",
+		"to=01-02-2041",
+		"created_from=yesterday",
+		"created_to=2041-02-01T25:00:00Z",
+		"cursor=not-a-cursor",
+	} {
+		if code, _ := list(query); code != http.StatusBadRequest {
+			t.Fatalf("GET /jobs?%s = %d, want 400", query, code)
+		}
+	}
+}
+
+// readSSEvent reads the next server-sent event from stream and returns its
+// name and data. ok is false once the stream ends.
+func readSSEvent(stream *bufio.Scanner) (name, data string, ok bool) {
+	for stream.Scan() {
+		line := stream.Text()
+		switch {
+		case line == "":
+			if name != "" || data != "" {
+				return name, data, true
+			}
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			data = strings.TrimPrefix(line, "data:")
+		}
+	}
+
+	return name, data, false
+}
+
+func TestJobEventStreamEndsWithDownload(t *testing.T) {
+	pool := mustConnectDB(t)
+
+	eventsCtx, stopEvents := context.WithCancel(context.Background())
+	t.Cleanup(stopEvents)
+	events := services.NewJobEventService(repositories.NewDatabaseJobEventRepository(pool))
+	go events.Run(eventsCtx)
+
+	// Event yang dipublish sebelum LISTEN aktif hilang, jadi tunggu sampai event percobaan diterima
+	probeID := uuid.New().String()
+	probe, unsubscribe := events.Subscribe(probeID)
+	deadline := time.Now().Add(10 * time.Second)
+	for listening := false; !listening; {
+		if time.Now().After(deadline) {
+			t.Fatal("job events are not being delivered")
+		}
+		events.Publish(context.Background(), models.JobEvent{JobID: probeID, Type: "status"})
+		select {
+		case <-probe:
+			listening = true
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	unsubscribe()
+
+	jobID := seedJob(t, pool, dto.CreateSettlementJobRequest{From: "2041-04-01", To: "2041-04-01", MerchantIDs: []string{"merchant-sse-none"}}, "QUEUED")
+
+	jobService := newJobService(pool, config.Job{Workers: 1, PollInterval: 50 * time.Millisecond}, settlementArtifacts(t), events)
+	server := httptest.NewServer(newJobRouter(jobService, events))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Get(server.URL + "/jobs/" + jobID + "/events")
+	if err != nil {
+		t.Fatalf("failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("event stream = %d %s, want 200 text/event-stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	stream := bufio.NewScanner(resp.Body)
+
+	// Snapshot status dikirim setelah berlangganan, jadi worker baru dijalankan setelah snapshot diterima
+	name, data, ok := readSSEvent(stream)
+	var event models.JobEvent
+	if !ok || name != "status" || json.Unmarshal([]byte(data), &event) != nil || event.Status != "QUEUED" {
+		t.Fatalf("first event = %q %s, want the QUEUED status snapshot", name, data)
+	}
+	startWorkers(t, jobService)
+
+	for {
+		name, data, ok = readSSEvent(stream)
+		if !ok {
+			t.Fatalf("event stream ended before the done event: %v", stream.Err())
+		}
+		if name == "done" {
+			break
+		}
+	}
+
+	event = models.JobEvent{}
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatalf("failed to decode done event %s: %v", data, err)
+	}
+	wantURL := "/downloads/" + jobID
+	if event.JobID != jobID || event.Status != "DONE" || event.Progress != 100 || event.DownloadURL == nil || *event.DownloadURL != wantURL {
+		t.Fatalf("done event = %s, want job %s DONE at 100%% with download_url %s", data, jobID, wantURL)
+	}
+
+	// Stream ditutup setelah event done, dan laporannya bisa diunduh
+	if name, _, ok := readSSEvent(stream); ok {
+		t.Fatalf("got %q event after done, want the stream to end", name)
+	}
+
+	download, err := client.Get(server.URL + *event.DownloadURL)
+	if err != nil {
+		t.Fatalf("failed to download report: %v", err)
+	}
+	defer download.Body.Close()
+	if download.StatusCode != http.StatusOK {
+		t.Fatalf("download = %d, want 200", download.StatusCode)
+	}
+}
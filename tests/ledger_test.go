@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/banggibima/be-assignment/config"
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/banggibima/be-assignment/internal/services"
+)
+
+func TestLedgerFollowsPaymentsSettlementsAndPayouts(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const merchantID = "merchant-ledger"
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM ledger_journals WHERE id IN (SELECT journal_id FROM ledger_entries WHERE account_id LIKE 'merchant:merchant-ledger:%')`)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM payouts WHERE merchant_id = $1`, merchantID)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE merchant_id = $1`, merchantID)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date = '2031-05-01' AND to_date = '2031-05-02'`)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	ledgerService := services.NewLedgerService(repositories.NewDatabaseLedgerRepository(pool))
+
+	// Transaksi yang dicatat langsung di database diposting oleh job settlement
+	seedTransactions(t, pool, "ledger-a", merchantID, "2031-05-01", 2, 1000, 10)
+	seedTransactions(t, pool, "ledger-b", merchantID, "2031-05-02", 1, 2000, 20)
+
+	balances := func() map[string]int {
+		t.Helper()
+		res, err := ledgerService.GetBalances(ctx, dto.LedgerBalancesRequest{MerchantID: merchantID, Currency: "IDR"})
+		if err != nil {
+			t.Fatalf("failed to get balances: %v", err)
+		}
+		got := map[string]int{}
+		for _, b := range res {
+			got[b.AccountID] = b.Balance
+		}
+		return got
+	}
+
+	jobService := startJobService(t, pool)
+	req := dto.CreateSettlementJobRequest{From: "2031-05-01", To: "2031-05-02", MerchantIDs: []string{merchantID}}
+
+	runSettlementJob(t, pool, jobService, req)
+	if got := balances(); got["merchant:merchant-ledger:pending"] != 0 || got["merchant:merchant-ledger:payable"] != 3960 {
+		t.Fatalf("balances = %+v, want pending 0 and payable 3960", got)
+	}
+
+	// Refund yang dicatat setelah settlement diposting oleh rerun, dan rerun berikutnya tidak memposting ulang
+	_, err := pool.Exec(ctx, `
+		UPDATE transactions SET status = 'PARTIALLY_REFUNDED', reversal_amount = 500, reversed_at = '2031-05-02T15:00:00Z' WHERE id = 'txn-ledger-b-1'
+	`)
+	if err != nil {
+		t.Fatalf("failed to refund transaction: %v", err)
+	}
+	for range 2 {
+		runSettlementJob(t, pool, jobService, req)
+
+		got := balances()
+		if got["merchant:merchant-ledger:pending"] != 0 || got["merchant:merchant-ledger:payable"] != 3460 {
+			t.Fatalf("balances = %+v, want pending 0 and payable 3460", got)
+		}
+	}
+
+	journals, err := ledgerService.ListJournals(ctx, dto.ListLedgerJournalsRequest{SourceID: "txn-ledger-b-1"})
+	if err != nil {
+		t.Fatalf("failed to list journals: %v", err)
+	}
+	kinds := map[string]bool{}
+	for _, journal := range journals.Data {
+		kinds[journal.Kind] = true
+	}
+	if len(journals.Data) != 3 || !kinds["PAYMENT"] || !kinds["FEE"] || !kinds["REFUND"] {
+		t.Fatalf("journals of txn-ledger-b-1 = %+v, want one PAYMENT, FEE and REFUND", journals.Data)
+	}
+
+	payoutService := services.NewPayoutService(pool, repositories.NewDatabasePayoutRepository(pool), ledgerService, config.Payout{})
+	payouts, err := payoutService.GeneratePayouts(ctx, dto.GeneratePayoutsRequest{PayoutDate: "2031-05-03", MerchantIDs: []string{merchantID}})
+	if err != nil {
+		t.Fatalf("failed to generate payouts: %v", err)
+	}
+	if len(payouts) != 1 || payouts[0].Amount != 3460 {
+		t.Fatalf("payouts = %+v, want one payout of 3460", payouts)
+	}
+	if _, err := payoutService.UpdatePayoutStatus(ctx, payouts[0].ID, dto.UpdatePayoutStatusRequest{Status: "SENT"}); err != nil {
+		t.Fatalf("failed to send payout: %v", err)
+	}
+
+	if got := balances(); got["merchant:merchant-ledger:payable"] != 0 {
+		t.Fatalf("balances = %+v, want payable 0 after the payout is sent", got)
+	}
+
+	invariants, err := ledgerService.CheckInvariants(ctx)
+	if err != nil {
+		t.Fatalf("failed to check invariants: %v", err)
+	}
+	if !invariants.Balanced {
+		t.Fatalf("unbalanced journals: %+v", invariants.Imbalances)
+	}
+}
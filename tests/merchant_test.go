@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/banggibima/be-assignment/internal/services"
+)
+
+func TestSettlementDayFollowsMerchantCutoff(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const (
+		date       = "2030-11-02"
+		jakarta    = "merchant-cutoff-jakarta"
+		utcDefault = "merchant-cutoff-utc"
+	)
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE date BETWEEN '2030-11-01' AND '2030-11-03'`)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date = $1::date AND to_date = $1::date`, date)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM merchant_settlement_configs WHERE merchant_id = $1`, jakarta)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	merchantService := services.NewMerchantService(repositories.NewDatabaseMerchantSettlementConfigRepository(pool), utcSettlementDay)
+	cutoff := 17
+	if _, err := merchantService.UpdateSettlementConfig(ctx, jakarta, dto.UpdateMerchantSettlementConfigRequest{TimeZone: "Asia/Jakarta", CutoffHour: &cutoff}); err != nil {
+		t.Fatalf("failed to update settlement config: %v", err)
+	}
+
+	at := func(value string) time.Time {
+		t.Helper()
+		paidAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("invalid time %q: %v", value, err)
+		}
+		return paidAt
+	}
+
+	// Asia/Jakarta UTC+7 dengan cut-off 17:00, hari settlement 2 November dimulai 1 November 17:00 WIB
+	seedTransactionsAt(t, pool, "cutoff-jakarta", jakarta, at("2030-11-01T09:30:00Z"), 1, 1000, 10) // 16:30 WIB, hari 1 November
+	seedTransactionsAt(t, pool, "cutoff-jakarta", jakarta, at("2030-11-01T10:00:00Z"), 1, 2000, 20) // 17:00 WIB, hari 2 November
+	seedTransactionsAt(t, pool, "cutoff-jakarta", jakarta, at("2030-11-01T18:00:00Z"), 1, 3000, 30) // 01:00 WIB, hari 2 November
+	seedTransactionsAt(t, pool, "cutoff-jakarta", jakarta, at("2030-11-02T10:00:00Z"), 1, 4000, 40) // 17:00 WIB, hari 3 November
+
+	// Merchant tanpa config memakai hari kalender UTC
+	seedTransactionsAt(t, pool, "cutoff-utc", utcDefault, at("2030-11-01T23:30:00Z"), 1, 1000, 10)
+	seedTransactionsAt(t, pool, "cutoff-utc", utcDefault, at("2030-11-02T00:00:00Z"), 1, 2000, 20)
+	seedTransactionsAt(t, pool, "cutoff-utc", utcDefault, at("2030-11-02T23:59:59Z"), 1, 3000, 30)
+
+	jobService := startJobService(t, pool)
+	for _, aggregation := range []string{"STREAM", "DATABASE"} {
+		t.Run(aggregation, func(t *testing.T) {
+			runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{From: date, To: date, Aggregation: aggregation})
+
+			rows := settlementRows(t, pool, "2030-11-01", "2030-11-03")
+			if len(rows) != 2 {
+				t.Fatalf("expected settlements only on %s, got %+v", date, rows)
+			}
+
+			got := rows[jakarta+"|"+date+"|IDR"]
+			if got.TxnCount != 2 || got.GrossAmount != 5000 || got.FeeAmount != 50 {
+				t.Fatalf("jakarta settlement = %+v, want the 2 payments between the cut-offs", got)
+			}
+
+			got = rows[utcDefault+"|"+date+"|IDR"]
+			if got.TxnCount != 2 || got.GrossAmount != 5000 {
+				t.Fatalf("utc settlement = %+v, want the 2 payments of the UTC day", got)
+			}
+		})
+	}
+}
@@ -15,7 +15,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func mustConnectDB(t *testing.T) *pgxpool.Pool {
+func mustConnectDB(t testing.TB) *pgxpool.Pool {
 	t.Helper()
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
@@ -37,6 +37,9 @@ func mustConnectDB(t *testing.T) *pgxpool.Pool {
 	if err != nil {
 		t.Fatalf("cannot connect to database: %v", err)
 	}
+
+	// Ditutup setelah cleanup lain yang masih memakai pool
+	t.Cleanup(pool.Close)
 	return pool
 }
 
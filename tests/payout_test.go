@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/banggibima/be-assignment/config"
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/banggibima/be-assignment/internal/services"
+)
+
+func TestPayoutLifecycleReleasesFailedSettlements(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const (
+		large = "merchant-payout-large"
+		small = "merchant-payout-small"
+	)
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM payouts WHERE merchant_id LIKE 'merchant-payout-%'`)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE merchant_id LIKE 'merchant-payout-%'`)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date = '2031-03-01' AND to_date = '2031-03-02'`)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	seedTransactions(t, pool, "payout-large-1", large, "2031-03-01", 1, 10000, 100)
+	seedTransactions(t, pool, "payout-large-2", large, "2031-03-02", 1, 10000, 100)
+	seedTransactions(t, pool, "payout-small", small, "2031-03-01", 1, 1000, 10)
+
+	merchantIDs := []string{large, small}
+	runSettlementJob(t, pool, startJobService(t, pool), dto.CreateSettlementJobRequest{From: "2031-03-01", To: "2031-03-02", MerchantIDs: merchantIDs})
+
+	payoutService := services.NewPayoutService(pool, repositories.NewDatabasePayoutRepository(pool), services.NewLedgerService(repositories.NewDatabaseLedgerRepository(pool)), config.Payout{MinAmount: 5000})
+
+	// Saldo merchant kecil di bawah minimum sehingga menunggu tanggal payout berikutnya
+	payouts, err := payoutService.GeneratePayouts(ctx, dto.GeneratePayoutsRequest{PayoutDate: "2031-03-03", MerchantIDs: merchantIDs})
+	if err != nil {
+		t.Fatalf("failed to generate payouts: %v", err)
+	}
+	if len(payouts) != 1 || payouts[0].MerchantID != large || payouts[0].Amount != 19800 || payouts[0].SettlementCount != 2 || payouts[0].Status != "PENDING" {
+		t.Fatalf("payouts = %+v, want one PENDING payout of 19800 for %s", payouts, large)
+	}
+	payoutID := payouts[0].ID
+
+	again, err := payoutService.GeneratePayouts(ctx, dto.GeneratePayoutsRequest{PayoutDate: "2031-03-03", MerchantIDs: merchantIDs})
+	if err != nil {
+		t.Fatalf("failed to generate payouts again: %v", err)
+	}
+	if len(again) != 0 {
+		t.Fatalf("generating again = %+v, want no payouts for settlements already paid out", again)
+	}
+
+	if _, err := payoutService.UpdatePayoutStatus(ctx, payoutID, dto.UpdatePayoutStatusRequest{Status: "CONFIRMED"}); !errors.Is(err, services.ErrInvalidPayoutTransition) {
+		t.Fatalf("confirming a PENDING payout: err = %v, want %v", err, services.ErrInvalidPayoutTransition)
+	}
+
+	reference := "bank-ref-1"
+	sent, err := payoutService.UpdatePayoutStatus(ctx, payoutID, dto.UpdatePayoutStatusRequest{Status: "SENT", Reference: &reference})
+	if err != nil {
+		t.Fatalf("failed to send payout: %v", err)
+	}
+	if sent.Status != "SENT" || sent.SentAt == nil || sent.Reference == nil || *sent.Reference != reference {
+		t.Fatalf("sent payout = %+v, want SENT with reference", sent)
+	}
+
+	if _, err := payoutService.UpdatePayoutStatus(ctx, payoutID, dto.UpdatePayoutStatusRequest{Status: "FAILED"}); !errors.Is(err, services.ErrInvalidPayoutRequest) {
+		t.Fatalf("failing without reason: err = %v, want %v", err, services.ErrInvalidPayoutRequest)
+	}
+	reason := "account closed"
+	if _, err := payoutService.UpdatePayoutStatus(ctx, payoutID, dto.UpdatePayoutStatusRequest{Status: "FAILED", FailureReason: &reason}); err != nil {
+		t.Fatalf("failed to fail payout: %v", err)
+	}
+
+	// Settlement dari payout FAILED ikut lagi pada payout berikutnya
+	minAmount := 0
+	payouts, err = payoutService.GeneratePayouts(ctx, dto.GeneratePayoutsRequest{PayoutDate: "2031-03-04", MerchantIDs: merchantIDs, MinAmount: &minAmount})
+	if err != nil {
+		t.Fatalf("failed to generate payouts: %v", err)
+	}
+
+	amounts := map[string]int{}
+	for _, payout := range payouts {
+		amounts[payout.MerchantID] = payout.Amount
+	}
+	if len(payouts) != 2 || amounts[large] != 19800 || amounts[small] != 990 {
+		t.Fatalf("payouts = %+v, want 19800 for %s and 990 for %s", payouts, large, small)
+	}
+
+	detail, err := payoutService.GetPayout(ctx, payouts[0].ID)
+	if err != nil {
+		t.Fatalf("failed to get payout: %v", err)
+	}
+	if len(detail.Items) != detail.SettlementCount {
+		t.Fatalf("payout %+v has %d items, want %d", detail, len(detail.Items), detail.SettlementCount)
+	}
+
+	// Payout PENDING bisa langsung FAILED tanpa jurnal ledger, dan settlement-nya ikut lagi pada payout berikutnya
+	var smallID string
+	for _, payout := range payouts {
+		if payout.MerchantID == small {
+			smallID = payout.ID
+		}
+	}
+	failed, err := payoutService.UpdatePayoutStatus(ctx, smallID, dto.UpdatePayoutStatusRequest{Status: "FAILED", FailureReason: &reason})
+	if err != nil {
+		t.Fatalf("failed to fail pending payout: %v", err)
+	}
+	if failed.Status != "FAILED" || failed.SentAt != nil || failed.FailedAt == nil {
+		t.Fatalf("failed payout = %+v, want FAILED without being sent", failed)
+	}
+	var journals int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM ledger_journals WHERE source_id = $1`, smallID).Scan(&journals); err != nil {
+		t.Fatalf("failed to count journals: %v", err)
+	}
+	if journals != 0 {
+		t.Fatalf("pending payout that failed posted %d journals, want none", journals)
+	}
+
+	payouts, err = payoutService.GeneratePayouts(ctx, dto.GeneratePayoutsRequest{PayoutDate: "2031-03-05", MerchantIDs: []string{small}, MinAmount: &minAmount})
+	if err != nil {
+		t.Fatalf("failed to generate payouts: %v", err)
+	}
+	if len(payouts) != 1 || payouts[0].Amount != 990 {
+		t.Fatalf("payouts = %+v, want 990 for %s again", payouts, small)
+	}
+}
+
+func TestPayoutPaysRerunDifference(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const (
+		merchantID = "merchant-payout-rerun"
+		date       = "2031-03-05"
+	)
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM ledger_journals WHERE id IN (SELECT journal_id FROM ledger_entries WHERE account_id LIKE 'merchant:merchant-payout-rerun:%')`)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM payouts WHERE merchant_id = $1`, merchantID)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE merchant_id = $1`, merchantID)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date = $1::date AND to_date = $1::date`, date)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	jobService := startJobService(t, pool)
+	req := dto.CreateSettlementJobRequest{From: date, To: date, MerchantIDs: []string{merchantID}}
+	payoutService := services.NewPayoutService(pool, repositories.NewDatabasePayoutRepository(pool), services.NewLedgerService(repositories.NewDatabaseLedgerRepository(pool)), config.Payout{})
+	generate := func(payoutDate string) []dto.PayoutResponse {
+		t.Helper()
+		payouts, err := payoutService.GeneratePayouts(ctx, dto.GeneratePayoutsRequest{PayoutDate: payoutDate, MerchantIDs: []string{merchantID}})
+		if err != nil {
+			t.Fatalf("failed to generate payouts: %v", err)
+		}
+		return payouts
+	}
+
+	seedTransactions(t, pool, "payout-rerun", merchantID, date, 1, 10000, 100)
+	runSettlementJob(t, pool, jobService, req)
+
+	payouts := generate("2031-03-06")
+	if len(payouts) != 1 || payouts[0].Amount != 9900 {
+		t.Fatalf("payouts = %+v, want one payout of 9900", payouts)
+	}
+	if _, err := payoutService.UpdatePayoutStatus(ctx, payouts[0].ID, dto.UpdatePayoutStatusRequest{Status: "SENT"}); err != nil {
+		t.Fatalf("failed to send payout: %v", err)
+	}
+
+	// Rerun setelah payout menambah payable, sehingga payout berikutnya hanya membayar selisihnya
+	seedTransactions(t, pool, "payout-rerun", merchantID, date, 1, 10000, 100)
+	runSettlementJob(t, pool, jobService, req)
+
+	payouts = generate("2031-03-07")
+	if len(payouts) != 1 || payouts[0].Amount != 9900 || payouts[0].SettlementCount != 1 {
+		t.Fatalf("payouts = %+v, want one payout of the 9900 difference", payouts)
+	}
+	if _, err := payoutService.UpdatePayoutStatus(ctx, payouts[0].ID, dto.UpdatePayoutStatusRequest{Status: "SENT"}); err != nil {
+		t.Fatalf("failed to send payout: %v", err)
+	}
+
+	// Refund setelah dibayar mengurangi payable; selisih negatif menunggu settlement berikutnya
+	_, err := pool.Exec(ctx, `
+		UPDATE transactions SET status = 'REFUNDED', reversal_amount = amount, reversed_at = '2031-03-05T13:00:00Z' WHERE id = 'txn-payout-rerun-1'
+	`)
+	if err != nil {
+		t.Fatalf("failed to refund transaction: %v", err)
+	}
+	runSettlementJob(t, pool, jobService, req)
+
+	if payouts := generate("2031-03-08"); len(payouts) != 0 {
+		t.Fatalf("payouts = %+v, want none while the difference is negative", payouts)
+	}
+
+	var outstanding int
+	err = pool.QueryRow(ctx, `
+		SELECT s.payable_amount - COALESCE(SUM(i.amount), 0)
+		FROM settlements s
+		LEFT JOIN payout_items i ON i.merchant_id = s.merchant_id AND i.date = s.date AND i.currency = s.currency
+		WHERE s.merchant_id = $1 AND s.date = $2::date
+		GROUP BY s.payable_amount
+	`, merchantID, date).Scan(&outstanding)
+	if err != nil {
+		t.Fatalf("failed to read outstanding amount: %v", err)
+	}
+	if outstanding != -10000 {
+		t.Fatalf("outstanding = %d, want -10000 to be deducted from a later payout", outstanding)
+	}
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/banggibima/be-assignment/internal/services"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// seedRunningJob inserts a RUNNING job leased by workerID and removes it when
+// the test or benchmark ends.
+func seedRunningJob(b testing.TB, pool *pgxpool.Pool, workerID string, total int) string {
+	b.Helper()
+	ctx := context.Background()
+	jobID := uuid.New().String()
+
+	_, err := pool.Exec(ctx, `
+		INSERT INTO jobs (id, job_id, job_type, status, processed, total, progress, from_date, to_date, worker_id, heartbeat_at, created_at, updated_at)
+		VALUES ($1, $2, 'SETTLEMENT', 'RUNNING', 0, $3, 0, '2025-01-01', '2025-01-31', $4, NOW(), NOW(), NOW())
+	`, uuid.New().String(), jobID, total, workerID)
+	if err != nil {
+		b.Fatalf("failed to seed job: %v", err)
+	}
+
+	b.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM jobs WHERE job_id = $1`, jobID)
+	})
+
+	return jobID
+}
+
+// BenchmarkProgressPerRow is the old behaviour: one UPDATE per processed row.
+func BenchmarkProgressPerRow(b *testing.B) {
+	pool := mustConnectDB(b)
+	ctx := context.Background()
+
+	jobRepo := repositories.NewDatabaseJobRepository(pool)
+	jobID := seedRunningJob(b, pool, "bench-worker", b.N)
+
+	b.ResetTimer()
+	for i := 1; i <= b.N; i++ {
+		if _, err := jobRepo.UpdateProgress(ctx, nil, jobID, "bench-worker", i, i*100/b.N); err != nil {
+			b.Fatalf("update progress: %v", err)
+		}
+	}
+	b.ReportMetric(1, "writes/row")
+}
+
+// BenchmarkProgressCoalesced reports every row through a ProgressReporter,
+// which only writes on the default row-count or time cadence.
+func BenchmarkProgressCoalesced(b *testing.B) {
+	pool := mustConnectDB(b)
+	ctx := context.Background()
+
+	jobRepo := repositories.NewDatabaseJobRepository(pool)
+	jobID := seedRunningJob(b, pool, "bench-worker", b.N)
+
+	writes := 0
+	reporter := services.NewProgressReporter(1000, time.Second, 0, func(processed int) error {
+		writes++
+		_, err := jobRepo.UpdateProgress(ctx, nil, jobID, "bench-worker", processed, processed*100/b.N)
+		return err
+	})
+
+	b.ResetTimer()
+	for i := 1; i <= b.N; i++ {
+		if err := reporter.Report(i); err != nil {
+			b.Fatalf("report progress: %v", err)
+		}
+	}
+	if err := reporter.Flush(); err != nil {
+		b.Fatalf("flush progress: %v", err)
+	}
+	b.ReportMetric(float64(writes)/float64(b.N), "writes/row")
+}
+
+// TestProgressCannotResurrectCancelledJob checks the status guard: a late
+// progress write from a worker must not flip a CANCELLED job back to RUNNING.
+func TestProgressCannotResurrectCancelledJob(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	jobRepo := repositories.NewDatabaseJobRepository(pool)
+	jobID := uuid.New().String()
+
+	_, err := pool.Exec(ctx, `
+		INSERT INTO jobs (id, job_id, job_type, status, processed, total, progress, from_date, to_date, worker_id, heartbeat_at, created_at, updated_at)
+		VALUES ($1, $2, 'SETTLEMENT', 'RUNNING', 0, 10, 0, '2025-01-01', '2025-01-31', 'test-worker', NOW(), NOW(), NOW())
+	`, uuid.New().String(), jobID)
+	if err != nil {
+		t.Fatalf("failed to seed job: %v", err)
+	}
+	defer pool.Exec(context.Background(), `DELETE FROM jobs WHERE job_id = $1`, jobID)
+
+	if _, err := jobRepo.CancelActive(ctx, jobID); err != nil {
+		t.Fatalf("cancel job: %v", err)
+	}
+
+	owned, err := jobRepo.UpdateProgress(ctx, nil, jobID, "test-worker", 5, 50)
+	if err != nil {
+		t.Fatalf("update progress: %v", err)
+	}
+	if owned {
+		t.Fatalf("progress update reported ownership of a cancelled job")
+	}
+
+	job, err := jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.Status != "CANCELLED" {
+		t.Fatalf("job status = %s, expected CANCELLED", job.Status)
+	}
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/banggibima/be-assignment/config"
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/banggibima/be-assignment/internal/services"
+)
+
+func TestReconcileBankStatementAgainstPayoutsAndSettlements(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const (
+		merchantA = "merchant-recon-a"
+		merchantB = "merchant-recon-b"
+	)
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM bank_reconciliations WHERE file_name LIKE 'recon-test.%'`)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM ledger_journals WHERE id IN (SELECT journal_id FROM ledger_entries WHERE account_id LIKE 'merchant:merchant-recon-%')`)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM payouts WHERE merchant_id LIKE 'merchant-recon-%'`)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE merchant_id LIKE 'merchant-recon-%'`)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date = '2031-07-01' AND to_date = '2031-07-01'`)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	seedTransactions(t, pool, "recon-a", merchantA, "2031-07-01", 1, 5000, 50)
+	seedTransactions(t, pool, "recon-b", merchantB, "2031-07-01", 1, 3000, 30)
+	runSettlementJob(t, pool, startJobService(t, pool), dto.CreateSettlementJobRequest{From: "2031-07-01", To: "2031-07-01", MerchantIDs: []string{merchantA, merchantB}})
+
+	ledgerService := services.NewLedgerService(repositories.NewDatabaseLedgerRepository(pool))
+	payoutService := services.NewPayoutService(pool, repositories.NewDatabasePayoutRepository(pool), ledgerService, config.Payout{})
+	payouts, err := payoutService.GeneratePayouts(ctx, dto.GeneratePayoutsRequest{PayoutDate: "2031-07-02", MerchantIDs: []string{merchantA, merchantB}})
+	if err != nil {
+		t.Fatalf("failed to generate payouts: %v", err)
+	}
+	for _, payout := range payouts {
+		if payout.MerchantID != merchantA {
+			continue
+		}
+		reference := "bank-recon-a"
+		if _, err := payoutService.UpdatePayoutStatus(ctx, payout.ID, dto.UpdatePayoutStatusRequest{Status: "SENT", Reference: &reference}); err != nil {
+			t.Fatalf("failed to send payout: %v", err)
+		}
+	}
+	// Payout dikirim pada tanggal payout-nya agar masuk rentang rekening koran
+	if _, err := pool.Exec(ctx, `UPDATE payouts SET sent_at = '2031-07-02 10:00:00' WHERE merchant_id = $1`, merchantA); err != nil {
+		t.Fatalf("failed to backdate payout: %v", err)
+	}
+
+	var settlementB string
+	if err := pool.QueryRow(ctx, `SELECT id FROM settlements WHERE merchant_id = $1`, merchantB).Scan(&settlementB); err != nil {
+		t.Fatalf("failed to read settlement: %v", err)
+	}
+
+	statements := map[string]string{
+		"recon-test.csv": "date,amount,currency,reference,description\n" +
+			"2031-07-02,-4950,IDR,bank-recon-a,payout merchant-recon-a\n" +
+			"2031-07-02,4950,IDR,,acquirer settlement\n" +
+			"2031-07-02,2900,IDR," + settlementB + ",\n" +
+			"2031-07-02,12345,IDR,unknown,\n",
+		"recon-test.sta": ":20:STMT1\n:25:123456789\n:28C:1/1\n:60F:C310701IDR0,\n" +
+			":61:3107020702D4950,NTRFbank-recon-a//BNK1\n:86:payout merchant-recon-a\n" +
+			":61:310702C4950,NTRFNONREF//BNK2\n:86:acquirer\nsettlement\n" +
+			":61:310702C2900,NTRF" + settlementB + "\n" +
+			":61:310702C12345,NTRFunknown\n" +
+			":62F:C310702IDR15245,\n-}\n",
+	}
+
+	reconciliationService := services.NewReconciliationService(pool, repositories.NewDatabaseReconciliationRepository(pool), config.Reconciliation{DateToleranceDays: 2})
+	for fileName, statement := range statements {
+		res, err := reconciliationService.Reconcile(ctx, fileName, []byte(statement), dto.ReconcileRequest{})
+		if err != nil {
+			t.Fatalf("%s: failed to reconcile: %v", fileName, err)
+		}
+
+		statuses := []string{}
+		for _, item := range res.Items {
+			statuses = append(statuses, item.Status)
+		}
+		want := "[MATCHED MATCHED PARTIAL UNMATCHED]"
+		if res.LineCount != 4 || fmt.Sprint(statuses) != want {
+			t.Fatalf("%s: items = %+v, want statuses %s", fileName, res.Items, want)
+		}
+		if *res.Items[0].MatchKind != "PAYOUT" || *res.Items[1].MatchKind != "SETTLEMENT" || *res.Items[1].MerchantID != merchantA {
+			t.Fatalf("%s: matched items = %+v, want the payout and settlement of %s", fileName, res.Items[:2], merchantA)
+		}
+		if *res.Items[2].MatchID != settlementB || res.Items[2].Difference != -70 {
+			t.Fatalf("%s: partial item = %+v, want settlement %s short by 70", fileName, res.Items[2], settlementB)
+		}
+
+		saved, err := reconciliationService.GetReconciliation(ctx, res.ID, dto.GetReconciliationRequest{Status: "UNMATCHED"})
+		if err != nil {
+			t.Fatalf("%s: failed to get reconciliation: %v", fileName, err)
+		}
+		if saved.UnmatchedCount != 1 || len(saved.Items) != 1 || *saved.Items[0].Reference != "unknown" {
+			t.Fatalf("%s: saved unmatched items = %+v, want the unknown line", fileName, saved.Items)
+		}
+	}
+}
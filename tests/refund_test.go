@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/banggibima/be-assignment/internal/services"
+)
+
+func TestRefundsAndChargebacksCarryDeficitForward(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const merchantID = "merchant-refund"
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE merchant_id = $1`, merchantID)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date BETWEEN '2031-01-01' AND '2031-01-03'`)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	seedTransactions(t, pool, "refund-a", merchantID, "2031-01-01", 2, 1000, 10)
+	seedTransactions(t, pool, "refund-b", merchantID, "2031-01-01", 1, 1000, 10)
+	seedTransactions(t, pool, "refund-c", merchantID, "2031-01-02", 1, 500, 5)
+	seedTransactions(t, pool, "refund-d", merchantID, "2031-01-03", 1, 2000, 20)
+
+	reverse := func(id, status string, amount int, reversedAt string) {
+		t.Helper()
+		_, err := pool.Exec(ctx, `
+			UPDATE transactions SET status = $2, reversal_amount = $3, reversed_at = $4::timestamptz WHERE id = $1
+		`, id, status, amount, reversedAt)
+		if err != nil {
+			t.Fatalf("failed to reverse %s: %v", id, err)
+		}
+	}
+	// Refund dan chargeback masuk ke hari settlement saat dibalik, bukan saat dibayar
+	reverse("txn-refund-a-1", "REFUNDED", 1000, "2031-01-02T12:00:00Z")
+	reverse("txn-refund-b-1", "CHARGEBACK", 1000, "2031-01-02T13:00:00Z")
+	reverse("txn-refund-c-1", "PARTIALLY_REFUNDED", 200, "2031-01-03T12:00:00Z")
+
+	jobService := startJobService(t, pool)
+	runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{From: "2031-01-01", To: "2031-01-02", Aggregation: "STREAM"})
+	// Job berikutnya mengambil defisit dari settlement terakhir sebelum rentangnya
+	runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{From: "2031-01-03", To: "2031-01-03", Aggregation: "DATABASE"})
+
+	rows := settlementRows(t, pool, "2031-01-01", "2031-01-03")
+
+	want := map[string]models.SettlementAggregate{
+		"2031-01-01": {GrossAmount: 3000, FeeAmount: 30, NetAmount: 2970, TxnCount: 3, PayableAmount: 2970},
+		"2031-01-02": {
+			GrossAmount: -1500, FeeAmount: 5, NetAmount: -1505, TxnCount: 1,
+			RefundAmount: 1000, ChargebackAmount: 1000, ReversalCount: 2,
+			CarryForward: -1505,
+		},
+		"2031-01-03": {
+			GrossAmount: 1800, FeeAmount: 20, NetAmount: 1780, TxnCount: 1,
+			RefundAmount: 200, ReversalCount: 1,
+			CarriedIn: -1505, PayableAmount: 275,
+		},
+	}
+	for date, w := range want {
+		got, ok := rows[merchantID+"|"+date+"|IDR"]
+		if !ok {
+			t.Fatalf("missing settlement on %s", date)
+		}
+		got.PlanFeeAmount = nil
+		if got != w {
+			t.Fatalf("settlement on %s = %+v, want %+v", date, got, w)
+		}
+	}
+
+	// Rerun rentang lama akan mengubah carry-forward yang sudah dibawa settlement 2031-01-03
+	rerun := dto.CreateSettlementJobRequest{From: "2031-01-01", To: "2031-01-02", MerchantIDs: []string{merchantID}}
+	if _, err := jobService.CreateJob(ctx, rerun); !errors.Is(err, services.ErrLaterSettlementsExist) {
+		t.Fatalf("expected ErrLaterSettlementsExist, got %v", err)
+	}
+
+	// Job yang tidak lewat CreateJob juga ditolak saat menyimpan settlement
+	job := waitForJob(t, pool, seedJob(t, pool, rerun, "QUEUED"), "DONE", "DEAD")
+	if job.Status != "DEAD" || job.LastError == nil || !strings.Contains(*job.LastError, "LATER_SETTLEMENTS_EXIST") {
+		t.Fatalf("rerun job = %s (%v), want DEAD because of later settlements", job.Status, job.LastError)
+	}
+	if after := settlementRows(t, pool, "2031-01-01", "2031-01-03"); !reflect.DeepEqual(after, rows) {
+		t.Fatalf("settlements changed by a refused rerun: got %+v, want %+v", after, rows)
+	}
+}
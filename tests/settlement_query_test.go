@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/banggibima/be-assignment/internal/services"
+)
+
+func TestListSettlementsPagesWithTotals(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const date = "2030-08-01"
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE date = $1::date`, date)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date = $1::date AND to_date = $1::date`, date)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	seedTransactions(t, pool, "query-a", "merchant-query-a", date, 2, 1000, 10)
+	seedTransactions(t, pool, "query-b", "merchant-query-b", date, 3, 2000, 20)
+	runSettlementJob(t, pool, startJobService(t, pool), dto.CreateSettlementJobRequest{From: date, To: date})
+
+	settlementService := services.NewSettlementService(
+		pool,
+		repositories.NewDatabaseTransactionRepository(pool, utcSettlementDay),
+		repositories.NewDatabaseSettlementRepository(pool),
+	)
+
+	req := dto.ListSettlementsRequest{From: date, To: date, Limit: 1}
+	merchants := []string{}
+	for {
+		page, err := settlementService.ListSettlements(ctx, req)
+		if err != nil {
+			t.Fatalf("failed to list settlements: %v", err)
+		}
+
+		want := []dto.SettlementTotalsResponse{{Currency: "IDR", GrossAmount: 8000, FeeAmount: 80, NetAmount: 7920, TxnCount: 5}}
+		if !reflect.DeepEqual(page.Totals, want) {
+			t.Fatalf("totals = %+v, want %+v", page.Totals, want)
+		}
+
+		for _, settlement := range page.Data {
+			merchants = append(merchants, settlement.MerchantID)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		req.Cursor = *page.NextCursor
+	}
+
+	if len(merchants) != 2 || merchants[0] != "merchant-query-a" || merchants[1] != "merchant-query-b" {
+		t.Fatalf("pages returned %v", merchants)
+	}
+
+	settlement, err := settlementService.GetSettlement(ctx, "merchant-query-b", date, "")
+	if err != nil {
+		t.Fatalf("failed to get settlement: %v", err)
+	}
+	if settlement.GrossAmount != 6000 || settlement.TxnCount != 3 {
+		t.Fatalf("settlement = %+v", settlement)
+	}
+
+	if _, err := settlementService.GetSettlement(ctx, "merchant-query-missing", date, ""); !errors.Is(err, services.ErrSettlementNotFound) {
+		t.Fatalf("expected ErrSettlementNotFound, got %v", err)
+	}
+}
+
+func TestSettlementsSplitPerCurrency(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const (
+		date       = "2030-12-01"
+		merchantID = "merchant-currency"
+	)
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE date = $1::date`, date)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date = $1::date AND to_date = $1::date`, date)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	seedTransactions(t, pool, "currency-idr", merchantID, date, 2, 150000, 1500)
+	seedTransactions(t, pool, "currency-usd", merchantID, date, 3, 1000, 30)
+	if _, err := pool.Exec(ctx, `UPDATE transactions SET currency = 'USD', fee_currency = 'USD' WHERE order_id = 'order-currency-usd'`); err != nil {
+		t.Fatalf("failed to set currency: %v", err)
+	}
+
+	jobService := startJobService(t, pool)
+	for _, aggregation := range []string{"STREAM", "DATABASE"} {
+		t.Run(aggregation, func(t *testing.T) {
+			runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{From: date, To: date, Aggregation: aggregation})
+
+			rows := settlementRows(t, pool, date, date)
+			if len(rows) != 2 {
+				t.Fatalf("expected one settlement per currency, got %+v", rows)
+			}
+			if got := rows[merchantID+"|"+date+"|IDR"]; got.GrossAmount != 300000 || got.FeeAmount != 3000 || got.TxnCount != 2 {
+				t.Fatalf("IDR settlement = %+v", got)
+			}
+			if got := rows[merchantID+"|"+date+"|USD"]; got.GrossAmount != 3000 || got.FeeAmount != 90 || got.TxnCount != 3 {
+				t.Fatalf("USD settlement = %+v", got)
+			}
+		})
+	}
+
+	settlementService := services.NewSettlementService(
+		pool,
+		repositories.NewDatabaseTransactionRepository(pool, utcSettlementDay),
+		repositories.NewDatabaseSettlementRepository(pool),
+	)
+
+	page, err := settlementService.ListSettlements(ctx, dto.ListSettlementsRequest{MerchantID: merchantID, From: date, To: date})
+	if err != nil {
+		t.Fatalf("failed to list settlements: %v", err)
+	}
+	want := []dto.SettlementTotalsResponse{
+		{Currency: "IDR", GrossAmount: 300000, FeeAmount: 3000, NetAmount: 297000, TxnCount: 2},
+		{Currency: "USD", GrossAmount: 3000, FeeAmount: 90, NetAmount: 2910, TxnCount: 3},
+	}
+	if !reflect.DeepEqual(page.Totals, want) {
+		t.Fatalf("totals = %+v, want %+v", page.Totals, want)
+	}
+
+	settlement, err := settlementService.GetSettlement(ctx, merchantID, date, "USD")
+	if err != nil {
+		t.Fatalf("failed to get settlement: %v", err)
+	}
+	if settlement.Currency != "USD" || settlement.TxnCount != 3 {
+		t.Fatalf("settlement = %+v", settlement)
+	}
+}
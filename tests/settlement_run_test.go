@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/banggibima/be-assignment/internal/services"
+)
+
+func TestRollbackRestoresPreviousRun(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const (
+		date       = "2030-07-01"
+		merchantID = "merchant-rollback"
+	)
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE date = $1::date`, date)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date = $1::date AND to_date = $1::date`, date)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	jobService := startJobService(t, pool)
+	settleRepo := repositories.NewDatabaseSettlementRepository(pool)
+	runService := services.NewSettlementRunService(pool, settleRepo, services.NewLedgerService(repositories.NewDatabaseLedgerRepository(pool)))
+	req := dto.CreateSettlementJobRequest{From: date, To: date}
+
+	seedTransactions(t, pool, "rollback", merchantID, date, 3, 1000, 10)
+	runSettlementJob(t, pool, jobService, req)
+
+	seedTransactions(t, pool, "rollback", merchantID, date, 2, 1000, 10)
+	runSettlementJob(t, pool, jobService, req)
+
+	runs, err := runService.ListRuns(ctx, dto.ListSettlementRunsRequest{MerchantID: merchantID, Date: date})
+	if err != nil {
+		t.Fatalf("failed to list runs: %v", err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs, got %d", len(runs))
+	}
+	latest, previous := runs[0], runs[1]
+	if !latest.Current || previous.Current {
+		t.Fatalf("expected only the latest run to be current: latest=%v previous=%v", latest.Current, previous.Current)
+	}
+	if latest.Settlement == nil || latest.Settlement.TxnCount != 5 {
+		t.Fatalf("latest run settlement = %+v, want 5 transactions", latest.Settlement)
+	}
+	if previous.Settlement == nil || previous.Settlement.TxnCount != 3 {
+		t.Fatalf("previous run settlement = %+v, want 3 transactions", previous.Settlement)
+	}
+
+	rollback, err := runService.Rollback(ctx, previous.RunID, dto.RollbackSettlementRunRequest{})
+	if err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+
+	rows := settlementRows(t, pool, date, date)
+	got := rows[merchantID+"|"+date+"|IDR"]
+	if got.TxnCount != 3 || got.GrossAmount != 3000 || got.NetAmount != 2970 {
+		t.Fatalf("settlement after rollback = %+v, want the previous run", got)
+	}
+
+	runs, err = runService.ListRuns(ctx, dto.ListSettlementRunsRequest{MerchantID: merchantID, Date: date})
+	if err != nil {
+		t.Fatalf("failed to list runs: %v", err)
+	}
+	if len(runs) != 3 || runs[0].RunID != rollback.RunID || !runs[0].Current {
+		t.Fatalf("expected the rollback run to be the current one, got %+v", runs)
+	}
+	if runs[0].SourceRunID == nil || *runs[0].SourceRunID != previous.RunID {
+		t.Fatalf("rollback run source = %v, want %s", runs[0].SourceRunID, previous.RunID)
+	}
+}
+
+func TestRollbackRefusedWithLaterSettlements(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const (
+		date       = "2030-07-02"
+		later      = "2030-07-03"
+		merchantID = "merchant-rollback-later"
+	)
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE merchant_id = $1`, merchantID)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date BETWEEN $1::date AND $2::date`, date, later)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	jobService := startJobService(t, pool)
+	settleRepo := repositories.NewDatabaseSettlementRepository(pool)
+	runService := services.NewSettlementRunService(pool, settleRepo, services.NewLedgerService(repositories.NewDatabaseLedgerRepository(pool)))
+	scope := []string{merchantID}
+
+	seedTransactions(t, pool, "rollback-later", merchantID, date, 1, 1000, 10)
+	first := runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{From: date, To: date, MerchantIDs: scope})
+	seedTransactions(t, pool, "rollback-later", merchantID, date, 2, 1000, 10)
+	runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{From: date, To: date, MerchantIDs: scope})
+
+	runs, err := runService.ListRuns(ctx, dto.ListSettlementRunsRequest{MerchantID: merchantID, Date: date})
+	if err != nil {
+		t.Fatalf("failed to list runs: %v", err)
+	}
+	var previous string
+	for _, run := range runs {
+		if run.JobID != nil && *run.JobID == first.JobID {
+			previous = run.RunID
+		}
+	}
+	if previous == "" {
+		t.Fatalf("no run recorded for job %s: %+v", first.JobID, runs)
+	}
+
+	// Settlement hari berikutnya sudah membawa carry-forward dari tanggal yang akan di-rollback
+	seedTransactions(t, pool, "rollback-later-next", merchantID, later, 1, 1000, 10)
+	runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{From: later, To: later, MerchantIDs: scope})
+	before := settlementRows(t, pool, date, later)
+
+	if _, err := runService.Rollback(ctx, previous, dto.RollbackSettlementRunRequest{}); !errors.Is(err, services.ErrLaterSettlementsExist) {
+		t.Fatalf("expected ErrLaterSettlementsExist, got %v", err)
+	}
+	if after := settlementRows(t, pool, date, later); !reflect.DeepEqual(after, before) {
+		t.Fatalf("settlements changed by a refused rollback: got %+v, want %+v", after, before)
+	}
+}
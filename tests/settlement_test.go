@@ -0,0 +1,477 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/banggibima/be-assignment/config"
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/banggibima/be-assignment/internal/services"
+	"github.com/banggibima/be-assignment/pkg/storage"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/parquet-go/parquet-go"
+)
+
+// startJobService runs a job worker pool against pool until the test ends.
+func startJobService(t *testing.T, pool *pgxpool.Pool) *services.JobService {
+	t.Helper()
+
+	return startJobServiceWithConfig(t, pool, config.Job{Workers: 2, PollInterval: 100 * time.Millisecond})
+}
+
+func startJobServiceWithConfig(t *testing.T, pool *pgxpool.Pool, cfg config.Job) *services.JobService {
+	t.Helper()
+
+	jobService := newJobService(pool, cfg, settlementArtifacts(t), nil)
+	startWorkers(t, jobService)
+
+	return jobService
+}
+
+// settlementArtifacts is the local storage the settlement tests keep their
+// reports in.
+func settlementArtifacts(t *testing.T) services.ArtifactStorage {
+	t.Helper()
+
+	artifacts, err := storage.NewLocal(filepath.Join(os.TempDir(), "settlements"))
+	if err != nil {
+		t.Fatalf("failed to init artifact storage: %v", err)
+	}
+
+	return artifacts
+}
+
+// newJobService builds a job service without starting its workers, so tests
+// can queue jobs first. events may be nil.
+func newJobService(pool *pgxpool.Pool, cfg config.Job, artifacts services.ArtifactStorage, events services.JobEventPublisher) *services.JobService {
+	return services.NewJobService(
+		pool,
+		repositories.NewDatabaseJobRepository(pool),
+		repositories.NewDatabaseTransactionRepository(pool, utcSettlementDay),
+		repositories.NewDatabaseSettlementRepository(pool),
+		repositories.NewDatabaseFeePlanRepository(pool),
+		services.NewLedgerService(repositories.NewDatabaseLedgerRepository(pool)),
+		artifacts,
+		events,
+		cfg,
+	)
+}
+
+// startWorkers runs the worker pool of jobService until the test ends.
+func startWorkers(t *testing.T, jobService *services.JobService) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	jobService.StartWorkerPool(ctx)
+}
+
+// runSettlementJob creates a settlement job and waits until it is DONE.
+func runSettlementJob(t *testing.T, pool *pgxpool.Pool, jobService *services.JobService, req dto.CreateSettlementJobRequest) *models.Job {
+	t.Helper()
+	ctx := context.Background()
+	jobRepo := repositories.NewDatabaseJobRepository(pool)
+
+	res, err := jobService.CreateJob(ctx, req)
+	if err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Minute)
+	for time.Now().Before(deadline) {
+		job, err := jobRepo.GetByID(ctx, res.JobID)
+		if err != nil {
+			t.Fatalf("failed to get job %s: %v", res.JobID, err)
+		}
+
+		switch job.Status {
+		case "DONE":
+			return job
+		case "CANCELLED", "DEAD":
+			reason := ""
+			if job.LastError != nil {
+				reason = *job.LastError
+			}
+			t.Fatalf("job %s ended as %s: %s", res.JobID, job.Status, reason)
+		}
+
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	t.Fatalf("job %s did not finish in time", res.JobID)
+	return nil
+}
+
+func TestDatabaseAggregationMatchesStream(t *testing.T) {
+	pool := mustConnectDB(t)
+
+	jobService := startJobService(t, pool)
+
+	stream := runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{
+		From:        "2025-01-01",
+		To:          "2025-01-31",
+		Aggregation: "STREAM",
+	})
+	database := runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{
+		From:        "2025-01-01",
+		To:          "2025-01-31",
+		Aggregation: "DATABASE",
+	})
+
+	if stream.Checkpoint == nil || len(stream.Checkpoint.Settlements) == 0 {
+		t.Fatalf("stream job produced no settlements; is the seed data loaded?")
+	}
+	if database.Checkpoint == nil {
+		t.Fatalf("database job has no checkpoint")
+	}
+
+	if !reflect.DeepEqual(stream.Checkpoint.Settlements, database.Checkpoint.Settlements) {
+		t.Fatalf("aggregates differ:\nstream:   %d keys\ndatabase: %d keys", len(stream.Checkpoint.Settlements), len(database.Checkpoint.Settlements))
+	}
+	if stream.Checkpoint.Processed != database.Checkpoint.Processed {
+		t.Fatalf("processed differs: stream=%d database=%d", stream.Checkpoint.Processed, database.Checkpoint.Processed)
+	}
+
+	t.Logf("%d merchant/day aggregates match over %d transactions", len(stream.Checkpoint.Settlements), stream.Checkpoint.Processed)
+}
+
+func TestPartitionedJobMatchesSingleJob(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	req := dto.CreateSettlementJobRequest{From: "2025-01-01", To: "2025-01-31"}
+
+	// Each worker pool stops when its subtest ends, so the partitioned job is
+	// never claimed by a pool that has partitioning disabled.
+	var single, partitioned *models.Job
+	t.Run("single", func(t *testing.T) {
+		single = runSettlementJob(t, pool, startJobService(t, pool), req)
+	})
+	t.Run("partitioned", func(t *testing.T) {
+		jobService := startJobServiceWithConfig(t, pool, config.Job{Workers: 4, PollInterval: 100 * time.Millisecond, PartitionDays: 7})
+		partitioned = runSettlementJob(t, pool, jobService, req)
+	})
+	if single == nil || partitioned == nil {
+		t.FailNow()
+	}
+
+	partitions, err := repositories.NewDatabaseJobRepository(pool).ListPartitions(ctx, nil, partitioned.JobID)
+	if err != nil {
+		t.Fatalf("failed to list partitions: %v", err)
+	}
+	if len(partitions) != 5 {
+		t.Fatalf("expected 5 partitions of 7 days, got %d", len(partitions))
+	}
+	for _, partition := range partitions {
+		if partition.Status != "DONE" {
+			t.Fatalf("partition %s is %s", partition.JobID, partition.Status)
+		}
+	}
+
+	if single.Checkpoint == nil || partitioned.Checkpoint == nil {
+		t.Fatalf("jobs have no checkpoint")
+	}
+	if !reflect.DeepEqual(single.Checkpoint.Settlements, partitioned.Checkpoint.Settlements) {
+		t.Fatalf("aggregates differ:\nsingle:      %d keys\npartitioned: %d keys", len(single.Checkpoint.Settlements), len(partitioned.Checkpoint.Settlements))
+	}
+	if single.Checkpoint.Processed != partitioned.Checkpoint.Processed {
+		t.Fatalf("processed differs: single=%d partitioned=%d", single.Checkpoint.Processed, partitioned.Checkpoint.Processed)
+	}
+}
+
+// settlementRows reads the settlements dated in [from, to] keyed by
+// merchant, date and currency.
+func settlementRows(t *testing.T, pool *pgxpool.Pool, from, to string) map[string]models.SettlementAggregate {
+	t.Helper()
+
+	rows, err := pool.Query(context.Background(), `
+		SELECT merchant_id, date, currency, gross_amount, fee_amount, net_amount, txn_count, plan_fee_amount, fee_mismatch,
+			refund_amount, chargeback_amount, reversal_count, carried_in, payable_amount, carry_forward
+		FROM settlements WHERE date BETWEEN $1 AND $2
+	`, from, to)
+	if err != nil {
+		t.Fatalf("failed to read settlements: %v", err)
+	}
+	defer rows.Close()
+
+	result := map[string]models.SettlementAggregate{}
+	for rows.Next() {
+		var merchantID, currency string
+		var date time.Time
+		var s models.SettlementAggregate
+		if err := rows.Scan(&merchantID, &date, &currency, &s.GrossAmount, &s.FeeAmount, &s.NetAmount, &s.TxnCount, &s.PlanFeeAmount, &s.FeeMismatch,
+			&s.RefundAmount, &s.ChargebackAmount, &s.ReversalCount, &s.CarriedIn, &s.PayableAmount, &s.CarryForward); err != nil {
+			t.Fatalf("failed to scan settlement: %v", err)
+		}
+		result[merchantID+"|"+date.Format("2006-01-02")+"|"+currency] = s
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("failed to read settlements: %v", err)
+	}
+
+	return result
+}
+
+func TestRerunReplacesSettlements(t *testing.T) {
+	pool := mustConnectDB(t)
+
+	jobService := startJobService(t, pool)
+	req := dto.CreateSettlementJobRequest{From: "2025-01-01", To: "2025-01-31"}
+
+	first := runSettlementJob(t, pool, jobService, req)
+	afterFirst := settlementRows(t, pool, req.From, req.To)
+
+	runSettlementJob(t, pool, jobService, req)
+	afterSecond := settlementRows(t, pool, req.From, req.To)
+
+	if len(afterFirst) == 0 {
+		t.Fatalf("first run produced no settlements; is the seed data loaded?")
+	}
+	if !reflect.DeepEqual(afterFirst, afterSecond) {
+		t.Fatalf("settlements changed on rerun: %d rows after first run, %d after second", len(afterFirst), len(afterSecond))
+	}
+
+	// Checkpoint hanya menyimpan agregat transaksi; fee plan dan carry-forward
+	// dihitung saat settlement disimpan, jadi kolom turunannya dikosongkan.
+	for key, aggregate := range first.Checkpoint.Settlements {
+		got := afterSecond[key]
+		got.PlanFeeAmount, got.FeeMismatch = nil, false
+		got.CarriedIn, got.PayableAmount, got.CarryForward = 0, 0, 0
+		if !reflect.DeepEqual(got, *aggregate) {
+			t.Fatalf("settlement %s = %+v, want %+v", key, got, *aggregate)
+		}
+	}
+}
+
+func TestMerchantScopedRerunLeavesOtherMerchants(t *testing.T) {
+	pool := mustConnectDB(t)
+
+	const date = "2030-09-01"
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE date = $1::date`, date)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date = $1::date AND to_date = $1::date`, date)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	jobService := startJobService(t, pool)
+
+	seedTransactions(t, pool, "scoped-a", "merchant-scoped-a", date, 2, 1000, 10)
+	seedTransactions(t, pool, "scoped-b", "merchant-scoped-b", date, 2, 1000, 10)
+	runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{From: date, To: date})
+	before := settlementRows(t, pool, date, date)
+
+	// Transaksi baru untuk kedua merchant, tetapi hanya merchant A yang di-settle ulang
+	seedTransactions(t, pool, "scoped-a", "merchant-scoped-a", date, 1, 1000, 10)
+	seedTransactions(t, pool, "scoped-b", "merchant-scoped-b", date, 1, 1000, 10)
+	job := runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{
+		From:        date,
+		To:          date,
+		MerchantIDs: []string{"merchant-scoped-a"},
+	})
+	if job.Filter == nil || len(job.Filter.MerchantIDs) != 1 {
+		t.Fatalf("job filter = %+v, want merchant-scoped-a", job.Filter)
+	}
+
+	after := settlementRows(t, pool, date, date)
+	if got := after["merchant-scoped-a|"+date+"|IDR"]; got.TxnCount != 3 {
+		t.Fatalf("merchant A after rerun = %+v, want 3 transactions", got)
+	}
+	if got, want := after["merchant-scoped-b|"+date+"|IDR"], before["merchant-scoped-b|"+date+"|IDR"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("merchant B changed: got %+v, want %+v", got, want)
+	}
+
+	// Pengecualian merchant A: hanya merchant B yang di-settle ulang
+	runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{
+		From:               date,
+		To:                 date,
+		ExcludeMerchantIDs: []string{"merchant-scoped-a"},
+	})
+
+	excluded := settlementRows(t, pool, date, date)
+	if got := excluded["merchant-scoped-b|"+date+"|IDR"]; got.TxnCount != 3 {
+		t.Fatalf("merchant B after rerun = %+v, want 3 transactions", got)
+	}
+	if got, want := excluded["merchant-scoped-a|"+date+"|IDR"], after["merchant-scoped-a|"+date+"|IDR"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("merchant A changed: got %+v, want %+v", got, want)
+	}
+}
+
+func TestStatusFilteredJobLeavesSettlements(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const date = "2030-09-02"
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE date = $1::date`, date)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date = $1::date AND to_date = $1::date`, date)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	jobService := startJobService(t, pool)
+
+	seedTransactions(t, pool, "status-filter", "merchant-status-filter", date, 3, 1000, 10)
+	_, err := pool.Exec(ctx, `
+		UPDATE transactions SET status = 'REFUNDED', reversal_amount = amount, reversed_at = paid_at
+		WHERE id = 'txn-status-filter-1'
+	`)
+	if err != nil {
+		t.Fatalf("failed to refund transaction: %v", err)
+	}
+	runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{From: date, To: date})
+	before := settlementRows(t, pool, date, date)
+
+	// Job yang hanya membaca REFUNDED hanya menulis laporan tanpa menimpa total pembayaran
+	refunds := runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{
+		From:        date,
+		To:          date,
+		MerchantIDs: []string{"merchant-status-filter"},
+		Statuses:    []string{"REFUNDED"},
+	})
+	if got := refunds.Checkpoint.Settlements["merchant-status-filter|"+date+"|IDR"]; got == nil || got.TxnCount != 1 || got.ReversalCount != 1 || got.NetAmount != -10 {
+		t.Fatalf("report-only totals = %+v, want only the refunded transaction", got)
+	}
+	var runs int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM settlement_runs WHERE job_id = $1`, refunds.JobID).Scan(&runs); err != nil {
+		t.Fatalf("failed to count runs: %v", err)
+	}
+	if runs != 0 {
+		t.Fatalf("report-only job recorded %d settlement runs, want none", runs)
+	}
+	if after := settlementRows(t, pool, date, date); !reflect.DeepEqual(after, before) {
+		t.Fatalf("report-only job changed settlements: got %+v, want %+v", after, before)
+	}
+
+	_, err = jobService.CreateJob(ctx, dto.CreateSettlementJobRequest{
+		From:     date,
+		To:       date,
+		Statuses: []string{"PENDING"},
+	})
+	if !errors.Is(err, services.ErrInvalidJobRequest) {
+		t.Fatalf("expected ErrInvalidJobRequest for an unknown status, got %v", err)
+	}
+
+	// Daftar status lengkap dengan urutan berbeda tetap diterima
+	runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{
+		From:        date,
+		To:          date,
+		MerchantIDs: []string{"merchant-status-filter"},
+		Statuses:    []string{"CHARGEBACK", "REFUNDED", "PARTIALLY_REFUNDED", "PAID"},
+	})
+
+	after := settlementRows(t, pool, date, date)
+	key := "merchant-status-filter|" + date + "|IDR"
+	if got := after[key]; got.TxnCount != 3 || got.ReversalCount != 1 {
+		t.Fatalf("settlement = %+v, want 3 transactions and 1 reversal", got)
+	}
+	if !reflect.DeepEqual(after, before) {
+		t.Fatalf("settlements changed: got %+v, want %+v", after, before)
+	}
+}
+
+func TestSettlementReportFormats(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const date = "2031-09-01"
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM ledger_journals WHERE id IN (SELECT journal_id FROM ledger_entries WHERE account_id LIKE 'merchant:merchant-report-%')`)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlements WHERE date = $1::date`, date)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM settlement_runs WHERE from_date = $1::date AND to_date = $1::date`, date)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	jobService := startJobService(t, pool)
+
+	seedTransactions(t, pool, "report-a", "merchant-report-a", date, 2, 1000, 10)
+	seedTransactions(t, pool, "report-b", "merchant-report-b", date, 1, 3000, 30)
+	job := runSettlementJob(t, pool, jobService, dto.CreateSettlementJobRequest{
+		From:         date,
+		To:           date,
+		MerchantIDs:  []string{"merchant-report-a", "merchant-report-b"},
+		ReportFormat: models.ReportFormatParquet,
+	})
+
+	want := []services.SettlementReportRow{
+		{MerchantID: "merchant-report-a", Date: date, Currency: "IDR", Gross: 2000, Fee: 20, Net: 1980, TxnCount: 2, Payable: 1980},
+		{MerchantID: "merchant-report-b", Date: date, Currency: "IDR", Gross: 3000, Fee: 30, Net: 2970, TxnCount: 1, Payable: 2970},
+	}
+
+	// Tanpa format, laporan dikirim dalam format yang dipilih saat job dibuat
+	report, err := jobService.OpenReport(ctx, job.JobID, "")
+	if err != nil {
+		t.Fatalf("failed to open report: %v", err)
+	}
+	content, err := io.ReadAll(report.Body)
+	report.Body.Close()
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	if report.FileName != job.JobID+".parquet" || report.ContentType != "application/vnd.apache.parquet" {
+		t.Fatalf("report = %s (%s), want the Parquet file", report.FileName, report.ContentType)
+	}
+
+	// Manifest yang disimpan pada job cocok dengan file yang diunduh
+	sum := sha256.Sum256(content)
+	if job.Manifest == nil || job.Manifest.SHA256 != hex.EncodeToString(sum[:]) || job.Manifest.Size != int64(len(content)) || job.Manifest.Rows != 2 {
+		t.Fatalf("manifest = %+v, want the digest and size of the %d byte report with 2 rows", job.Manifest, len(content))
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("failed to open Parquet report: %v", err)
+	}
+	if value, _ := file.Lookup("job_id"); value != job.JobID {
+		t.Fatalf("Parquet job_id metadata = %q, want %q", value, job.JobID)
+	}
+	rows, err := parquet.Read[services.SettlementReportRow](bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("failed to read Parquet report: %v", err)
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("Parquet rows = %+v, want %+v", rows, want)
+	}
+
+	// Format lain dibuat dari run settlement job tersebut dengan header yang sama
+	report, err = jobService.OpenReport(ctx, job.JobID, models.ReportFormatJSON)
+	if err != nil {
+		t.Fatalf("failed to open JSON report: %v", err)
+	}
+	defer report.Body.Close()
+
+	var decoded services.SettlementReport
+	if err := json.NewDecoder(report.Body).Decode(&decoded); err != nil {
+		t.Fatalf("failed to decode JSON report: %v", err)
+	}
+	if decoded.JobID != job.JobID || decoded.From != date || !decoded.GeneratedAt.Equal(job.Manifest.GeneratedAt) {
+		t.Fatalf("JSON header = %s %s %s, want job %s generated at %s", decoded.JobID, decoded.From, decoded.GeneratedAt, job.JobID, job.Manifest.GeneratedAt)
+	}
+	if !reflect.DeepEqual(decoded.Rows, want) {
+		t.Fatalf("JSON rows = %+v, want %+v", decoded.Rows, want)
+	}
+	wantTotals := []services.SettlementReportTotal{{Currency: "IDR", Settlements: 2, Gross: 5000, Fee: 50, Net: 4950, TxnCount: 3, Payable: 4950}}
+	if !reflect.DeepEqual(decoded.Totals, wantTotals) {
+		t.Fatalf("JSON totals = %+v, want %+v", decoded.Totals, wantTotals)
+	}
+
+	if _, err := jobService.OpenReport(ctx, "missing-job", ""); !errors.Is(err, services.ErrJobNotFound) {
+		t.Fatalf("report of a missing job = %v, want %v", err, services.ErrJobNotFound)
+	}
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/banggibima/be-assignment/config"
+	"github.com/banggibima/be-assignment/pkg/storage"
+)
+
+func TestLocalStorageRoundTrip(t *testing.T) {
+	local, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to init local storage: %v", err)
+	}
+
+	testStorageRoundTrip(t, local)
+
+	if err := local.Put(context.Background(), "../escape.csv", strings.NewReader("x"), 1, "text/csv"); err == nil {
+		t.Fatalf("expected a key outside the storage directory to be rejected")
+	}
+}
+
+// TestS3StorageRoundTrip runs against the S3-compatible endpoint in
+// STORAGE_S3_ENDPOINT (e.g. the MinIO service of compose.yaml).
+func TestS3StorageRoundTrip(t *testing.T) {
+	endpoint := os.Getenv("STORAGE_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("STORAGE_S3_ENDPOINT is not set")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	cfg.Storage.S3.Prefix = "test/" + t.Name()
+
+	s3, err := storage.NewS3(context.Background(), cfg.Storage.S3)
+	if err != nil {
+		t.Fatalf("failed to init s3 storage: %v", err)
+	}
+
+	testStorageRoundTrip(t, s3)
+}
+
+func testStorageRoundTrip(t *testing.T, s storage.Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := s.Open(ctx, "missing.csv"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a missing key, got %v", err)
+	}
+
+	for _, body := range []string{"first\n", "second,replaced\n"} {
+		if err := s.Put(ctx, "reports/job.csv", strings.NewReader(body), int64(len(body)), "text/csv"); err != nil {
+			t.Fatalf("failed to put object: %v", err)
+		}
+
+		r, err := s.Open(ctx, "reports/job.csv")
+		if err != nil {
+			t.Fatalf("failed to open object: %v", err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("failed to read object: %v", err)
+		}
+		if !bytes.Equal(got, []byte(body)) {
+			t.Fatalf("expected object %q, got %q", body, got)
+		}
+	}
+}
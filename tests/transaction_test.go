@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/banggibima/be-assignment/internal/dto"
+	"github.com/banggibima/be-assignment/internal/models"
+	"github.com/banggibima/be-assignment/internal/repositories"
+	"github.com/banggibima/be-assignment/internal/services"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// utcSettlementDay makes settlement days UTC calendar days for merchants
+// without a settlement config.
+var utcSettlementDay = models.SettlementDay{TimeZone: "UTC"}
+
+// seedTransactions inserts n PAID transactions of amount and fee for
+// merchantID paid at noon UTC on date, with IDs txn-<name>-<n>. They hang off
+// a product and order named after name, which are removed with their
+// transactions when the test ends. Calling it again with the same name adds
+// more transactions.
+func seedTransactions(t *testing.T, pool *pgxpool.Pool, name, merchantID, date string, n, amount, fee int) {
+	t.Helper()
+
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		t.Fatalf("invalid date %q: %v", date, err)
+	}
+	seedTransactionsAt(t, pool, name, merchantID, day.Add(12*time.Hour), n, amount, fee)
+}
+
+// seedTransactionsAt is seedTransactions for transactions paid at paidAt.
+func seedTransactionsAt(t *testing.T, pool *pgxpool.Pool, name, merchantID string, paidAt time.Time, n, amount, fee int) {
+	t.Helper()
+	ctx := context.Background()
+
+	productID := "product-" + name
+	orderID := "order-" + name
+
+	_, err := pool.Exec(ctx, `
+		INSERT INTO products (id, name, stock, price, created_at, updated_at)
+		VALUES ($1, $1, 0, $2, NOW(), NOW())
+		ON CONFLICT (id) DO NOTHING;
+	`, productID, amount)
+	if err != nil {
+		t.Fatalf("failed to seed product: %v", err)
+	}
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO orders (id, product_id, buyer_id, quantity, total_price, created_at, updated_at)
+		VALUES ($1, $2, 'buyer-test', 1, $3, NOW(), NOW())
+		ON CONFLICT (id) DO NOTHING;
+	`, orderID, productID, amount)
+	if err != nil {
+		t.Fatalf("failed to seed order: %v", err)
+	}
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO transactions (id, order_id, merchant_id, amount, fee, status, paid_at, created_at, updated_at)
+		SELECT 'txn-' || $1 || '-' || i, $2, $3, $4, $5, 'PAID', $6, NOW(), NOW()
+		FROM generate_series(
+			(SELECT COUNT(*) FROM transactions WHERE order_id = $2) + 1,
+			(SELECT COUNT(*) FROM transactions WHERE order_id = $2) + $7::int
+		) AS i;
+	`, name, orderID, merchantID, amount, fee, paidAt, n)
+	if err != nil {
+		t.Fatalf("failed to seed transactions: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM orders WHERE id = $1`, orderID)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM products WHERE id = $1`, productID)
+	})
+}
+
+func TestFetchBatchReturnsEverySettlementLineOnce(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	const (
+		date     = "2030-06-15"
+		total    = 1000
+		refunded = 100
+		limit    = 97
+	)
+
+	// Semua transaksi memakai paid_at yang sama supaya urutan hanya ditentukan oleh id
+	_, _ = pool.Exec(ctx, `DELETE FROM transactions WHERE paid_at >= $1::date AND paid_at < $1::date + 1`, date)
+	seedTransactions(t, pool, "keyset", "merchant-keyset", date, total, 1000, 10)
+
+	// Sebagian transaksi direfund pada detik yang sama, sehingga payment dan refund hanya dibedakan oleh kind
+	_, err := pool.Exec(ctx, `
+		UPDATE transactions SET status = 'REFUNDED', reversal_amount = amount, reversed_at = paid_at
+		WHERE id IN (SELECT id FROM transactions WHERE order_id = 'order-keyset' ORDER BY id LIMIT $1)
+	`, refunded)
+	if err != nil {
+		t.Fatalf("failed to refund transactions: %v", err)
+	}
+
+	transactionRepo := repositories.NewDatabaseTransactionRepository(pool, utcSettlementDay)
+	filter := models.TransactionFilter{Statuses: models.SettlementStatuses}
+
+	seen := make(map[string]int, total+refunded)
+	var after models.SettlementLineCursor
+	for {
+		lines, err := transactionRepo.FetchBatch(ctx, date, date, filter, after, limit)
+		if err != nil {
+			t.Fatalf("failed to fetch batch: %v", err)
+		}
+
+		for _, line := range lines {
+			seen[line.TransactionID+"|"+line.Kind]++
+			after = models.SettlementLineCursor{OccurredAt: line.OccurredAt.Format(time.RFC3339Nano), TransactionID: line.TransactionID, Kind: line.Kind}
+		}
+
+		if len(lines) < limit {
+			break
+		}
+	}
+
+	if len(seen) != total+refunded {
+		t.Fatalf("expected %d distinct settlement lines, got %d", total+refunded, len(seen))
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Fatalf("settlement line %s was fetched %d times", key, n)
+		}
+	}
+
+	count, err := transactionRepo.CountByDateRange(ctx, date, date, filter)
+	if err != nil {
+		t.Fatalf("failed to count settlement lines: %v", err)
+	}
+	if count != total+refunded {
+		t.Fatalf("expected %d settlement lines counted, got %d", total+refunded, count)
+	}
+}
+
+func TestIngestTransactionsValidatesAndDeduplicates(t *testing.T) {
+	pool := mustConnectDB(t)
+	ctx := context.Background()
+
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM ledger_journals WHERE source_id LIKE 'txn-ingest-%'`)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM transactions WHERE id LIKE 'txn-ingest-%'`)
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	// Tanpa transaksi; hanya membuat order-ingest
+	seedTransactions(t, pool, "ingest", "merchant-ingest", "2031-08-01", 0, 1000, 10)
+
+	ledgerService := services.NewLedgerService(repositories.NewDatabaseLedgerRepository(pool))
+	transactionService := services.NewTransactionService(pool, repositories.NewDatabaseTransactionRepository(pool, utcSettlementDay), ledgerService)
+	paidAt := time.Date(2031, 8, 1, 9, 0, 0, 0, time.UTC)
+
+	req := dto.CreateTransactionRequest{ID: "txn-ingest-1", OrderID: "order-ingest", MerchantID: "merchant-ingest", Amount: 1000, Fee: 10, Status: "PAID", PaidAt: paidAt}
+	res, created, err := transactionService.CreateTransaction(ctx, req)
+	if err != nil || !created || res.Currency != "IDR" || res.FeeCurrency != "IDR" {
+		t.Fatalf("create = %+v, %v, %v, want a new IDR transaction", res, created, err)
+	}
+
+	// Mengirim ulang ID yang sama mengembalikan transaksi yang tersimpan
+	req.Amount = 2000
+	res, created, err = transactionService.CreateTransaction(ctx, req)
+	if err != nil || created || res.Amount != 1000 {
+		t.Fatalf("create again = %+v, %v, %v, want the stored transaction", res, created, err)
+	}
+
+	req.ID, req.OrderID = "txn-ingest-2", "order-ingest-missing"
+	if _, _, err := transactionService.CreateTransaction(ctx, req); !errors.Is(err, services.ErrOrderNotFound) {
+		t.Fatalf("create for unknown order = %v, want %v", err, services.ErrOrderNotFound)
+	}
+
+	imports := map[string]string{
+		"NDJSON": `{"id":"txn-ingest-1","order_id":"order-ingest","merchant_id":"merchant-ingest","amount":1000,"fee":10,"status":"PAID","paid_at":"2031-08-01T09:00:00Z"}
+{"id":"txn-ingest-n1","order_id":"order-ingest","merchant_id":"merchant-ingest","amount":1000,"fee":10,"status":"REFUNDED","paid_at":"2031-08-01T09:00:00Z","reversed_at":"2031-08-01T10:00:00Z"}
+{"id":"txn-ingest-n1","order_id":"order-ingest","merchant_id":"merchant-ingest","amount":1000,"fee":10,"status":"PAID","paid_at":"2031-08-01T09:00:00Z"}
+
+{"id":"txn-ingest-n2","order_id":"order-ingest-missing","merchant_id":"merchant-ingest","amount":1000,"fee":10,"status":"PAID","paid_at":"2031-08-01T09:00:00Z"}
+{"id":"txn-ingest-n3","order_id":"order-ingest","merchant_id":"merchant-ingest","amount":1000,"fee":2000,"status":"PAID","paid_at":"2031-08-01T09:00:00Z"}
+{"id":"txn-ingest-n4","unknown":true}
+`,
+		"CSV": "id,order_id,merchant_id,amount,fee,status,paid_at\n" +
+			"txn-ingest-1,order-ingest,merchant-ingest,1000,10,PAID,2031-08-01T09:00:00Z\n" +
+			"txn-ingest-c1,order-ingest,merchant-ingest,1000,10,PAID,2031-08-01T09:00:00Z\n" +
+			"txn-ingest-c1,order-ingest,merchant-ingest,1000,10,PAID,2031-08-01T09:00:00Z\n" +
+			"txn-ingest-c2,order-ingest-missing,merchant-ingest,1000,10,PAID,2031-08-01T09:00:00Z\n" +
+			"txn-ingest-c3,order-ingest,merchant-ingest,1000,2000,PAID,2031-08-01T09:00:00Z\n" +
+			"txn-ingest-c4,order-ingest,merchant-ingest,ten,10,PAID,2031-08-01T09:00:00Z\n",
+	}
+	for format, body := range imports {
+		res, err := transactionService.ImportTransactions(ctx, format, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("%s: failed to import: %v", format, err)
+		}
+		if res.Total != 6 || res.Created != 1 || res.Duplicates != 2 || res.Failed != 3 {
+			t.Fatalf("%s: import = %+v, want 6 rows: 1 created, 2 duplicates, 3 failed", format, res)
+		}
+
+		rows := []int{}
+		for _, e := range res.Errors {
+			rows = append(rows, e.Row)
+		}
+		// Baris 4 NDJSON kosong dan baris 1 CSV adalah header; order yang tidak dikenal baru ditolak saat batch disimpan
+		want := "[6 7 5]"
+		if fmt.Sprint(rows) != want {
+			t.Fatalf("%s: error rows = %v, want %s", format, res.Errors, want)
+		}
+	}
+
+	refunded, err := transactionService.GetTransaction(ctx, "txn-ingest-n1")
+	if err != nil || refunded.Status != "REFUNDED" || refunded.ReversalAmount != 1000 {
+		t.Fatalf("imported transaction = %+v, %v, want refunded in full", refunded, err)
+	}
+
+	// Transaksi yang dicatat langsung diposting ke ledger, dan duplikat tidak diposting lagi
+	wantJournals := map[string][]string{
+		"txn-ingest-1":  {"FEE", "PAYMENT"},
+		"txn-ingest-n1": {"FEE", "PAYMENT", "REFUND"},
+		"txn-ingest-c1": {"FEE", "PAYMENT"},
+	}
+	for id, want := range wantJournals {
+		journals, err := ledgerService.ListJournals(ctx, dto.ListLedgerJournalsRequest{SourceID: id})
+		if err != nil {
+			t.Fatalf("failed to list journals: %v", err)
+		}
+		kinds := []string{}
+		for _, journal := range journals.Data {
+			kinds = append(kinds, journal.Kind)
+		}
+		slices.Sort(kinds)
+		if !slices.Equal(kinds, want) {
+			t.Fatalf("journals of %s = %v, want %v", id, kinds, want)
+		}
+	}
+}